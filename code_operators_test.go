@@ -0,0 +1,49 @@
+package jsonvaluate
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCodeOperators(t *testing.T) {
+	tests := []struct {
+		value  interface{}
+		op     Operator
+		expect bool
+	}{
+		{"TH", OperatorIsCountryCode, true},
+		{"th", OperatorIsCountryCode, true},
+		{"Sg", OperatorIsCountryCode, true},
+		{"US", OperatorIsCountryCode, true},
+		{"XX", OperatorIsCountryCode, false},
+		{"UK", OperatorIsCountryCode, false},
+		{"THA", OperatorIsCountryCode, false},
+		{" TH", OperatorIsCountryCode, false},
+		{"", OperatorIsCountryCode, false},
+		{"THB", OperatorIsCurrencyCode, true},
+		{"usd", OperatorIsCurrencyCode, true},
+		{"EUR", OperatorIsCurrencyCode, true},
+		{"XAU", OperatorIsCurrencyCode, true},
+		{"XXX", OperatorIsCurrencyCode, false},
+		{"ABC", OperatorIsCurrencyCode, false},
+		{"TH", OperatorIsCurrencyCode, false},
+		{"THB", OperatorIsCountryCode, false},
+		// Non-strings never match
+		{764, OperatorIsCountryCode, false},
+		{nil, OperatorIsCurrencyCode, false},
+		{[]string{"TH"}, OperatorIsCountryCode, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%v %s", tt.value, tt.op), func(t *testing.T) {
+			data := map[string]interface{}{"code": tt.value}
+			if result := evalSingleCondition("code", tt.op, nil, data); result != tt.expect {
+				t.Errorf("%s(%v) = %v, want %v", tt.op, tt.value, result, tt.expect)
+			}
+		})
+	}
+
+	if n := len(countryCodes); n != 249 {
+		t.Errorf("got %d country codes, want 249", n)
+	}
+}
@@ -4,9 +4,14 @@
 package jsonvaluate
 
 import (
+	"cmp"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -41,8 +46,247 @@ const (
 	OperatorEndsWith   Operator = "endswith"   // String ends with suffix
 	OperatorBetween    Operator = "between"    // Value is between two bounds (inclusive)
 	OperatorNotBetween Operator = "notbetween" // Value is not between two bounds
+
+	// Additional collection and string operators
+	OperatorInString Operator = "in_string" // Value is a substring of the given string
+	OperatorLikeAny  Operator = "like_any"  // Matches at least one of the LIKE patterns
+	OperatorLikeAll  Operator = "like_all"  // Matches all of the LIKE patterns
+	OperatorCount    Operator = "count"     // Number of elements matches a count or [operator, count]
+	OperatorIndexOf  Operator = "indexof"   // Substring first occurs at the given index, or -1 if absent
+	OperatorIsUnique Operator = "isunique"  // No two elements of the collection are equal
+	OperatorPrefixIn Operator = "prefix_in" // String starts with at least one of the prefixes
+	OperatorSuffixIn Operator = "suffix_in" // String ends with at least one of the suffixes
+	OperatorKeyIn    Operator = "key_in"    // Value is a key of the given map
+
+	// Length operators
+	OperatorLengthBetween Operator = "length_between" // Length of a string or collection is in [min, max]
+
+	// Text operators convert both operands to strings
+	OperatorStrContains Operator = "str_contains" // Text of the value contains the text of the given value
+	OperatorFuzzyEq     Operator = "fuzzy_eq"     // Text of the value is within an edit distance of the given text, given as [target, maxDistance]
+
+	// Set operators compare collections ignoring order and duplicates
+	OperatorSuperset    Operator = "superset"     // Collection contains every element of the given collection
+	OperatorSubset      Operator = "subset"       // Every element of the collection is in the given collection
+	OperatorSetEq       Operator = "set_eq"       // Collection has the same elements as the given collection
+	OperatorSetContains Operator = "set_contains" // Delimited string or collection contains the value, or every element of a list, given as [delimiter, value]
+
+	// Numeric sign operators ignore Value, like isnull and istrue
+	OperatorIsPositive Operator = "ispositive" // Numeric value is greater than zero
+	OperatorIsNegative Operator = "isnegative" // Numeric value is less than zero
+	OperatorIsZero     Operator = "iszero"     // Numeric value equals zero
+
+	// Additional numeric operators
+	OperatorWithinPct Operator = "within_pct" // Number is within a percentage of a target, given as [target, percent]
+	OperatorStep      Operator = "step"       // Number is in [min, max] and a whole number of steps above min, given as [min, max, step]
+	OperatorBucketIs  Operator = "bucket_is"  // First bucket of an ordered list of {max, label} holding the number has the label, given as [buckets, label]
+	OperatorIsInteger Operator = "isinteger"  // Numeric value has no fractional part
+
+	// Percentage range operators
+	OperatorPctBetween Operator = "pct_between" // Number is between two percentages of another field, given as [basisKey, lowPct, highPct]
+
+	// Bit flag operators test the bits of a non-negative integer against a mask
+	OperatorBitSet   Operator = "bitset"   // Every bit of the mask is set
+	OperatorBitClear Operator = "bitclear" // No bit of the mask is set
+
+	// Composition operators
+	OperatorSubmatch Operator = "submatch" // Condition tree stored in the field matches the data
+	OperatorAnyOp    Operator = "any_op"   // Field matches any of a list of {operator, value} pairs
+
+	// Quantifier operators apply an {operator, value} pair to each element
+	OperatorAny Operator = "any" // Some element of the collection matches the {operator, value} pair
+	OperatorAll Operator = "all" // Every element of the collection matches the {operator, value} pair
+
+	// Regular expression operators
+	OperatorRegexExtract Operator = "regex_extract" // Capture group of a regular expression matches an operator, given as [pattern, group, operator, value]
+	OperatorCategoryIs   Operator = "category_is"   // First matching category of an ordered list of {pattern, label} has the label, given as [categories, label]
+
+	// Rank operators compare positions in an ordered list
+	OperatorRankGt  Operator = "rank_gt"  // Ranks after the threshold in an ordered list
+	OperatorRankGte Operator = "rank_gte" // Ranks at or after the threshold in an ordered list
+	OperatorRankLt  Operator = "rank_lt"  // Ranks before the threshold in an ordered list
+	OperatorRankLte Operator = "rank_lte" // Ranks at or before the threshold in an ordered list
+
+	// Semantic version operators
+	OperatorSemverEq  Operator = "semver_eq"  // Same semantic version
+	OperatorSemverGt  Operator = "semver_gt"  // Later semantic version
+	OperatorSemverGte Operator = "semver_gte" // Same or later semantic version
+	OperatorSemverLt  Operator = "semver_lt"  // Earlier semantic version
+	OperatorSemverLte Operator = "semver_lte" // Same or earlier semantic version
+
+	// Type and structure operators
+	OperatorTypeIs        Operator = "typeis"         // Value's runtime type is one of the named types
+	OperatorMatchesSchema Operator = "matches_schema" // Object has the keys and types described by a Schema
+	OperatorJSONEq        Operator = "json_eq"        // Equal as JSON, ignoring key order and numeric types
+
+	// Format operators
+	OperatorIsFormat Operator = "isformat" // String is in the named format, such as "email", "url" or "uuid"
+
+	// Code operators validate ISO codes and ignore Value
+	OperatorIsCountryCode  Operator = "is_country_code"  // String is an ISO 3166-1 alpha-2 country code
+	OperatorIsCurrencyCode Operator = "is_currency_code" // String is an ISO 4217 currency code
+
+	// Network operators
+	OperatorIPInCIDR Operator = "ip_in_cidr" // IP address is in the CIDR block, or one of the CIDR blocks
+
+	// Date and time operators
+	OperatorYearEq    Operator = "year=="    // Time's year equals (one of) the given year(s)
+	OperatorMonthEq   Operator = "month=="   // Time's month equals (one of) the given month(s)
+	OperatorDayEq     Operator = "day=="     // Time's day of month equals (one of) the given day(s)
+	OperatorWeekdayEq Operator = "weekday==" // Time's weekday equals (one of) the given weekday(s)
+	OperatorWithin    Operator = "within"    // Time is within the given duration before now
+	OperatorOlderThan Operator = "olderthan" // Time is more than the given duration before now
+
+	// Time comparison operators
+	OperatorTimeWithin Operator = "time_within" // Time is within a duration of a target time, given as [target, duration]
+	OperatorInSchedule Operator = "in_schedule" // Time falls in a weekly Schedule of days and a daily time window
+
+	// Date validation operators
+	OperatorIsDate     Operator = "isdate"     // String parses as a date, with the given layout(s) if any
+	OperatorIsDateTime Operator = "isdatetime" // String parses as a date and time of day, with the given layout(s) if any
 )
 
+// OperatorInfo describes an operator for catalogs such as rule-builder UIs.
+type OperatorInfo struct {
+	Name        Operator
+	Builtin     bool   // Implemented by the library rather than registered
+	UsesValue   bool   // Reads the condition's Value; false for operators like isnull
+	Description string // Short human-readable description; empty for custom operators
+}
+
+// builtinOperatorTable lists every operator implemented by the library, in
+// declaration order, with its metadata.
+var builtinOperatorTable = []OperatorInfo{
+	{Name: OperatorEq, UsesValue: true, Description: "Equal to"},
+	{Name: OperatorNeq, UsesValue: true, Description: "Not equal to"},
+	{Name: OperatorGt, UsesValue: true, Description: "Greater than"},
+	{Name: OperatorGte, UsesValue: true, Description: "Greater than or equal to"},
+	{Name: OperatorLt, UsesValue: true, Description: "Less than"},
+	{Name: OperatorLte, UsesValue: true, Description: "Less than or equal to"},
+	{Name: OperatorIn, UsesValue: true, Description: "Value is in collection"},
+	{Name: OperatorNin, UsesValue: true, Description: "Value is not in collection"},
+	{Name: OperatorContains, UsesValue: true, Description: "String contains substring"},
+	{Name: OperatorNcontains, UsesValue: true, Description: "String does not contain substring"},
+	{Name: OperatorIsnull, UsesValue: false, Description: "Value is null or doesn't exist"},
+	{Name: OperatorIsnotnull, UsesValue: false, Description: "Value is not null and exists"},
+	{Name: OperatorIsEmpty, UsesValue: false, Description: "Value is empty (empty string, array, etc.)"},
+	{Name: OperatorIsNotEmpty, UsesValue: false, Description: "Value is not empty"},
+	{Name: OperatorIsTrue, UsesValue: false, Description: "Value is true (boolean or truthy)"},
+	{Name: OperatorIsFalse, UsesValue: false, Description: "Value is false (boolean or falsy)"},
+	{Name: OperatorLike, UsesValue: true, Description: "SQL-like pattern matching (case sensitive)"},
+	{Name: OperatorIlike, UsesValue: true, Description: "SQL-like pattern matching (case insensitive)"},
+	{Name: OperatorNlike, UsesValue: true, Description: "NOT SQL-like pattern matching"},
+	{Name: OperatorStartsWith, UsesValue: true, Description: "String starts with prefix"},
+	{Name: OperatorEndsWith, UsesValue: true, Description: "String ends with suffix"},
+	{Name: OperatorBetween, UsesValue: true, Description: "Value is between two bounds (inclusive)"},
+	{Name: OperatorNotBetween, UsesValue: true, Description: "Value is not between two bounds"},
+
+	{Name: OperatorInString, UsesValue: true, Description: "Value is a substring of the given string"},
+	{Name: OperatorLikeAny, UsesValue: true, Description: "Matches at least one of the LIKE patterns"},
+	{Name: OperatorLikeAll, UsesValue: true, Description: "Matches all of the LIKE patterns"},
+	{Name: OperatorCount, UsesValue: true, Description: "Number of elements matches a count or [operator, count]"},
+	{Name: OperatorIndexOf, UsesValue: true, Description: "Substring first occurs at the given index, or -1 if absent"},
+	{Name: OperatorIsUnique, UsesValue: false, Description: "No two elements of the collection are equal"},
+	{Name: OperatorPrefixIn, UsesValue: true, Description: "String starts with at least one of the prefixes"},
+	{Name: OperatorSuffixIn, UsesValue: true, Description: "String ends with at least one of the suffixes"},
+	{Name: OperatorKeyIn, UsesValue: true, Description: "Value is a key of the given map"},
+
+	{Name: OperatorLengthBetween, UsesValue: true, Description: "Length of a string or collection is in [min, max]"},
+	{Name: OperatorStrContains, UsesValue: true, Description: "Text of the value contains the text of the given value"},
+	{Name: OperatorFuzzyEq, UsesValue: true, Description: "Text of the value is within an edit distance of the given text, given as [target, maxDistance]"},
+
+	{Name: OperatorSuperset, UsesValue: true, Description: "Collection contains every element of the given collection"},
+	{Name: OperatorSubset, UsesValue: true, Description: "Every element of the collection is in the given collection"},
+	{Name: OperatorSetEq, UsesValue: true, Description: "Collection has the same elements as the given collection"},
+	{Name: OperatorSetContains, UsesValue: true, Description: "Delimited string or collection contains the value, or every element of a list, given as [delimiter, value]"},
+
+	{Name: OperatorIsPositive, UsesValue: false, Description: "Numeric value is greater than zero"},
+	{Name: OperatorIsNegative, UsesValue: false, Description: "Numeric value is less than zero"},
+	{Name: OperatorIsZero, UsesValue: false, Description: "Numeric value equals zero"},
+
+	{Name: OperatorWithinPct, UsesValue: true, Description: "Number is within a percentage of a target, given as [target, percent]"},
+	{Name: OperatorStep, UsesValue: true, Description: "Number is in [min, max] and a whole number of steps above min, given as [min, max, step]"},
+	{Name: OperatorBucketIs, UsesValue: true, Description: "First bucket of an ordered list of {max, label} holding the number has the label, given as [buckets, label]"},
+	{Name: OperatorPctBetween, UsesValue: true, Description: "Number is between two percentages of another field, given as [basisKey, lowPct, highPct]"},
+	{Name: OperatorBitSet, UsesValue: true, Description: "Every bit of the mask is set"},
+	{Name: OperatorBitClear, UsesValue: true, Description: "No bit of the mask is set"},
+	{Name: OperatorIsInteger, UsesValue: false, Description: "Numeric value has no fractional part"},
+
+	{Name: OperatorSubmatch, UsesValue: false, Description: "Condition tree stored in the field matches the data"},
+	{Name: OperatorAnyOp, UsesValue: true, Description: "Field matches any of a list of {operator, value} pairs"},
+
+	{Name: OperatorAny, UsesValue: true, Description: "Some element of the collection matches the {operator, value} pair"},
+	{Name: OperatorAll, UsesValue: true, Description: "Every element of the collection matches the {operator, value} pair"},
+	{Name: OperatorRegexExtract, UsesValue: true, Description: "Capture group of a regular expression matches an operator, given as [pattern, group, operator, value]"},
+	{Name: OperatorCategoryIs, UsesValue: true, Description: "First matching category of an ordered list of {pattern, label} has the label, given as [categories, label]"},
+
+	{Name: OperatorRankGt, UsesValue: true, Description: "Ranks after the threshold in an ordered list"},
+	{Name: OperatorRankGte, UsesValue: true, Description: "Ranks at or after the threshold in an ordered list"},
+	{Name: OperatorRankLt, UsesValue: true, Description: "Ranks before the threshold in an ordered list"},
+	{Name: OperatorRankLte, UsesValue: true, Description: "Ranks at or before the threshold in an ordered list"},
+
+	{Name: OperatorSemverEq, UsesValue: true, Description: "Same semantic version"},
+	{Name: OperatorSemverGt, UsesValue: true, Description: "Later semantic version"},
+	{Name: OperatorSemverGte, UsesValue: true, Description: "Same or later semantic version"},
+	{Name: OperatorSemverLt, UsesValue: true, Description: "Earlier semantic version"},
+	{Name: OperatorSemverLte, UsesValue: true, Description: "Same or earlier semantic version"},
+
+	{Name: OperatorTypeIs, UsesValue: true, Description: "Value's runtime type is one of the named types"},
+	{Name: OperatorMatchesSchema, UsesValue: true, Description: "Object has the keys and types described by a Schema"},
+	{Name: OperatorJSONEq, UsesValue: true, Description: "Equal as JSON, ignoring key order and numeric types"},
+
+	{Name: OperatorIsFormat, UsesValue: true, Description: "String is in the named format, such as \"email\", \"url\" or \"uuid\""},
+
+	{Name: OperatorIsCountryCode, UsesValue: false, Description: "String is an ISO 3166-1 alpha-2 country code"},
+	{Name: OperatorIsCurrencyCode, UsesValue: false, Description: "String is an ISO 4217 currency code"},
+
+	{Name: OperatorIPInCIDR, UsesValue: true, Description: "IP address is in the CIDR block, or one of the CIDR blocks"},
+
+	{Name: OperatorYearEq, UsesValue: true, Description: "Time's year equals (one of) the given year(s)"},
+	{Name: OperatorMonthEq, UsesValue: true, Description: "Time's month equals (one of) the given month(s)"},
+	{Name: OperatorDayEq, UsesValue: true, Description: "Time's day of month equals (one of) the given day(s)"},
+	{Name: OperatorWeekdayEq, UsesValue: true, Description: "Time's weekday equals (one of) the given weekday(s)"},
+	{Name: OperatorWithin, UsesValue: true, Description: "Time is within the given duration before now"},
+	{Name: OperatorOlderThan, UsesValue: true, Description: "Time is more than the given duration before now"},
+
+	{Name: OperatorTimeWithin, UsesValue: true, Description: "Time is within a duration of a target time, given as [target, duration]"},
+	{Name: OperatorInSchedule, UsesValue: true, Description: "Time falls in a weekly Schedule of days and a daily time window"},
+
+	{Name: OperatorIsDate, UsesValue: true, Description: "String parses as a date, with the given layout(s) if any"},
+	{Name: OperatorIsDateTime, UsesValue: true, Description: "String parses as a date and time of day, with the given layout(s) if any"},
+}
+
+// builtinOperators is the set of operators implemented by the library.
+// Custom operators cannot be registered under these names.
+var builtinOperators = func() map[Operator]bool {
+	set := make(map[Operator]bool, len(builtinOperatorTable))
+	for _, info := range builtinOperatorTable {
+		set[info.Name] = true
+	}
+	return set
+}()
+
+// IsBuiltinOperator reports whether op is one of the library's built-in operators.
+func IsBuiltinOperator(op Operator) bool {
+	return builtinOperators[op]
+}
+
+// AllOperators returns the catalog of available operators: the built-in
+// operators in declaration order, followed by the registered custom operators
+// sorted lexicographically. Custom operators are reported as using Value.
+func AllOperators() []OperatorInfo {
+	custom := GetRegisteredCustomOperators()
+	infos := make([]OperatorInfo, 0, len(builtinOperatorTable)+len(custom))
+	for _, info := range builtinOperatorTable {
+		info.Builtin = true
+		infos = append(infos, info)
+	}
+	for _, op := range custom {
+		infos = append(infos, OperatorInfo{Name: op, UsesValue: true})
+	}
+	return infos
+}
+
 // Logic represents the logical operation for combining multiple conditions.
 type Logic string
 
@@ -50,6 +294,10 @@ type Logic string
 const (
 	LogicAnd Logic = "AND" // All conditions must be true
 	LogicOr  Logic = "OR"  // At least one condition must be true
+	LogicNot Logic = "NOT" // The single child condition must be false
+
+	LogicAtLeast Logic = "ATLEAST" // At least Threshold conditions must be true
+	LogicImplies Logic = "IMPLIES" // If the first of two child conditions is true, so must be the second
 )
 
 // Conditions represents a condition tree that can be either a single condition
@@ -57,6 +305,16 @@ const (
 //
 // For single conditions, use Key, Operator, and Value fields.
 // For group conditions, use Logic and Children fields.
+// If a node sets both, the group fields take precedence and Key, Operator and
+// Value are ignored; ValidateConditions rejects such nodes.
+//
+// An ATLEAST group is true when at least Threshold of its children are true,
+// for k-of-n rules such as "at least 2 of these 4". A Threshold of 0 is always
+// true, and one larger than the number of children is never true.
+//
+// A single condition may set Default, which is used as the field value when Key
+// is missing from the data, before the operator runs. This avoids wrapping
+// optional fields in an OR with isnull. A nil Default means no default.
 //
 // Example single condition:
 //
@@ -76,12 +334,19 @@ const (
 //	    },
 //	}
 type Conditions struct {
-	Logic    Logic        `json:"logic,omitempty"`    // "AND" or "OR" for group, empty for single
-	Children []Conditions `json:"children,omitempty"` // Child conditions for group
+	Logic     Logic        `json:"logic,omitempty"`     // "AND" or "OR" for group, empty for single
+	Children  []Conditions `json:"children,omitempty"`  // Child conditions for group
+	Threshold int          `json:"threshold,omitempty"` // Number of children that must be true for an ATLEAST group
 
 	Key      string      `json:"key,omitempty"`      // Field key for single condition
 	Operator Operator    `json:"operator,omitempty"` // Comparison operator for single condition
 	Value    interface{} `json:"value,omitempty"`    // Expected value for single condition
+	Default  interface{} `json:"default,omitempty"`  // Field value to use when Key is missing from the data
+
+	// Name and ID label the node for people and tools, e.g. "Applicant must
+	// be an adult" and "adult-check". Evaluation ignores them.
+	Name string `json:"name,omitempty"`
+	ID   string `json:"id,omitempty"`
 }
 
 // CustomOperatorValidator defines the function signature for custom operator validation.
@@ -89,16 +354,70 @@ type Conditions struct {
 // and returns true if the condition is satisfied.
 type CustomOperatorValidator func(fieldValue, expectedValue interface{}) bool
 
+// CustomOperatorValidatorE is like CustomOperatorValidator but can also report
+// that the condition could not be evaluated (bad expected value, unsupported
+// field type, ...). A non-nil error is surfaced by EvaluateConditionE; the
+// bool-only evaluation functions treat it as false.
+type CustomOperatorValidatorE func(fieldValue, expectedValue interface{}) (bool, error)
+
+// FieldValue is the field a custom operator registered with
+// RegisterCustomOperatorField is applied to. Exists tells a field that is
+// missing from the data apart from one explicitly set to null, which both
+// have a nil Value. A field filled in from the condition's Default exists.
+type FieldValue struct {
+	Value  interface{}
+	Exists bool
+}
+
+// CustomOperatorFieldValidator is like CustomOperatorValidatorE but receives
+// the field as a FieldValue, so it can tell whether the field exists.
+type CustomOperatorFieldValidator func(field FieldValue, expectedValue interface{}) (bool, error)
+
+// ErrOperatorPanicked is returned by EvaluateConditionE when a custom operator panics.
+var ErrOperatorPanicked = errors.New("custom operator panicked")
+
+// ErrNotContainer is returned by EvaluateConditionE when "contains" or
+// "ncontains" is applied to a field that is neither a string nor a slice or
+// array and Evaluator.StrictContains is set.
+var ErrNotContainer = errors.New("value is not a string or collection")
+
+// ErrIncomparable is returned by EvaluateConditionE when an ordering operator
+// compares values of incomparable types and Evaluator.StrictCompare is set.
+var ErrIncomparable = errors.New("values are not comparable")
+
+// ErrUnknownParam is returned by EvaluateConditionE when a ParamRef names a
+// parameter that isn't in Evaluator.Params.
+var ErrUnknownParam = errors.New("unknown parameter")
+
+// CustomOperatorValueCheck checks that the expected value of a condition has
+// the shape a custom operator needs, such as a two-element slice, returning an
+// error describing what is wrong if it doesn't.
+type CustomOperatorValueCheck func(expectedValue interface{}) error
+
+// ErrInvalidValue is returned by ValidateConditions, ValidateConditionGroup
+// and EvaluateConditionE when a condition's value fails the value check of its
+// custom operator. The check's own error is wrapped as well.
+var ErrInvalidValue = errors.New("invalid value")
+
+// customOperator is a registered custom operator
+type customOperator struct {
+	validator  CustomOperatorFieldValidator
+	checkValue CustomOperatorValueCheck // nil if any value is accepted
+}
+
 // Thread-safe registry for custom operators
 var (
-	customOperators = make(map[Operator]CustomOperatorValidator)
+	customOperators = make(map[Operator]customOperator)
 	customOpsMutex  sync.RWMutex
 )
 
 // RegisterCustomOperator registers a new custom operator with its validation function.
-// The operator name should be unique and not conflict with built-in operators.
+// The operator name should be unique and must not conflict with built-in operators,
+// which would otherwise silently take precedence; use IsBuiltinOperator to check.
 // The validator function will be called with the field value and expected value.
 //
+// It panics if validator is nil or operator is a built-in operator.
+//
 // Example:
 //
 //	RegisterCustomOperator("case_insensitive_eq", func(fieldValue, expectedValue interface{}) bool {
@@ -108,12 +427,201 @@ var (
 //	})
 func RegisterCustomOperator(operator Operator, validator CustomOperatorValidator) {
 	if validator == nil {
-		panic("custom operator validator cannot be nil")
+		panic(ErrNilValidator.Error())
+	}
+	RegisterCustomOperatorE(operator, func(fieldValue, expectedValue interface{}) (bool, error) {
+		return validator(fieldValue, expectedValue), nil
+	})
+}
+
+// RegisterCustomOperatorE registers a custom operator whose validator can return
+// an error. Errors propagate through EvaluateConditionE so operator authors can
+// distinguish "not satisfied" from "couldn't evaluate".
+//
+// Example:
+//
+//	RegisterCustomOperatorE("regex", func(fieldValue, expectedValue interface{}) (bool, error) {
+//	    re, err := regexp.Compile(fmt.Sprintf("%v", expectedValue))
+//	    if err != nil {
+//	        return false, err
+//	    }
+//	    return re.MatchString(fmt.Sprintf("%v", fieldValue)), nil
+//	})
+func RegisterCustomOperatorE(operator Operator, validator CustomOperatorValidatorE) {
+	if err := RegisterCustomOperatorFunc(operator, validator); err != nil {
+		panic(err.Error())
+	}
+}
+
+// Errors returned by RegisterCustomOperatorFunc.
+var (
+	ErrNilValidator    = errors.New("custom operator validator cannot be nil")
+	ErrBuiltinOperator = errors.New("custom operator conflicts with a built-in operator")
+	ErrOperatorExists  = errors.New("custom operator is already registered")
+)
+
+// RegisterOption configures RegisterCustomOperatorFunc and
+// RegisterCustomOperatorField.
+type RegisterOption func(*registerOptions)
+
+// registerOptions holds the settings applied by RegisterOptions
+type registerOptions struct {
+	allowOverride bool
+	checkValue    CustomOperatorValueCheck
+}
+
+// WithAllowOverride sets whether registering an operator that is already
+// registered replaces it (the default) or fails with ErrOperatorExists.
+// Disallowing overrides makes collisions between independently loaded
+// operator sets visible.
+func WithAllowOverride(allow bool) RegisterOption {
+	return func(o *registerOptions) {
+		o.allowOverride = allow
+	}
+}
+
+// WithValueCheck sets a check of the expected value of conditions using the
+// operator, so that a value of the wrong shape is reported as ErrInvalidValue
+// by ValidateConditions and EvaluateConditionE instead of silently failing to
+// match.
+func WithValueCheck(check CustomOperatorValueCheck) RegisterOption {
+	return func(o *registerOptions) {
+		o.checkValue = check
+	}
+}
+
+// RegisterCustomOperatorFunc registers a custom operator like
+// RegisterCustomOperatorE, but returns an error instead of panicking:
+// ErrNilValidator, ErrBuiltinOperator, or, with WithAllowOverride(false),
+// ErrOperatorExists when the operator is already registered.
+//
+// Example:
+//
+//	err := RegisterCustomOperatorFunc("is_even", isEven, WithAllowOverride(false))
+//	if errors.Is(err, ErrOperatorExists) {
+//	    // another plugin registered "is_even" first
+//	}
+func RegisterCustomOperatorFunc(operator Operator, validator CustomOperatorValidatorE, opts ...RegisterOption) error {
+	if validator == nil {
+		return ErrNilValidator
+	}
+	return RegisterCustomOperatorField(operator, func(field FieldValue, expectedValue interface{}) (bool, error) {
+		return validator(field.Value, expectedValue)
+	}, opts...)
+}
+
+// RegisterCustomOperatorField registers a custom operator like
+// RegisterCustomOperatorFunc, with a validator that is told whether the field
+// exists in the data. This lets rules tell a field explicitly set to null
+// apart from a missing one.
+//
+// Example:
+//
+//	RegisterCustomOperatorField("explicit_null", func(field FieldValue, _ interface{}) (bool, error) {
+//	    return field.Exists && field.Value == nil, nil
+//	})
+func RegisterCustomOperatorField(operator Operator, validator CustomOperatorFieldValidator, opts ...RegisterOption) error {
+	options := registerOptions{allowOverride: true}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if validator == nil {
+		return ErrNilValidator
+	}
+	if IsBuiltinOperator(operator) {
+		return fmt.Errorf("%w: %q", ErrBuiltinOperator, operator)
 	}
 
 	customOpsMutex.Lock()
 	defer customOpsMutex.Unlock()
-	customOperators[operator] = validator
+	if _, exists := customOperators[operator]; exists && !options.allowOverride {
+		return fmt.Errorf("%w: %q", ErrOperatorExists, operator)
+	}
+	customOperators[operator] = customOperator{validator: validator, checkValue: options.checkValue}
+	return nil
+}
+
+// RegisterCustomOperatorWithValidation registers a custom operator like
+// RegisterCustomOperatorFunc, together with a check of the expected value of
+// conditions using it, as with WithValueCheck.
+//
+// Example:
+//
+//	RegisterCustomOperatorWithValidation("in_range", inRange, func(value interface{}) error {
+//	    if bounds, ok := value.([]interface{}); !ok || len(bounds) != 2 {
+//	        return errors.New("want [min, max]")
+//	    }
+//	    return nil
+//	})
+func RegisterCustomOperatorWithValidation(operator Operator, validator CustomOperatorValidatorE, checkValue CustomOperatorValueCheck, opts ...RegisterOption) error {
+	return RegisterCustomOperatorFunc(operator, validator, append(opts, WithValueCheck(checkValue))...)
+}
+
+// NegateOperator registers newName as the negation of the custom operator
+// existing, like the built-in twins nin and notbetween, so the inverse of an
+// operator never has to be written by hand. newName negates existing as
+// currently registered, including its value check; call NegateOperator again
+// after registering existing anew. It returns an error wrapping
+// ErrUnknownOperator if existing is not a registered custom operator, and the
+// errors of RegisterCustomOperatorField for newName.
+//
+// The negation is applied to the validator's result as is, so for a missing
+// field newName is true whenever existing is false. Errors are not negated:
+// if existing fails, newName fails too.
+//
+// Example:
+//
+//	RegisterCustomOperator("in_range", inRange)
+//	err := NegateOperator("in_range", "not_in_range")
+func NegateOperator(existing, newName Operator) error {
+	customOpsMutex.RLock()
+	custom, exists := customOperators[existing]
+	customOpsMutex.RUnlock()
+	if !exists {
+		return fmt.Errorf("%w: %q is not a registered custom operator", ErrUnknownOperator, existing)
+	}
+
+	return RegisterCustomOperatorField(newName, func(field FieldValue, expectedValue interface{}) (bool, error) {
+		result, err := custom.validator(field, expectedValue)
+		if err != nil {
+			return false, err
+		}
+		return !result, nil
+	}, WithValueCheck(custom.checkValue))
+}
+
+// checkCustomValue runs the value check of the custom operator op, if it is
+// one, on value
+func checkCustomValue(op Operator, value interface{}) error {
+	customOpsMutex.RLock()
+	custom, isCustom := customOperators[op]
+	customOpsMutex.RUnlock()
+	if !isCustom {
+		return nil
+	}
+	return custom.check(op, value)
+}
+
+// check runs the operator's value check, if any, on value, wrapping a failure
+// in ErrInvalidValue
+func (c customOperator) check(op Operator, value interface{}) error {
+	if c.checkValue == nil {
+		return nil
+	}
+	if err := c.checkValue(value); err != nil {
+		return fmt.Errorf("%w for operator %s: %w", ErrInvalidValue, op, err)
+	}
+	return nil
+}
+
+// HasCustomOperator reports whether a custom operator is registered under the
+// given name.
+func HasCustomOperator(operator Operator) bool {
+	customOpsMutex.RLock()
+	defer customOpsMutex.RUnlock()
+	_, exists := customOperators[operator]
+	return exists
 }
 
 // UnregisterCustomOperator removes a custom operator from the registry.
@@ -124,7 +632,8 @@ func UnregisterCustomOperator(operator Operator) {
 	delete(customOperators, operator)
 }
 
-// GetRegisteredCustomOperators returns a list of all registered custom operators.
+// GetRegisteredCustomOperators returns a list of all registered custom operators,
+// sorted lexicographically.
 func GetRegisteredCustomOperators() []Operator {
 	customOpsMutex.RLock()
 	defer customOpsMutex.RUnlock()
@@ -133,9 +642,49 @@ func GetRegisteredCustomOperators() []Operator {
 	for op := range customOperators {
 		operators = append(operators, op)
 	}
+	sort.Slice(operators, func(i, j int) bool { return operators[i] < operators[j] })
 	return operators
 }
 
+// OperatorSnapshot is a point-in-time copy of the custom operator registry,
+// taken with SnapshotOperators and applied with RestoreOperators.
+type OperatorSnapshot struct {
+	operators map[Operator]customOperator
+}
+
+// SnapshotOperators returns a copy of the currently registered custom operators.
+// Combined with RestoreOperators it lets tests isolate registry changes:
+//
+//	defer RestoreOperators(SnapshotOperators())
+func SnapshotOperators() OperatorSnapshot {
+	customOpsMutex.RLock()
+	defer customOpsMutex.RUnlock()
+
+	operators := make(map[Operator]customOperator, len(customOperators))
+	for op, custom := range customOperators {
+		operators[op] = custom
+	}
+	return OperatorSnapshot{operators: operators}
+}
+
+// RestoreOperators replaces the custom operator registry with the operators
+// recorded in snapshot, discarding any registered since.
+func RestoreOperators(snapshot OperatorSnapshot) {
+	operators := make(map[Operator]customOperator, len(snapshot.operators))
+	for op, custom := range snapshot.operators {
+		operators[op] = custom
+	}
+
+	customOpsMutex.Lock()
+	defer customOpsMutex.Unlock()
+	customOperators = operators
+}
+
+// ResetCustomOperators unregisters all custom operators.
+func ResetCustomOperators() {
+	RestoreOperators(OperatorSnapshot{})
+}
+
 // EvaluateCondition evaluates a condition tree against the provided data.
 // It returns true if the condition is satisfied, false otherwise.
 //
@@ -164,132 +713,420 @@ func GetRegisteredCustomOperators() []Operator {
 //
 //	result := EvaluateCondition(condition, data) // returns true
 func EvaluateCondition(cond Conditions, data map[string]interface{}) bool {
+	return defaultEvaluator.EvaluateCondition(cond, data)
+}
+
+// EvaluateConditionE evaluates a condition tree like EvaluateCondition, but
+// reports errors raised by custom operators registered with
+// RegisterCustomOperatorE, as well as custom operator panics (wrapped in
+// ErrOperatorPanicked). Evaluation stops at the first error.
+func EvaluateConditionE(cond Conditions, data map[string]interface{}) (bool, error) {
+	return defaultEvaluator.EvaluateConditionE(cond, data)
+}
+
+// EvaluateConditionWith evaluates a condition tree like EvaluateCondition,
+// with ops available as custom operators for this call only. They take
+// precedence over registered custom operators of the same name, but not over
+// built-in operators, and are never visible to other evaluations, so one-off
+// rules need no RegisterCustomOperator/UnregisterCustomOperator pair.
+//
+// Example:
+//
+//	EvaluateConditionWith(NewSimpleCondition("sku", "sku_allowed", nil), data, map[Operator]CustomOperatorValidator{
+//	    "sku_allowed": func(fieldValue, _ interface{}) bool {
+//	        return allowed[fmt.Sprint(fieldValue)]
+//	    },
+//	})
+func EvaluateConditionWith(cond Conditions, data map[string]interface{}, ops map[Operator]CustomOperatorValidator) bool {
+	return defaultEvaluator.EvaluateConditionWith(cond, data, ops)
+}
+
+// EvaluateBatch evaluates a condition tree against each row of data, returning
+// the results in row order. Use an Evaluator with BatchWorkers set to evaluate
+// rows in parallel.
+func EvaluateBatch(cond Conditions, rows []map[string]interface{}) []bool {
+	return defaultEvaluator.EvaluateBatch(cond, rows)
+}
+
+// EvaluateAny reports whether the condition tree is satisfied by at least one
+// row, stopping at the first row that satisfies it. It is false for no rows.
+func EvaluateAny(cond Conditions, rows []map[string]interface{}) bool {
+	return defaultEvaluator.EvaluateAny(cond, rows)
+}
+
+// EvaluateAll reports whether the condition tree is satisfied by every row,
+// stopping at the first row that doesn't satisfy it. It is true for no rows.
+func EvaluateAll(cond Conditions, rows []map[string]interface{}) bool {
+	return defaultEvaluator.EvaluateAll(cond, rows)
+}
+
+// evalCondition walks the condition tree. Unless the evaluation is strict,
+// errors from single conditions are treated as false and evaluation continues.
+//
+// A node with Logic set is a group: an empty AND group is true and an empty OR
+// group is false. A NOT group is true when its child is false. An IMPLIES
+// group is true unless its first child is true and its second false. A node
+// with neither Logic nor Key/Operator set is empty and evaluates to the
+// Evaluator's EmptyResult.
+func (ev *evaluation) evalCondition(cond Conditions) (bool, error) {
 	// Handle group conditions (AND/OR logic)
-	if cond.Logic != "" && len(cond.Children) > 0 {
+	if cond.Logic != "" {
+		if err := ev.enterGroup(); err != nil {
+			return false, err
+		}
+		defer ev.leaveGroup()
+
 		switch cond.Logic {
 		case LogicAnd:
 			for _, child := range cond.Children {
-				if !EvaluateCondition(child, data) {
-					return false
+				result, err := ev.evalCondition(child)
+				if err != nil {
+					return false, err
+				}
+				if !result {
+					return false, nil
 				}
 			}
-			return true
+			return true, nil
 		case LogicOr:
 			for _, child := range cond.Children {
-				if EvaluateCondition(child, data) {
-					return true
+				result, err := ev.evalCondition(child)
+				if err != nil {
+					return false, err
+				}
+				if result {
+					return true, nil
 				}
 			}
-			return false
+			return false, nil
+		case LogicNot:
+			// NOT negates the conjunction of its children; ValidateConditions
+			// requires exactly one child
+			for _, child := range cond.Children {
+				result, err := ev.evalCondition(child)
+				if err != nil {
+					return false, err
+				}
+				if !result {
+					return true, nil
+				}
+			}
+			return false, nil
+		case LogicAtLeast:
+			// Stop as soon as the threshold is reached or can no longer be reached
+			passed := 0
+			for i, child := range cond.Children {
+				if passed >= cond.Threshold || passed+len(cond.Children)-i < cond.Threshold {
+					break
+				}
+				result, err := ev.evalCondition(child)
+				if err != nil {
+					return false, err
+				}
+				if result {
+					passed++
+				}
+			}
+			return passed >= cond.Threshold, nil
+		case LogicImplies:
+			// ValidateConditions requires exactly an antecedent and a consequent
+			if len(cond.Children) != 2 {
+				return false, nil
+			}
+			antecedent, err := ev.evalCondition(cond.Children[0])
+			if err != nil || !antecedent {
+				return !antecedent, err
+			}
+			return ev.evalCondition(cond.Children[1])
+		default:
+			if ev.strict {
+				return false, fmt.Errorf("%w %q", ErrUnknownLogic, cond.Logic)
+			}
+			return false, nil
 		}
 	}
 
 	// Handle single conditions
 	if cond.Key != "" && cond.Operator != "" {
-		return evalSingleCondition(cond.Key, cond.Operator, cond.Value, data)
+		result, err := ev.evalSingle(cond.Key, cond.Operator, cond.Value, cond.Default)
+		if err != nil && !ev.strict {
+			return false, nil
+		}
+		return result, err
 	}
 
 	// Default case for empty conditions
-	return true
+	return ev.EmptyResult, nil
 }
 
 // evalSingleCondition evaluates a single condition against the data
 func evalSingleCondition(key string, op Operator, value interface{}, data map[string]interface{}) bool {
-	v, exists := data[key]
+	result, err := defaultEvaluator.newEvaluation(data, false).evalSingle(key, op, value, nil)
+	return err == nil && result
+}
+
+// evalSingle evaluates a single condition against the data, reporting errors
+// from custom operators. def, when not nil, replaces a missing field value.
+// The OnEvaluate callback, when set, is invoked with the outcome.
+func (ev *evaluation) evalSingle(key string, op Operator, value, def interface{}) (bool, error) {
+	if ev.OnEvaluate == nil {
+		return ev.evalLeaf(key, op, value, def)
+	}
+	start := time.Now()
+	result, err := ev.evalLeaf(key, op, value, def)
+	ev.OnEvaluate(key, op, err == nil && result, time.Since(start))
+	return result, err
+}
+
+// evalLeaf looks up the field for a single condition and applies its operator.
+func (ev *evaluation) evalLeaf(key string, op Operator, value, def interface{}) (bool, error) {
+	if !ev.allowsOperator(op) {
+		return false, fmt.Errorf("%w %q", ErrOperatorNotAllowed, op)
+	}
+	v, exists := ev.lookup(key)
+	if !exists && def != nil {
+		v, exists = def, true
+	}
+	if op == OperatorSubmatch {
+		// submatch needs the key to detect rules that refer to themselves
+		return ev.submatch(key, deref(v), exists)
+	}
+	return ev.evalOperator(op, deref(v), exists, value)
+}
+
+// evalOperator applies op to the field value v, which exists tells whether the
+// field was present in the data, and the expected value
+func (ev *evaluation) evalOperator(op Operator, v interface{}, exists bool, value interface{}) (bool, error) {
+	if !ev.allowsOperator(op) {
+		// Also covers operators nested in the value of another operator
+		return false, fmt.Errorf("%w %q", ErrOperatorNotAllowed, op)
+	}
+	value, err := ev.resolveValue(value)
+	if errors.Is(err, errMissingRef) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
 
 	switch op {
 	case OperatorIsnull:
-		return !exists || v == nil
+		return !exists || v == nil, nil
 	case OperatorIsnotnull:
-		return exists && v != nil
+		return exists && v != nil, nil
 	case OperatorIsEmpty:
-		return isEmpty(v)
+		return ev.isEmpty(v), nil
 	case OperatorIsNotEmpty:
-		return !isEmpty(v)
+		return !ev.isEmpty(v), nil
 	case OperatorIsTrue:
-		return toBool(v)
+		return toBool(v), nil
 	case OperatorIsFalse:
-		return !toBool(v)
+		return !toBool(v), nil
+	case OperatorIsPositive, OperatorIsNegative, OperatorIsZero:
+		return hasSign(v, op), nil
+	case OperatorIsInteger:
+		return isInteger(v), nil
+	case OperatorAnyOp:
+		// The alternatives decide how to treat a missing field
+		return ev.anyOp(v, exists, value)
 	}
 
 	// For other built-in operators, the key must exist
 	if !exists {
 		// Check if this is a custom operator first
-		customOpsMutex.RLock()
-		validator, isCustom := customOperators[op]
-		customOpsMutex.RUnlock()
-
-		if isCustom {
-			// Handle panics in custom operators gracefully
-			defer func() {
-				if r := recover(); r != nil {
-					// Custom operator panicked, return false
-				}
-			}()
-			return validator(v, value) // v will be nil for missing keys
+		if custom, isCustom := ev.customOperator(op); isCustom {
+			return callCustomOperator(op, custom, FieldValue{Value: v}, value) // v will be nil for missing keys
 		}
 
-		return false
+		return false, nil
 	}
 
 	switch op {
 	case OperatorEq:
-		return isEqual(v, value)
+		return ev.isEqual(v, value), nil
 	case OperatorNeq:
-		return !isEqual(v, value)
-	case OperatorGt:
-		return compareValues(v, value) > 0
-	case OperatorGte:
-		return compareValues(v, value) >= 0
-	case OperatorLt:
-		return compareValues(v, value) < 0
-	case OperatorLte:
-		return compareValues(v, value) <= 0
+		return !ev.isEqual(v, value), nil
+	case OperatorGt, OperatorGte, OperatorLt, OperatorLte:
+		return ev.compareOrdered(op, v, value)
 	case OperatorIn:
-		return isIn(v, value)
+		return ev.isIn(v, value), nil
 	case OperatorNin:
-		return !isIn(v, value)
+		return !ev.isIn(v, value), nil
+	case OperatorKeyIn:
+		return ev.keyIn(v, value), nil
+	case OperatorInString:
+		return inString(v, value), nil
 	case OperatorContains:
-		return contains(v, value)
+		return ev.contains(op, v, value)
 	case OperatorNcontains:
-		return !contains(v, value)
+		result, err := ev.contains(op, v, value)
+		return err == nil && !result, err
+	case OperatorStrContains:
+		return contains(v, value), nil
+	case OperatorFuzzyEq:
+		return fuzzyEq(v, value), nil
 	case OperatorLike:
-		return like(v, value, false)
+		return like(v, value, false), nil
 	case OperatorIlike:
-		return like(v, value, true)
+		return like(v, value, true), nil
 	case OperatorNlike:
-		return !like(v, value, false)
+		return !like(v, value, false), nil
+	case OperatorLikeAny:
+		return likeAny(v, value), nil
+	case OperatorLikeAll:
+		return likeAll(v, value), nil
 	case OperatorStartsWith:
-		return startsWith(v, value)
+		return startsWith(v, value), nil
+	case OperatorIndexOf:
+		return indexOf(v, value), nil
 	case OperatorEndsWith:
-		return endsWith(v, value)
+		return endsWith(v, value), nil
+	case OperatorPrefixIn:
+		return matchesAny(startsWith, v, value), nil
+	case OperatorSuffixIn:
+		return matchesAny(endsWith, v, value), nil
 	case OperatorBetween:
-		return between(v, value)
+		return ev.between(v, value), nil
 	case OperatorNotBetween:
-		return !between(v, value)
+		return !ev.between(v, value), nil
+	case OperatorCount:
+		return countIs(v, value), nil
+	case OperatorLengthBetween:
+		return lengthBetween(v, value), nil
+	case OperatorIsUnique:
+		return ev.isUnique(v), nil
+	case OperatorWithinPct:
+		return withinPct(v, value), nil
+	case OperatorStep:
+		return onStep(v, value), nil
+	case OperatorBucketIs:
+		return ev.bucketIs(v, value), nil
+	case OperatorPctBetween:
+		return ev.pctBetween(v, value), nil
+	case OperatorBitSet, OperatorBitClear:
+		return hasBits(v, op, value), nil
+	case OperatorAny, OperatorAll:
+		return ev.quantify(v, op, value)
+	case OperatorRegexExtract:
+		return ev.regexExtract(v, value)
+	case OperatorCategoryIs:
+		return ev.categoryIs(v, value), nil
+	case OperatorSuperset, OperatorSubset, OperatorSetEq:
+		return compareSets(v, op, value), nil
+	case OperatorSetContains:
+		return setContains(v, value), nil
+	case OperatorSemverEq, OperatorSemverGt, OperatorSemverGte, OperatorSemverLt, OperatorSemverLte:
+		return compareSemver(v, op, value), nil
+	case OperatorTypeIs:
+		return typeIs(v, value), nil
+	case OperatorMatchesSchema:
+		return matchesSchema(v, value), nil
+	case OperatorJSONEq:
+		return jsonEqual(v, value), nil
+	case OperatorRankGt, OperatorRankGte, OperatorRankLt, OperatorRankLte:
+		return compareRank(v, op, value), nil
+	case OperatorIsCountryCode:
+		return isCode(v, countryCodes), nil
+	case OperatorIsCurrencyCode:
+		return isCode(v, currencyCodes), nil
+	case OperatorIsFormat:
+		return isFormat(v, value)
+	case OperatorIPInCIDR:
+		return ipInCIDR(v, value), nil
+	case OperatorYearEq, OperatorMonthEq, OperatorDayEq, OperatorWeekdayEq:
+		return datePartEq(v, op, value), nil
+	case OperatorWithin:
+		return ev.within(v, value), nil
+	case OperatorOlderThan:
+		return ev.olderThan(v, value), nil
+	case OperatorTimeWithin:
+		return timeWithin(v, value), nil
+	case OperatorInSchedule:
+		return inSchedule(v, value), nil
+	case OperatorIsDate, OperatorIsDateTime:
+		return isDate(v, op, value), nil
 	default:
 		// Check for custom operators
-		customOpsMutex.RLock()
-		validator, exists := customOperators[op]
-		customOpsMutex.RUnlock()
-
-		if exists {
-			// Handle panics in custom operators gracefully
-			defer func() {
-				if r := recover(); r != nil {
-					// Custom operator panicked, return false
-				}
-			}()
-			return validator(v, value)
+		if custom, exists := ev.customOperator(op); exists {
+			return callCustomOperator(op, custom, FieldValue{Value: v, Exists: true}, value)
 		}
 
-		return false
+		return false, nil
+	}
+}
+
+// customOperator returns the custom operator op, preferring an operator
+// scoped to the evaluation over the registry
+func (ev *evaluation) customOperator(op Operator) (customOperator, bool) {
+	if validator := ev.ops[op]; validator != nil {
+		return customOperator{validator: func(field FieldValue, expectedValue interface{}) (bool, error) {
+			return validator(field.Value, expectedValue), nil
+		}}, true
 	}
+	customOpsMutex.RLock()
+	defer customOpsMutex.RUnlock()
+	custom, exists := customOperators[op]
+	return custom, exists
+}
+
+// callCustomOperator invokes a custom operator, converting a panic into an
+// ErrOperatorPanicked error and a false result. A value that fails the
+// operator's value check is reported as ErrInvalidValue without calling it.
+func callCustomOperator(op Operator, custom customOperator, field FieldValue, value interface{}) (result bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = false
+			err = fmt.Errorf("%w: %s: %v", ErrOperatorPanicked, op, r)
+		}
+	}()
+	if err := custom.check(op, value); err != nil {
+		return false, err
+	}
+	result, err = custom.validator(field, value)
+	if err != nil {
+		return false, fmt.Errorf("operator %s: %w", op, err)
+	}
+	return result, nil
 }
 
 // Helper functions
 
-// isEmpty checks if a value is considered empty
+// isEmpty checks if a value is considered empty, honoring the TrimEmpty and
+// ZeroIsEmpty options
+func (ev *evaluation) isEmpty(v interface{}) bool {
+	if str, ok := v.(string); ok && ev.TrimEmpty {
+		return strings.TrimSpace(str) == ""
+	}
+	if ev.ZeroIsEmpty && isZero(v) {
+		return true
+	}
+	return isEmpty(v)
+}
+
+// isZero checks if v is a number equal to zero, false or the zero time.Time.
+// Strings, including "0" and "false", are never zero.
+func isZero(v interface{}) bool {
+	switch val := v.(type) {
+	case time.Time:
+		return val.IsZero()
+	case json.Number:
+		n, err := val.Float64()
+		return err == nil && n == 0
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Bool:
+		return !rv.Bool()
+	case reflect.String:
+		return false
+	}
+	n, ok := toNumber(v)
+	return ok && n == 0
+}
+
+// isEmpty checks if a value is considered empty: nil, an empty string, or an
+// empty array, slice, map or channel. Numbers and booleans are never empty.
 func isEmpty(v interface{}) bool {
 	if v == nil {
 		return true
@@ -308,6 +1145,137 @@ func isEmpty(v interface{}) bool {
 	}
 }
 
+// truthyStrings are the strings, compared case-insensitively after trimming
+// whitespace, that toBool treats as true. Any other string, including
+// "false", "0", "no" and "off", is false.
+var truthyStrings = map[string]bool{
+	"true": true,
+	"1":    true,
+	"yes":  true,
+	"on":   true,
+	"t":    true,
+	"y":    true,
+}
+
+// falsyStrings are the strings, compared like truthyStrings, that are read as
+// false when a boolean is compared with a string. Together with truthyStrings
+// they are the strings that can equal a boolean.
+var falsyStrings = map[string]bool{
+	"false": true,
+	"0":     true,
+	"no":    true,
+	"off":   true,
+	"f":     true,
+	"n":     true,
+}
+
+// toBoolStrict reads v as a boolean if it is a bool or a truthy or falsy
+// string. Other values, including numbers, are not booleans.
+func toBoolStrict(v interface{}) (value bool, ok bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Bool:
+		return rv.Bool(), true
+	case reflect.String:
+		s := strings.ToLower(strings.TrimSpace(rv.String()))
+		if truthyStrings[s] {
+			return true, true
+		}
+		return false, falsyStrings[s]
+	}
+	return false, false
+}
+
+// isBool reports whether v is a boolean value
+func isBool(v interface{}) bool {
+	return v != nil && reflect.TypeOf(v).Kind() == reflect.Bool
+}
+
+// compareOrdered applies the ordering operator op to v1 and v2. With the
+// StrictCompare option set, operands that aren't both numbers, times, strings
+// or booleans are reported as ErrIncomparable instead of being compared as
+// strings.
+func (ev *evaluation) compareOrdered(op Operator, v1, v2 interface{}) (bool, error) {
+	if !isOrdered(v1, v2) || isNaN(v1) || isNaN(v2) {
+		return false, nil
+	}
+	if ev.StrictCompare && !isComparable(v1, v2) {
+		return false, fmt.Errorf("operator %s: %w: %T and %T", op, ErrIncomparable, v1, v2)
+	}
+
+	c := compareValues(v1, v2)
+	switch op {
+	case OperatorGt:
+		return c > 0, nil
+	case OperatorGte:
+		return c >= 0, nil
+	case OperatorLt:
+		return c < 0, nil
+	case OperatorLte:
+		return c <= 0, nil
+	default:
+		return false, nil
+	}
+}
+
+// isComparable reports whether v1 and v2 are both numbers (including numeric
+// strings), both durations, both times, both strings or both booleans, so
+// that ordering them is meaningful
+func isComparable(v1, v2 interface{}) bool {
+	if isBool(v1) || isBool(v2) {
+		_, ok1 := toBoolStrict(v1)
+		_, ok2 := toBoolStrict(v2)
+		return ok1 && ok2
+	}
+	if _, ok := compareDurations(v1, v2); ok {
+		return true
+	}
+	if _, ok1 := toNumber(v1); ok1 {
+		_, ok2 := toNumber(v2)
+		return ok2
+	}
+	if _, ok2 := toNumber(v2); ok2 {
+		return false
+	}
+	if _, ok1 := toTime(v1); ok1 {
+		_, ok2 := toTime(v2)
+		return ok2
+	}
+	if _, ok2 := toTime(v2); ok2 {
+		return false
+	}
+	return isString(v1) && isString(v2)
+}
+
+// isString reports whether v is a string, or a byte slice holding text
+func isString(v interface{}) bool {
+	switch v.(type) {
+	case []byte, json.RawMessage:
+		return true
+	}
+	return v != nil && reflect.TypeOf(v).Kind() == reflect.String
+}
+
+// isOrdered reports whether v1 and v2 can be compared by the ordering
+// operators. A boolean is only ordered against another boolean, or a truthy or
+// falsy string, with false before true. A time.Time, such as the current time
+// given by Now(), is only ordered against another time.
+func isOrdered(v1, v2 interface{}) bool {
+	_, isTime1 := v1.(time.Time)
+	_, isTime2 := v2.(time.Time)
+	if isTime1 || isTime2 {
+		_, ok1 := toTime(v1)
+		_, ok2 := toTime(v2)
+		return ok1 && ok2
+	}
+	if !isBool(v1) && !isBool(v2) {
+		return true
+	}
+	_, ok1 := toBoolStrict(v1)
+	_, ok2 := toBoolStrict(v2)
+	return ok1 && ok2
+}
+
 // toBool converts various types to boolean
 func toBool(v interface{}) bool {
 	if v == nil {
@@ -318,7 +1286,7 @@ func toBool(v interface{}) bool {
 	case bool:
 		return val
 	case string:
-		return strings.ToLower(val) == "true"
+		return truthyStrings[strings.ToLower(strings.TrimSpace(val))]
 	case int, int8, int16, int32, int64:
 		return reflect.ValueOf(val).Int() != 0
 	case uint, uint8, uint16, uint32, uint64:
@@ -330,12 +1298,110 @@ func toBool(v interface{}) bool {
 	}
 }
 
+// hasSign checks the sign of a numeric value for the ispositive, isnegative
+// and iszero operators. Non-numeric values have no sign.
+func hasSign(v interface{}, op Operator) bool {
+	n, ok := toNumber(v)
+	if !ok {
+		return false
+	}
+	switch op {
+	case OperatorIsPositive:
+		return n > 0
+	case OperatorIsNegative:
+		return n < 0
+	case OperatorIsZero:
+		return n == 0
+	default:
+		return false
+	}
+}
+
+// isEqual checks equality between two values, treating numbers within the
+// FloatTolerance option of each other as equal
+func (ev *evaluation) isEqual(v1, v2 interface{}) bool {
+	if ev.FloatTolerance > 0 {
+		if n1, ok1 := toNumber(v1); ok1 {
+			if n2, ok2 := toNumber(v2); ok2 {
+				return n1 == n2 || math.Abs(n1-n2) <= ev.FloatTolerance
+			}
+		}
+	}
+	return ev.equals(v1, v2)
+}
+
+// equals checks equality between two values like isEqual, honoring the
+// NoStringNumberCoercion option
+func (ev *evaluation) equals(v1, v2 interface{}) bool {
+	if ev.NoStringNumberCoercion {
+		if equal, ok := equalWithoutCoercion(v1, v2); ok {
+			return equal
+		}
+	}
+	return isEqual(v1, v2)
+}
+
+// equalWithoutCoercion compares v1 and v2 without reading strings as
+// numbers: a string never equals a number and two strings are compared as
+// text, or as durations when both are duration strings. It reports false for
+// ok when neither value is a string, leaving the comparison to isEqual.
+func equalWithoutCoercion(v1, v2 interface{}) (equal, ok bool) {
+	text1, text2 := isText(v1), isText(v2)
+	switch {
+	case text1 && text2:
+		if c, ok := compareDurations(v1, v2); ok {
+			return c == 0, true
+		}
+		return toString(v1) == toString(v2), true
+	case text1:
+		if _, isNumber := toNumber(v2); isNumber {
+			return false, true
+		}
+	case text2:
+		if _, isNumber := toNumber(v1); isNumber {
+			return false, true
+		}
+	}
+	return false, false
+}
+
+// isText checks if v holds text: a string or byte slice, but not a
+// json.Number
+func isText(v interface{}) bool {
+	if _, isNumber := v.(json.Number); isNumber {
+		return false
+	}
+	return isString(v)
+}
+
 // isEqual checks equality between two values
 func isEqual(v1, v2 interface{}) bool {
+	// Fast path for operands of the same common type, skipping reflection.
+	// Different strings may still be equal durations or numbers, such as
+	// "1h" and "60m" or "1.0" and "1", so only a match returns early.
+	switch a := v1.(type) {
+	case string:
+		if b, ok := v2.(string); ok && a == b {
+			return true
+		}
+	case int:
+		if b, ok := v2.(int); ok {
+			return a == b
+		}
+	case float64:
+		if b, ok := v2.(float64); ok {
+			return a == b
+		}
+	case bool:
+		if b, ok := v2.(bool); ok {
+			return a == b
+		}
+	}
+
 	if v1 == nil && v2 == nil {
 		return true
 	}
-	if v1 == nil || v2 == nil {
+	if v1 == nil || v2 == nil || isNaN(v1) || isNaN(v2) {
 		return false
 	}
 
@@ -344,11 +1410,21 @@ func isEqual(v1, v2 interface{}) bool {
 		return true
 	}
 
+	// A boolean equals a boolean, or a truthy or falsy string, of the same value
+	if isBool(v1) || isBool(v2) {
+		b1, ok1 := toBoolStrict(v1)
+		b2, ok2 := toBoolStrict(v2)
+		return ok1 && ok2 && b1 == b2
+	}
+
+	// Try duration comparison, so "1h" equals "60m"
+	if c, ok := compareDurations(v1, v2); ok {
+		return c == 0
+	}
+
 	// Try numeric comparison
-	if n1, ok1 := toNumber(v1); ok1 {
-		if n2, ok2 := toNumber(v2); ok2 {
-			return n1 == n2
-		}
+	if c, ok := compareNumbers(v1, v2); ok {
+		return c == 0
 	}
 
 	// Try string comparison
@@ -358,32 +1434,42 @@ func isEqual(v1, v2 interface{}) bool {
 // compareValues compares two values and returns -1, 0, or 1
 func compareValues(v1, v2 interface{}) int {
 
-	// Try numeric comparison first
-	if n1, ok1 := toNumber(v1); ok1 {
-		if n2, ok2 := toNumber(v2); ok2 {
-			if n1 < n2 {
+	// Booleans order false before true
+	if isBool(v1) || isBool(v2) {
+		b1, ok1 := toBoolStrict(v1)
+		b2, ok2 := toBoolStrict(v2)
+		if ok1 && ok2 {
+			switch {
+			case b1 == b2:
+				return 0
+			case b2:
 				return -1
-			} else if n1 > n2 {
+			default:
 				return 1
 			}
-			return 0
 		}
 	}
 
+	// Try duration comparison, so "1h" is after "30m"
+	if c, ok := compareDurations(v1, v2); ok {
+		return c
+	}
+
+	// Try numeric comparison
+	if c, ok := compareNumbers(v1, v2); ok {
+		return c
+	}
+
 	// Try time comparison
 	if t1, ok1 := toTime(v1); ok1 {
 		if t2, ok2 := toTime(v2); ok2 {
-			// Debug output
-
 			if t1.Before(t2) {
 				return -1
 			} else if t1.After(t2) {
 				return 1
 			}
 			return 0
-		} else {
 		}
-	} else {
 	}
 
 	// Fall back to string comparison
@@ -396,6 +1482,83 @@ func compareValues(v1, v2 interface{}) int {
 	return 0
 }
 
+// compareDurations compares two durations, each a time.Duration or a duration
+// string such as "1h30m", and returns -1, 0, or 1. Bare numbers are not
+// durations, so comparing "30m" with 30 is not a duration comparison.
+func compareDurations(v1, v2 interface{}) (int, bool) {
+	d1, ok1 := toDuration(v1)
+	d2, ok2 := toDuration(v2)
+	if !ok1 || !ok2 {
+		return 0, false
+	}
+	if d1 < d2 {
+		return -1, true
+	} else if d1 > d2 {
+		return 1, true
+	}
+	return 0, true
+}
+
+// compareNumbers compares two numeric values and returns -1, 0, or 1.
+// Integers of any width are compared exactly, so values beyond float64
+// precision do not collide; other numbers are compared as float64. NaN is
+// unordered, so it is reported as not comparable.
+func compareNumbers(v1, v2 interface{}) (int, bool) {
+	if neg1, mag1, ok1 := toInteger(v1); ok1 {
+		if neg2, mag2, ok2 := toInteger(v2); ok2 {
+			switch {
+			case neg1 != neg2 && neg1:
+				return -1, true
+			case neg1 != neg2:
+				return 1, true
+			case mag1 == mag2:
+				return 0, true
+			case (mag1 < mag2) != neg1:
+				return -1, true
+			default:
+				return 1, true
+			}
+		}
+	}
+
+	n1, ok1 := toNumber(v1)
+	n2, ok2 := toNumber(v2)
+	if !ok1 || !ok2 || math.IsNaN(n1) || math.IsNaN(n2) {
+		return 0, false
+	}
+	if n1 < n2 {
+		return -1, true
+	} else if n1 > n2 {
+		return 1, true
+	}
+	return 0, true
+}
+
+// toInteger converts integer values, including named integer types, to a
+// sign and magnitude so integers of different widths can be compared exactly
+func toInteger(v interface{}) (negative bool, magnitude uint64, ok bool) {
+	if n, isNumber := v.(json.Number); isNumber {
+		// Integers decoded with json.Decoder.UseNumber keep their exact value
+		i, err := n.Int64()
+		if err != nil {
+			return false, 0, false
+		}
+		v = i
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := rv.Int()
+		if i < 0 {
+			return true, uint64(-(i + 1)) + 1, true
+		}
+		return false, uint64(i), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return false, rv.Uint(), true
+	}
+	return false, 0, false
+}
+
 // toNumber converts various types to float64
 func toNumber(v interface{}) (float64, bool) {
 	switch val := v.(type) {
@@ -420,13 +1583,34 @@ func toNumber(v interface{}) (float64, bool) {
 	case uint64:
 		return float64(val), true
 	case float32:
-		return float64(val), true
+		// Widen via the shortest decimal form, so float32(0.1) equals 0.1
+		// rather than 0.10000000149011612
+		f, _ := strconv.ParseFloat(strconv.FormatFloat(float64(val), 'g', -1, 32), 64)
+		return f, true
 	case float64:
 		return val, true
 	case string:
 		if f, err := parseFloat(val); err == nil {
 			return f, true
 		}
+	case json.Number:
+		// Numbers decoded with json.Decoder.UseNumber
+		if f, err := val.Float64(); err == nil {
+			return f, true
+		}
+	}
+
+	// Named numeric types, e.g. type Score int32
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32:
+		return toNumber(float32(rv.Float()))
+	case reflect.Float64:
+		return rv.Float(), true
 	}
 	return 0, false
 }
@@ -435,10 +1619,39 @@ func toNumber(v interface{}) (float64, bool) {
 func parseFloat(s string) (float64, error) {
 	// Use strconv.ParseFloat for proper validation
 	// This will only succeed if the entire string is a valid number
-	return strconv.ParseFloat(s, 64)
+	f, err := strconv.ParseFloat(s, 64)
+	if err == nil && (math.IsNaN(f) || math.IsInf(f, 0)) {
+		// "NaN" and "Inf" are text, such as the name "Nan", not numbers
+		return 0, strconv.ErrSyntax
+	}
+	return f, err
+}
+
+// isNaN checks if v is a floating-point NaN, which equals nothing, not even
+// itself, and is neither before nor after any value
+func isNaN(v interface{}) bool {
+	n, ok := toNumber(v)
+	return ok && math.IsNaN(n)
 }
 
-// toString converts any value to string
+// deref follows pointers to the value they point at, so a *int compares like
+// an int. A nil pointer is returned as nil and so behaves like null.
+func deref(v interface{}) interface{} {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr {
+		return v
+	}
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	return rv.Interface()
+}
+
+// toString converts any value to string. Byte slices, including
+// json.RawMessage, are converted to the text they hold.
 func toString(v interface{}) string {
 	if v == nil {
 		return ""
@@ -447,6 +1660,10 @@ func toString(v interface{}) string {
 	switch val := v.(type) {
 	case string:
 		return val
+	case []byte:
+		return string(val)
+	case json.RawMessage:
+		return string(val)
 	case fmt.Stringer:
 		return val.String()
 	default:
@@ -479,8 +1696,76 @@ func toTime(v interface{}) (time.Time, bool) {
 	return time.Time{}, false
 }
 
+// isIn checks if value is in the collection. With the InDelimiter option set,
+// a string collection is split into a list of elements instead of being
+// searched for a substring. With TrimIn set, whitespace around the value and
+// string elements is ignored.
+func (ev *evaluation) isIn(v, collection interface{}) bool {
+	equal := ev.equals
+	if ev.TrimIn {
+		v = trimString(v)
+		equal = func(v, element interface{}) bool {
+			return ev.equals(v, trimString(element))
+		}
+	}
+	if str, ok := collection.(string); ok && ev.InDelimiter != "" {
+		return isInFunc(v, splitList(str, ev.InDelimiter), equal)
+	}
+	return isInFunc(v, collection, equal)
+}
+
+// trimString removes surrounding whitespace from v if it is a string
+func trimString(v interface{}) interface{} {
+	if str, ok := v.(string); ok {
+		return strings.TrimSpace(str)
+	}
+	return v
+}
+
+// keyIn checks if v equals one of the keys of the map m. Unlike isIn it
+// never looks at elements, so any other m matches nothing.
+func (ev *evaluation) keyIn(v, m interface{}) bool {
+	mv := reflect.ValueOf(m)
+	if mv.Kind() != reflect.Map {
+		return false
+	}
+	for _, key := range mv.MapKeys() {
+		if ev.equals(v, key.Interface()) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitList splits s on sep, trimming whitespace around each element and
+// dropping empty elements
+func splitList(s, sep string) []string {
+	parts := strings.Split(s, sep)
+	list := parts[:0]
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			list = append(list, part)
+		}
+	}
+	return list
+}
+
+// inString checks if v is a substring of the string s
+func inString(v, s interface{}) bool {
+	if v == nil || s == nil {
+		return false
+	}
+	return strings.Contains(toString(s), toString(v))
+}
+
 // isIn checks if value is in the collection
 func isIn(v, collection interface{}) bool {
+	return isInFunc(v, collection, isEqual)
+}
+
+// isInFunc checks if value is in the collection, comparing elements, with
+// pointers dereferenced, with equal
+func isInFunc(v, collection interface{}, equal func(v1, v2 interface{}) bool) bool {
 	if collection == nil {
 		return false
 	}
@@ -489,13 +1774,13 @@ func isIn(v, collection interface{}) bool {
 	switch cv.Kind() {
 	case reflect.Array, reflect.Slice:
 		for i := 0; i < cv.Len(); i++ {
-			if isEqual(v, cv.Index(i).Interface()) {
+			if equal(v, deref(cv.Index(i).Interface())) {
 				return true
 			}
 		}
 	case reflect.Map:
 		for _, key := range cv.MapKeys() {
-			if isEqual(v, key.Interface()) {
+			if equal(v, key.Interface()) {
 				return true
 			}
 		}
@@ -505,7 +1790,26 @@ func isIn(v, collection interface{}) bool {
 	return false
 }
 
-// contains checks if haystack contains needle
+// contains checks if v contains needle, honoring the StrictContains option
+func (ev *evaluation) contains(op Operator, v, needle interface{}) (bool, error) {
+	if !ev.StrictContains || v == nil {
+		return contains(v, needle), nil
+	}
+	switch v.(type) {
+	case []byte, json.RawMessage:
+		// Byte slices hold text
+		return contains(v, needle), nil
+	}
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.String:
+		return contains(v, needle), nil
+	case reflect.Slice, reflect.Array:
+		return isInFunc(needle, v, ev.equals), nil
+	}
+	return false, fmt.Errorf("operator %s: %w: %T", op, ErrNotContainer, v)
+}
+
+// contains checks if the text of haystack contains the text of needle
 func contains(haystack, needle interface{}) bool {
 	if haystack == nil || needle == nil {
 		return false
@@ -530,17 +1834,60 @@ func like(v, pattern interface{}, caseInsensitive bool) bool {
 		pat = strings.ToLower(pat)
 	}
 
-	// Convert SQL LIKE pattern to regex
-	// % matches any sequence of characters
-	// _ matches any single character
-	regexPattern := strings.ReplaceAll(pat, "%", ".*")
-	regexPattern = strings.ReplaceAll(regexPattern, "_", ".")
-	regexPattern = "^" + regexPattern + "$"
-
-	matched, err := regexp.MatchString(regexPattern, str)
+	matched, err := regexp.MatchString(likeToRegexp(pat), str)
 	return err == nil && matched
 }
 
+// likeToRegexp converts a SQL LIKE pattern to an anchored regular expression.
+// % matches any sequence of characters and _ matches any single character;
+// every other character matches itself.
+func likeToRegexp(pattern string) string {
+	var b strings.Builder
+	b.WriteString("(?s)^")
+	for _, part := range strings.SplitAfter(pattern, "") {
+		switch part {
+		case "%":
+			b.WriteString(".*")
+		case "_":
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(part))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// likeAny checks if v matches any of the LIKE patterns. patterns may be a
+// single pattern or a slice of patterns; an empty slice matches nothing.
+func likeAny(v, patterns interface{}) bool {
+	pv := reflect.ValueOf(patterns)
+	if pv.Kind() != reflect.Slice && pv.Kind() != reflect.Array {
+		return like(v, patterns, false)
+	}
+	for i := 0; i < pv.Len(); i++ {
+		if like(v, pv.Index(i).Interface(), false) {
+			return true
+		}
+	}
+	return false
+}
+
+// likeAll checks if v matches every one of the LIKE patterns. patterns may be
+// a single pattern or a slice of patterns; an empty slice matches anything.
+func likeAll(v, patterns interface{}) bool {
+	pv := reflect.ValueOf(patterns)
+	if pv.Kind() != reflect.Slice && pv.Kind() != reflect.Array {
+		return like(v, patterns, false)
+	}
+	for i := 0; i < pv.Len(); i++ {
+		if !like(v, pv.Index(i).Interface(), false) {
+			return false
+		}
+	}
+	return true
+}
+
 // startsWith checks if string starts with prefix
 func startsWith(v, prefix interface{}) bool {
 	if v == nil || prefix == nil {
@@ -552,6 +1899,22 @@ func startsWith(v, prefix interface{}) bool {
 	return strings.HasPrefix(str, pre)
 }
 
+// indexOf checks where a substring first occurs in the string v. spec is
+// [substring, index], where index is a byte offset as returned by
+// strings.Index, or -1 for a substring that must not occur.
+func indexOf(v, spec interface{}) bool {
+	sv := reflect.ValueOf(spec)
+	if v == nil || sv.Kind() != reflect.Slice || sv.Len() != 2 {
+		return false
+	}
+	substr := sv.Index(0).Interface()
+	index, ok := toInt(sv.Index(1).Interface())
+	if substr == nil || !ok {
+		return false
+	}
+	return strings.Index(toString(v), toString(substr)) == index
+}
+
 // endsWith checks if string ends with suffix
 func endsWith(v, suffix interface{}) bool {
 	if v == nil || suffix == nil {
@@ -563,22 +1926,55 @@ func endsWith(v, suffix interface{}) bool {
 	return strings.HasSuffix(str, suf)
 }
 
-// between checks if value is between two bounds (inclusive)
-func between(v, bounds interface{}) bool {
-	if v == nil || bounds == nil {
+// matchesAny checks if match(v, pattern) holds for at least one of patterns,
+// which may be a single pattern or a slice of patterns. An empty slice
+// matches nothing.
+func matchesAny(match func(v, pattern interface{}) bool, v, patterns interface{}) bool {
+	pv := reflect.ValueOf(patterns)
+	if pv.Kind() != reflect.Slice && pv.Kind() != reflect.Array {
+		return match(v, patterns)
+	}
+	for i := 0; i < pv.Len(); i++ {
+		if match(v, pv.Index(i).Interface()) {
+			return true
+		}
+	}
+	return false
+}
+
+// between checks if value is between two bounds (inclusive). A nil bound is
+// open, so [100, nil] means >= 100. With the SortBetweenBounds option set,
+// [max, min] bounds are swapped into order.
+func (ev *evaluation) between(v, bounds interface{}) bool {
+	min, max, ok := betweenBounds(bounds)
+	if !ok || v == nil || isNaN(v) || isNaN(min) || isNaN(max) {
 		return false
 	}
+	if min == nil || max == nil {
+		return (min == nil || compareValues(v, min) >= 0) && (max == nil || compareValues(v, max) <= 0)
+	}
+	if _, isMap := bounds.(map[string]interface{}); (isMap || ev.SortBetweenBounds) && compareValues(min, max) > 0 {
+		min, max = max, min
+	}
+	return compareValues(v, min) >= 0 && compareValues(v, max) <= 0
+}
+
+// betweenBounds extracts the bounds of a between operator, given either as a
+// [min, max] slice or as a {"min": min, "max": max} map, dereferencing
+// pointer bounds
+func betweenBounds(bounds interface{}) (min, max interface{}, ok bool) {
+	if m, isMap := bounds.(map[string]interface{}); isMap {
+		min, hasMin := m["min"]
+		max, hasMax := m["max"]
+		return deref(min), deref(max), hasMin && hasMax
+	}
 
 	// bounds should be a slice with 2 elements [min, max]
 	boundsSlice := reflect.ValueOf(bounds)
 	if boundsSlice.Kind() != reflect.Slice || boundsSlice.Len() != 2 {
-		return false
+		return nil, nil, false
 	}
-
-	min := boundsSlice.Index(0).Interface()
-	max := boundsSlice.Index(1).Interface()
-
-	return compareValues(v, min) >= 0 && compareValues(v, max) <= 0
+	return deref(boundsSlice.Index(0).Interface()), deref(boundsSlice.Index(1).Interface()), true
 }
 
 // ConditionGroup represents a more flexible condition structure that allows
@@ -594,17 +1990,31 @@ type ConditionWithLogic struct {
 	Key      string      `json:"key,omitempty"`      // Field key for condition
 	Operator Operator    `json:"operator,omitempty"` // Comparison operator
 	Value    interface{} `json:"value,omitempty"`    // Expected value
+	Default  interface{} `json:"default,omitempty"`  // Field value to use when Key is missing
 
 	// Group condition (alternative to single condition)
 	Group *ConditionGroup `json:"group,omitempty"` // Nested group of conditions
 
+	// Not negates the result of this condition or group
+	Not bool `json:"not,omitempty"`
+
 	// Logic operator to connect to the next condition
 	NextLogic Logic `json:"next_logic,omitempty"` // "AND" or "OR" to connect to next condition
+
+	// Name and ID label the condition or group, as in Conditions, and are
+	// copied into its GroupStep when tracing. Evaluation ignores them.
+	Name string `json:"name,omitempty"`
+	ID   string `json:"id,omitempty"`
 }
 
 // EvaluateConditionGroup evaluates a ConditionGroup against the provided data.
 // This allows for more flexible logical expressions between conditions.
 //
+// Conditions are combined strictly from left to right without operator
+// precedence: "A OR B AND C" evaluates as "(A OR B) AND C", not as the
+// conventional "A OR (B AND C)". Use nested groups to make the intended
+// grouping explicit, or an Evaluator with HonorPrecedence set.
+//
 // Example usage:
 //
 //	group := ConditionGroup{
@@ -623,42 +2033,131 @@ type ConditionWithLogic struct {
 //	    },
 //	}
 func EvaluateConditionGroup(group ConditionGroup, data map[string]interface{}) bool {
+	return defaultEvaluator.EvaluateConditionGroup(group, data)
+}
+
+// EvaluateConditionGroupE evaluates a ConditionGroup like EvaluateConditionGroup,
+// but reports errors from custom operators and unknown NextLogic values.
+// Evaluation stops at the first error.
+func EvaluateConditionGroupE(group ConditionGroup, data map[string]interface{}) (bool, error) {
+	return defaultEvaluator.EvaluateConditionGroupE(group, data)
+}
+
+// evalGroup combines the group's conditions using each condition's NextLogic
+// to connect it to the following one. A missing NextLogic defaults to AND.
+//
+// By default the conditions are folded from left to right, so "A OR B AND C"
+// is "(A OR B) AND C". With the Evaluator's HonorPrecedence option AND binds
+// tighter than OR, giving "A OR (B AND C)".
+//
+// When trace is not nil, each step of the fold is recorded in it.
+func (ev *evaluation) evalGroup(group ConditionGroup, trace *GroupTrace) (bool, error) {
 	if len(group.Conditions) == 0 {
-		return true
+		if trace != nil {
+			trace.Result = true
+		}
+		return true, nil
 	}
+	if err := ev.enterGroup(); err != nil {
+		return false, err
+	}
+	defer ev.leaveGroup()
 
 	// Evaluate first condition
-	result := evaluateConditionWithLogic(group.Conditions[0], data)
+	result, err := ev.evalConditionWithLogic(group.Conditions[0], trace, "")
+	if err != nil {
+		return false, err
+	}
+
+	// With precedence, result holds the current run of AND-ed conditions and
+	// anyTerm whether any earlier run, separated by OR, was true
+	anyTerm := false
+	if trace != nil {
+		trace.Steps[0].Accumulator = result
+	}
 
 	// Process remaining conditions with their logic operators
 	for i := 1; i < len(group.Conditions); i++ {
 		prevCondition := group.Conditions[i-1]
-		currentResult := evaluateConditionWithLogic(group.Conditions[i], data)
+		logic := prevCondition.NextLogic
+		if logic != LogicOr {
+			// If no logic specified, default to AND
+			logic = LogicAnd
+		}
 
-		// Apply the logic operator from the previous condition
+		currentResult, err := ev.evalConditionWithLogic(group.Conditions[i], trace, logic)
+		if err != nil {
+			return false, err
+		}
 		switch prevCondition.NextLogic {
-		case LogicAnd:
-			result = result && currentResult
-		case LogicOr:
-			result = result || currentResult
+		case LogicAnd, LogicOr, "":
 		default:
-			// If no logic specified, default to AND
+			if ev.strict {
+				return false, fmt.Errorf("%w %q", ErrUnknownLogic, prevCondition.NextLogic)
+			}
+		}
+
+		// Apply the logic operator from the previous condition
+		if logic == LogicOr {
+			if ev.HonorPrecedence {
+				anyTerm = anyTerm || result
+				result = currentResult
+			} else {
+				result = result || currentResult
+			}
+		} else {
 			result = result && currentResult
 		}
+		if trace != nil {
+			trace.Steps[i].Accumulator = anyTerm || result
+		}
 	}
 
-	return result
+	if trace != nil {
+		trace.Result = anyTerm || result
+	}
+	return anyTerm || result, nil
 }
 
-// evaluateConditionWithLogic evaluates a single ConditionWithLogic
-func evaluateConditionWithLogic(condition ConditionWithLogic, data map[string]interface{}) bool {
-	// If it's a group condition, evaluate the group
+// evalConditionWithLogic evaluates a single ConditionWithLogic. When trace is
+// not nil, a step for the condition, combined with the preceding ones by
+// logic, is appended to it.
+func (ev *evaluation) evalConditionWithLogic(condition ConditionWithLogic, trace *GroupTrace, logic Logic) (bool, error) {
+	var step *GroupStep
+	if trace != nil {
+		trace.Steps = append(trace.Steps, GroupStep{Index: len(trace.Steps), Name: condition.Name, ID: condition.ID, Logic: logic})
+		step = &trace.Steps[len(trace.Steps)-1]
+	}
+
+	var result bool
 	if condition.Group != nil {
-		return EvaluateConditionGroup(*condition.Group, data)
+		// If it's a group condition, evaluate the group
+		var nested *GroupTrace
+		if step != nil {
+			nested = &GroupTrace{}
+			step.Group = nested
+		}
+		group, err := ev.evalGroup(*condition.Group, nested)
+		if err != nil {
+			return false, err
+		}
+		result = group
+	} else {
+		// Otherwise, evaluate as a single condition
+		single, err := ev.evalSingle(condition.Key, condition.Operator, condition.Value, condition.Default)
+		if err != nil && ev.strict {
+			return false, err
+		}
+		result = err == nil && single
 	}
 
-	// Otherwise, evaluate as a single condition
-	return evalSingleCondition(condition.Key, condition.Operator, condition.Value, data)
+	if condition.Not {
+		result = !result
+	}
+	if step != nil {
+		step.Result = result
+	}
+	return result, nil
 }
 
 // Helper functions for creating common condition patterns
@@ -673,6 +2172,96 @@ func NewSimpleCondition(key string, operator Operator, value interface{}) Condit
 	}
 }
 
+// NewInCondition creates an "in" condition that is true when the field equals
+// one of values, e.g. NewInCondition("status", "active", "pending"). Each
+// argument is one element of the list, so pass an existing slice as
+// NewSimpleCondition(key, OperatorIn, slice) instead. With no values the
+// condition is always false.
+func NewInCondition(key string, values ...interface{}) Conditions {
+	if values == nil {
+		values = []interface{}{}
+	}
+	return NewSimpleCondition(key, OperatorIn, values)
+}
+
+// NewBetweenCondition creates a "between" condition that is true when the
+// field is in [min, max], inclusive. A nil bound is open.
+func NewBetweenCondition(key string, min, max interface{}) Conditions {
+	return NewSimpleCondition(key, OperatorBetween, []interface{}{min, max})
+}
+
+// Ordered is the constraint of the typed builders of ordering conditions,
+// such as Gt: the numbers, strings and durations of cmp.Ordered, and times.
+//
+// The typed builders create the same Conditions as NewSimpleCondition,
+// NewInCondition and NewBetweenCondition, but type-check the value when the
+// rule is built: Gt[int]("age", minAge) only compiles if minAge is an int,
+// Between requires both bounds to have the same type, and In takes a
+// []string as In(key, codes...) without converting it to []interface{}.
+// Evaluation is unchanged.
+type Ordered interface {
+	cmp.Ordered | time.Time
+}
+
+// Eq creates an "==" condition on key with a value of type T.
+func Eq[T comparable](key string, v T) Conditions {
+	return NewSimpleCondition(key, OperatorEq, v)
+}
+
+// Neq creates a "!=" condition on key with a value of type T.
+func Neq[T comparable](key string, v T) Conditions {
+	return NewSimpleCondition(key, OperatorNeq, v)
+}
+
+// Gt creates a ">" condition on key with a value of type T.
+func Gt[T Ordered](key string, v T) Conditions {
+	return NewSimpleCondition(key, OperatorGt, v)
+}
+
+// Gte creates a ">=" condition on key with a value of type T.
+func Gte[T Ordered](key string, v T) Conditions {
+	return NewSimpleCondition(key, OperatorGte, v)
+}
+
+// Lt creates a "<" condition on key with a value of type T.
+func Lt[T Ordered](key string, v T) Conditions {
+	return NewSimpleCondition(key, OperatorLt, v)
+}
+
+// Lte creates a "<=" condition on key with a value of type T.
+func Lte[T Ordered](key string, v T) Conditions {
+	return NewSimpleCondition(key, OperatorLte, v)
+}
+
+// Between creates a "between" condition on key with bounds of type T, like
+// NewBetweenCondition. Both bounds are required; use NewBetweenCondition for
+// an open bound.
+func Between[T Ordered](key string, min, max T) Conditions {
+	return NewBetweenCondition(key, min, max)
+}
+
+// In creates an "in" condition on key with values of type T, like
+// NewInCondition, e.g. In("status", "active", "pending"). Pass an existing
+// slice as In(key, slice...).
+func In[T comparable](key string, vs ...T) Conditions {
+	return NewSimpleCondition(key, OperatorIn, toInterfaces(vs))
+}
+
+// Nin creates a "nin" condition on key with values of type T.
+func Nin[T comparable](key string, vs ...T) Conditions {
+	return NewSimpleCondition(key, OperatorNin, toInterfaces(vs))
+}
+
+// toInterfaces copies vs into a non-nil []interface{}, the list form
+// NewInCondition produces
+func toInterfaces[T any](vs []T) []interface{} {
+	values := make([]interface{}, len(vs))
+	for i, v := range vs {
+		values[i] = v
+	}
+	return values
+}
+
 // NewAndGroup creates an AND group condition from a list of child conditions.
 // All child conditions must evaluate to true for the group to be true.
 func NewAndGroup(children ...Conditions) Conditions {
@@ -691,6 +2280,70 @@ func NewOrGroup(children ...Conditions) Conditions {
 	}
 }
 
+// NewAtLeastGroup creates an ATLEAST group condition that is true when at
+// least n of the child conditions are true.
+func NewAtLeastGroup(n int, children ...Conditions) Conditions {
+	return Conditions{
+		Logic:     LogicAtLeast,
+		Children:  children,
+		Threshold: n,
+	}
+}
+
+// NewImpliesGroup creates an IMPLIES group that is true when antecedent is
+// false or consequent is true, i.e. "if antecedent then consequent", such as
+// "a minor needs guardian consent". The consequent is only evaluated when the
+// antecedent is true.
+func NewImpliesGroup(antecedent, consequent Conditions) Conditions {
+	return Conditions{
+		Logic:    LogicImplies,
+		Children: []Conditions{antecedent, consequent},
+	}
+}
+
+// And combines two condition trees into an AND group. If either side is
+// already an AND group, its children are merged into the result instead of
+// being nested, so And(And(a, b), c) is a single AND group of a, b and c.
+func And(a, b Conditions) Conditions {
+	return combine(LogicAnd, a, b)
+}
+
+// Or combines two condition trees into an OR group, merging the children of
+// either side that is already an OR group, like And.
+func Or(a, b Conditions) Conditions {
+	return combine(LogicOr, a, b)
+}
+
+// combine builds a group with the given logic from a and b, flattening sides
+// that are groups with the same logic
+func combine(logic Logic, a, b Conditions) Conditions {
+	var children []Conditions
+	for _, side := range []Conditions{a, b} {
+		if side.Logic == logic {
+			children = append(children, side.Children...)
+		} else {
+			children = append(children, side)
+		}
+	}
+	return Conditions{
+		Logic:    logic,
+		Children: children,
+	}
+}
+
+// Negate wraps a condition in a NOT group so its result is inverted at
+// evaluation time. It works uniformly for every operator, including custom
+// ones. Negating a NOT group unwraps it instead of nesting another NOT.
+func Negate(cond Conditions) Conditions {
+	if cond.Logic == LogicNot && len(cond.Children) == 1 {
+		return cond.Children[0]
+	}
+	return Conditions{
+		Logic:    LogicNot,
+		Children: []Conditions{cond},
+	}
+}
+
 // Helper functions for creating flexible condition patterns
 
 // NewConditionGroup creates a new ConditionGroup with the specified conditions.
@@ -719,55 +2372,160 @@ func NewGroupConditionWithLogic(group ConditionGroup, nextLogic Logic) Condition
 }
 
 // ConvertToConditionGroup converts the traditional nested Conditions structure
-// to the new flexible ConditionGroup structure.
+// to the new flexible ConditionGroup structure. ATLEAST groups have no
+// ConditionGroup equivalent and are expanded into an OR of one AND group per
+// combination of Threshold children, so a k-of-n group grows to n choose k
+// groups.
+//
+// An empty OR group, a group with unknown logic and an IMPLIES group without
+// exactly two children evaluate to false, and become a negated empty group,
+// which is false too. An empty node evaluates to the Evaluator's EmptyResult;
+// it becomes an empty ConditionGroup, which is always true, so the converted
+// rule only agrees with an Evaluator whose EmptyResult is true, the default.
 func ConvertToConditionGroup(conditions Conditions) ConditionGroup {
-	// If it's a single condition
-	if conditions.Key != "" {
+	if conditions.Logic == LogicAtLeast {
+		return convertAtLeast(conditions)
+	}
+
+	// Single conditions, NOT and IMPLIES groups become a single
+	// ConditionWithLogic that keeps their Name and ID
+	if isSingleNode(conditions) {
 		return ConditionGroup{
-			Conditions: []ConditionWithLogic{
-				{
-					Key:      conditions.Key,
-					Operator: conditions.Operator,
-					Value:    conditions.Value,
-				},
-			},
+			Conditions: []ConditionWithLogic{convertNode(conditions)},
 		}
 	}
 
 	// If it's a group condition
-	if len(conditions.Children) == 0 {
+	switch conditions.Logic {
+	case "":
+		// Without logic the node is empty, whatever its children
 		return ConditionGroup{}
+	case LogicAnd:
+		if len(conditions.Children) == 0 {
+			return ConditionGroup{}
+		}
+	case LogicOr:
+		if len(conditions.Children) == 0 {
+			return falseGroup()
+		}
+	default:
+		return falseGroup()
 	}
 
 	var conditionsWithLogic []ConditionWithLogic
 	for i, child := range conditions.Children {
-		var nextLogic Logic
+		condition := convertNode(child)
 		// For all conditions except the last one, use the group's logic
 		if i < len(conditions.Children)-1 {
-			nextLogic = conditions.Logic
+			condition.NextLogic = conditions.Logic
 		}
+		conditionsWithLogic = append(conditionsWithLogic, condition)
+	}
 
-		// If child is a single condition
-		if child.Key != "" {
-			conditionsWithLogic = append(conditionsWithLogic, ConditionWithLogic{
-				Key:       child.Key,
-				Operator:  child.Operator,
-				Value:     child.Value,
-				NextLogic: nextLogic,
-			})
-		} else {
-			// If child is a nested group, convert it recursively
-			childGroup := ConvertToConditionGroup(child)
-			conditionsWithLogic = append(conditionsWithLogic, ConditionWithLogic{
-				Group:     &childGroup,
-				NextLogic: nextLogic,
-			})
+	return ConditionGroup{
+		Conditions: conditionsWithLogic,
+	}
+}
+
+// isSingleNode reports whether convertNode converts cond without wrapping it
+// in a nested group: single conditions, NOT groups and IMPLIES groups. Group
+// fields take precedence over Key, as when evaluating.
+func isSingleNode(cond Conditions) bool {
+	switch cond.Logic {
+	case "":
+		return cond.Key != ""
+	case LogicNot:
+		return true
+	case LogicImplies:
+		return len(cond.Children) == 2
+	default:
+		return false
+	}
+}
+
+// convertNode converts one node of a Conditions tree to a ConditionWithLogic
+// carrying its Name and ID, without a NextLogic
+func convertNode(cond Conditions) ConditionWithLogic {
+	node := ConditionWithLogic{Name: cond.Name, ID: cond.ID}
+	switch {
+	case cond.Logic == "" && cond.Key != "":
+		node.Key = cond.Key
+		node.Operator = cond.Operator
+		node.Value = cond.Value
+		node.Default = cond.Default
+	case cond.Logic == LogicNot:
+		// A NOT group becomes a negated nested group of its children
+		inner := ConvertToConditionGroup(Conditions{Logic: LogicAnd, Children: cond.Children})
+		node.Group = &inner
+		node.Not = true
+	case cond.Logic == LogicImplies && len(cond.Children) == 2:
+		// An IMPLIES group becomes NOT antecedent OR consequent
+		antecedent := ConvertToConditionGroup(cond.Children[0])
+		consequent := ConvertToConditionGroup(cond.Children[1])
+		node.Group = &ConditionGroup{
+			Conditions: []ConditionWithLogic{
+				{Group: &antecedent, Not: true, NextLogic: LogicOr},
+				{Group: &consequent},
+			},
 		}
+	default:
+		// A nested group is converted recursively
+		group := ConvertToConditionGroup(cond)
+		node.Group = &group
 	}
+	return node
+}
 
+// falseGroup returns a ConditionGroup that is always false: a negated empty
+// group, since an empty group is true
+func falseGroup() ConditionGroup {
 	return ConditionGroup{
-		Conditions: conditionsWithLogic,
+		Conditions: []ConditionWithLogic{
+			{Group: &ConditionGroup{}, Not: true},
+		},
+	}
+}
+
+// convertAtLeast converts an ATLEAST group to an OR of AND groups, one for
+// each combination of Threshold children
+func convertAtLeast(conditions Conditions) ConditionGroup {
+	k, n := conditions.Threshold, len(conditions.Children)
+	if k <= 0 {
+		// An empty group is true
+		return ConditionGroup{}
+	}
+	if k > n {
+		return falseGroup()
+	}
+
+	var alternatives []ConditionWithLogic
+	indexes := make([]int, k)
+	for i := range indexes {
+		indexes[i] = i
+	}
+	for {
+		selected := make([]Conditions, k)
+		for i, index := range indexes {
+			selected[i] = conditions.Children[index]
+		}
+		group := ConvertToConditionGroup(Conditions{Logic: LogicAnd, Children: selected})
+		alternatives = append(alternatives, ConditionWithLogic{Group: &group, NextLogic: LogicOr})
+
+		// Advance to the next combination in lexicographic order
+		i := k - 1
+		for i >= 0 && indexes[i] == n-k+i {
+			i--
+		}
+		if i < 0 {
+			break
+		}
+		indexes[i]++
+		for j := i + 1; j < k; j++ {
+			indexes[j] = indexes[j-1] + 1
+		}
 	}
+	alternatives[len(alternatives)-1].NextLogic = ""
+	return ConditionGroup{Conditions: alternatives}
 }
 
 // EvaluateFlexibleCondition evaluates either the traditional Conditions structure
@@ -786,3 +2544,27 @@ func EvaluateFlexibleCondition(conditions interface{}, data map[string]interface
 		return false
 	}
 }
+
+// ErrUnsupportedConditionType is returned by EvaluateFlexibleConditionE for
+// values that are neither a Conditions nor a ConditionGroup.
+var ErrUnsupportedConditionType = errors.New("unsupported condition type")
+
+// EvaluateFlexibleConditionE is like EvaluateFlexibleCondition, but dispatches
+// to EvaluateConditionE and EvaluateConditionGroupE and reports their errors.
+func EvaluateFlexibleConditionE(conditions interface{}, data map[string]interface{}) (bool, error) {
+	switch cond := conditions.(type) {
+	case Conditions:
+		return EvaluateConditionE(cond, data)
+	case ConditionGroup:
+		return EvaluateConditionGroupE(cond, data)
+	case *Conditions:
+		if cond != nil {
+			return EvaluateConditionE(*cond, data)
+		}
+	case *ConditionGroup:
+		if cond != nil {
+			return EvaluateConditionGroupE(*cond, data)
+		}
+	}
+	return false, fmt.Errorf("%w: %T", ErrUnsupportedConditionType, conditions)
+}
@@ -1,9 +1,14 @@
 package jsonvaluate
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"reflect"
+	"regexp"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -238,11 +243,80 @@ func BenchmarkEvaluateCondition(b *testing.B) {
 	}
 }
 
-func TestCustomOperators(t *testing.T) {
-	// Clean up any existing custom operators
-	for _, op := range GetRegisteredCustomOperators() {
-		UnregisterCustomOperator(op)
+func TestIsEqual(t *testing.T) {
+	tests := []struct {
+		name   string
+		v1, v2 interface{}
+		expect bool
+	}{
+		{"same string", "TH", "TH", true},
+		{"different string", "TH", "SG", false},
+		{"equal durations", "1h", "60m", true},
+		{"equal numeric strings", "1.0", "1", true},
+		{"same int", 25, 25, true},
+		{"different int", 25, 26, false},
+		{"same float", 88.5, 88.5, true},
+		{"signed zero", 0.0, math.Copysign(0, -1), true},
+		{"NaN", math.NaN(), math.NaN(), false},
+		{"same bool", true, true, true},
+		{"different bool", true, false, false},
+		{"int and float", 25, 25.0, true},
+		{"int and string", 25, "25", true},
+		{"bool and string", true, "true", true},
+		{"nil", nil, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := isEqual(tt.v1, tt.v2); result != tt.expect {
+				t.Errorf("isEqual(%v, %v) = %v, want %v", tt.v1, tt.v2, result, tt.expect)
+			}
+			if result := isEqual(tt.v2, tt.v1); result != tt.expect {
+				t.Errorf("isEqual(%v, %v) = %v, want %v", tt.v2, tt.v1, result, tt.expect)
+			}
+		})
+	}
+}
+
+// BenchmarkIsEqual compares the same-type cases isEqual handles without
+// reflection against mixed types, which still take the general path.
+func BenchmarkIsEqual(b *testing.B) {
+	benchmarks := []struct {
+		name   string
+		v1, v2 interface{}
+	}{
+		{"string", "active", "active"},
+		{"int", 25, 25},
+		{"float64", 88.5, 88.5},
+		{"bool", true, true},
+		{"int_float64", 25, 25.0},
+		{"int_string", 25, "25"},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_ = isEqual(bm.v1, bm.v2)
+			}
+		})
+	}
+}
+
+func BenchmarkEqualsOperator(b *testing.B) {
+	data := map[string]interface{}{"country": "TH", "age": 25}
+	country := NewSimpleCondition("country", OperatorEq, "TH")
+	age := NewSimpleCondition("age", OperatorEq, 25)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = EvaluateCondition(country, data)
+		_ = EvaluateCondition(age, data)
 	}
+}
+
+func TestCustomOperators(t *testing.T) {
+	// Start from an empty registry and restore it afterwards
+	defer RestoreOperators(SnapshotOperators())
+	ResetCustomOperators()
 
 	data := map[string]interface{}{
 		"name":  "John Doe",
@@ -410,17 +484,12 @@ func TestCustomOperators(t *testing.T) {
 		t.Error("Complex condition with custom operator should be true")
 	}
 
-	// Clean up
-	for _, op := range GetRegisteredCustomOperators() {
-		UnregisterCustomOperator(op)
-	}
 }
 
 func TestCustomOperatorEdgeCases(t *testing.T) {
-	// Clean up any existing custom operators
-	for _, op := range GetRegisteredCustomOperators() {
-		UnregisterCustomOperator(op)
-	}
+	// Start from an empty registry and restore it afterwards
+	defer RestoreOperators(SnapshotOperators())
+	ResetCustomOperators()
 
 	data := map[string]interface{}{
 		"value": "test",
@@ -443,6 +512,22 @@ func TestCustomOperatorEdgeCases(t *testing.T) {
 		t.Error("Panicking operator should return false")
 	}
 
+	// Missing keys go through the same recovery as present keys
+	missing := Conditions{Key: "missing", Operator: "panic_operator", Value: "anything"}
+	if EvaluateCondition(missing, data) {
+		t.Error("Panicking operator should return false for a missing key")
+	}
+	if result, err := EvaluateConditionE(missing, data); result || !errors.Is(err, ErrOperatorPanicked) {
+		t.Errorf("Expected false, ErrOperatorPanicked for a missing key, got %v, %v", result, err)
+	}
+	group := NewConditionGroup(NewConditionWithLogic("missing", "panic_operator", "anything", ""))
+	if EvaluateConditionGroup(group, data) {
+		t.Error("Panicking operator should return false for a missing key in a ConditionGroup")
+	}
+	if !EvaluateCondition(Negate(missing), data) {
+		t.Error("A recovered panic should evaluate to false, so its negation is true")
+	}
+
 	// Test 2: Custom operator with nil validator (should panic when registering)
 	func() {
 		defer func() {
@@ -477,17 +562,12 @@ func TestCustomOperatorEdgeCases(t *testing.T) {
 		<-done
 	}
 
-	// Clean up
-	for _, op := range GetRegisteredCustomOperators() {
-		UnregisterCustomOperator(op)
-	}
 }
 
 func TestQuickCustomOperatorDemo(t *testing.T) {
-	// Clean up any existing custom operators
-	for _, op := range GetRegisteredCustomOperators() {
-		UnregisterCustomOperator(op)
-	}
+	// Start from an empty registry and restore it afterwards
+	defer RestoreOperators(SnapshotOperators())
+	ResetCustomOperators()
 
 	// Register a custom operator
 	RegisterCustomOperator("case_insensitive_eq", func(fieldValue, expectedValue interface{}) bool {
@@ -517,9 +597,6 @@ func TestQuickCustomOperatorDemo(t *testing.T) {
 		t.Errorf("Expected 1 registered operator 'case_insensitive_eq', got %v", ops)
 	}
 
-	// Clean up
-	UnregisterCustomOperator("case_insensitive_eq")
-
 	fmt.Printf("✅ Custom operator demo test passed!\n")
 	fmt.Printf("   - Registered custom operator: %v\n", ops[0])
 	fmt.Printf("   - Evaluated condition successfully: %v\n", result)
@@ -645,6 +722,67 @@ func TestConditionGroup_FlexibleLogic(t *testing.T) {
 	}
 }
 
+func TestConvertToConditionGroup_MixedNode(t *testing.T) {
+	data := map[string]interface{}{"age": 25, "status": "inactive"}
+
+	// Group fields take precedence over Key, at the top level and in children
+	mixed := Conditions{
+		Key:      "age",
+		Operator: OperatorGt,
+		Value:    18,
+		Logic:    LogicAnd,
+		Children: []Conditions{
+			{Key: "status", Operator: OperatorEq, Value: "active"},
+		},
+	}
+	for name, cond := range map[string]Conditions{
+		"top level": mixed,
+		"child":     NewOrGroup(mixed, NewSimpleCondition("age", OperatorLt, 18)),
+	} {
+		want := EvaluateCondition(cond, data)
+		if want {
+			t.Fatalf("%s: expected mixed node to evaluate as its group", name)
+		}
+		if got := EvaluateConditionGroup(ConvertToConditionGroup(cond), data); got != want {
+			t.Errorf("%s: converted = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestConvertToConditionGroup_EmptyGroups(t *testing.T) {
+	data := map[string]interface{}{"age": 25}
+	adult := NewSimpleCondition("age", OperatorGte, 18)
+
+	tests := []struct {
+		name   string
+		cond   Conditions
+		expect bool
+	}{
+		{"empty AND", NewAndGroup(), true},
+		{"empty OR", NewOrGroup(), false},
+		{"zero condition", Conditions{}, true},
+		{"empty AND in AND", NewAndGroup(adult, NewAndGroup()), true},
+		{"empty OR in AND", NewAndGroup(adult, NewOrGroup()), false},
+		{"empty OR in OR", NewOrGroup(NewOrGroup(), adult), true},
+		{"zero condition in AND", NewAndGroup(adult, Conditions{}), true},
+		{"children without logic", Conditions{Children: []Conditions{Negate(adult)}}, true},
+		{"unknown logic", Conditions{Logic: "XOR", Children: []Conditions{adult}}, false},
+		{"IMPLIES with one child", Conditions{Logic: LogicImplies, Children: []Conditions{adult}}, false},
+		{"empty NOT", Conditions{Logic: LogicNot}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := EvaluateCondition(tt.cond, data); result != tt.expect {
+				t.Fatalf("EvaluateCondition() = %v, want %v", result, tt.expect)
+			}
+			if result := EvaluateConditionGroup(ConvertToConditionGroup(tt.cond), data); result != tt.expect {
+				t.Errorf("EvaluateConditionGroup() of converted group = %v, want %v", result, tt.expect)
+			}
+		})
+	}
+}
+
 func TestConditionGroup_HelperFunctions(t *testing.T) {
 	data := map[string]interface{}{
 		"age":    25,
@@ -819,3 +957,1835 @@ func TestFlexibleConditionDemo(t *testing.T) {
 		t.Error("All flexible conditions should be true")
 	}
 }
+
+func TestFlexibleConditionJSON_TypedData(t *testing.T) {
+	// Rules decoded from JSON hold float64 values, or json.Number with
+	// UseNumber, which must compare numerically with Go integer fields
+	flexibleJSON := `{
+		"conditions": [
+			{"key": "sum_insured", "operator": ">=", "value": 200000, "next_logic": "AND"},
+			{
+				"group": {
+					"conditions": [
+						{"key": "amount", "operator": ">=", "value": 100000, "next_logic": "OR"},
+						{"key": "amount", "operator": "<=", "value": 1000000}
+					]
+				},
+				"next_logic": "AND"
+			},
+			{"key": "percent", "operator": ">=", "value": 20, "next_logic": "AND"},
+			{"key": "age", "operator": "between", "value": [18, 65], "next_logic": "AND"},
+			{"key": "plan", "operator": "in", "value": [1, 2, 3]}
+		]
+	}`
+	nestedJSON := `{
+		"logic": "AND",
+		"children": [
+			{"key": "age", "operator": "between", "value": {"min": 18, "max": 65}},
+			{"key": "plan", "operator": "in", "value": [1, 2, 3]},
+			{"key": "percent", "operator": ">", "value": 9}
+		]
+	}`
+
+	typedData := []map[string]interface{}{
+		{"sum_insured": 250000, "amount": 150000, "percent": 25, "age": 30, "plan": 2},
+		{"sum_insured": int64(250000), "amount": int64(150000), "percent": int64(25), "age": int64(30), "plan": int64(2)},
+		{"sum_insured": int32(250000), "amount": int32(150000), "percent": int8(25), "age": int16(30), "plan": int8(2)},
+		{"sum_insured": uint(250000), "amount": uint64(150000), "percent": uint8(25), "age": uint32(30), "plan": uint16(2)},
+		{"sum_insured": float32(250000), "amount": 150000.0, "percent": float32(25), "age": float32(30), "plan": float32(2)},
+		// Compared as text these would fail, e.g. "100" < "20"
+		{"sum_insured": 1000000, "amount": 99, "percent": 100, "age": 40, "plan": 1},
+	}
+	// Bounds are inclusive, and values just outside them must not match
+	edgeData := map[string]interface{}{"sum_insured": 200000, "amount": 1000000, "percent": 20, "age": 65, "plan": 3}
+	outsideData := []map[string]interface{}{
+		{"sum_insured": 199999, "amount": 150000, "percent": 25, "age": 30, "plan": 2},
+		{"sum_insured": 250000, "amount": 150000, "percent": 25, "age": 66, "plan": 2},
+		{"sum_insured": 250000, "amount": 150000, "percent": 25, "age": int64(17), "plan": 2},
+		{"sum_insured": 250000, "amount": 150000, "percent": 25, "age": 30, "plan": int64(4)},
+		{"sum_insured": 250000, "amount": 150000, "percent": 25, "age": 5, "plan": 2},
+	}
+
+	for _, useNumber := range []bool{false, true} {
+		decode := func(s string, v interface{}) {
+			t.Helper()
+			dec := json.NewDecoder(strings.NewReader(s))
+			if useNumber {
+				dec.UseNumber()
+			}
+			if err := dec.Decode(v); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+		}
+		var group ConditionGroup
+		decode(flexibleJSON, &group)
+		var nested Conditions
+		decode(nestedJSON, &nested)
+
+		for _, data := range typedData {
+			if !EvaluateConditionGroup(group, data) {
+				t.Errorf("useNumber=%v: flexible rule = false for %#v", useNumber, data)
+			}
+			if !EvaluateCondition(nested, data) {
+				t.Errorf("useNumber=%v: nested rule = false for %#v", useNumber, data)
+			}
+		}
+		if !EvaluateConditionGroup(group, edgeData) {
+			t.Errorf("useNumber=%v: flexible rule = false at the bounds", useNumber)
+		}
+		for _, data := range outsideData {
+			if EvaluateConditionGroup(group, data) {
+				t.Errorf("useNumber=%v: flexible rule = true for %#v", useNumber, data)
+			}
+		}
+	}
+}
+
+func TestCustomOperatorWithError(t *testing.T) {
+	defer UnregisterCustomOperator("regex")
+	defer UnregisterCustomOperator("boom")
+
+	RegisterCustomOperatorE("regex", func(fieldValue, expectedValue interface{}) (bool, error) {
+		re, err := regexp.Compile(toString(expectedValue))
+		if err != nil {
+			return false, err
+		}
+		return re.MatchString(toString(fieldValue)), nil
+	})
+	RegisterCustomOperator("boom", func(fieldValue, expectedValue interface{}) bool {
+		panic("boom")
+	})
+
+	data := map[string]interface{}{
+		"code": "AB-123",
+	}
+
+	// Satisfied and not satisfied conditions report no error
+	result, err := EvaluateConditionE(Conditions{Key: "code", Operator: "regex", Value: `^[A-Z]{2}-\d+$`}, data)
+	if err != nil || !result {
+		t.Errorf("Expected true without error, got %v, %v", result, err)
+	}
+	result, err = EvaluateConditionE(Conditions{Key: "code", Operator: "regex", Value: `^\d+$`}, data)
+	if err != nil || result {
+		t.Errorf("Expected false without error, got %v, %v", result, err)
+	}
+
+	// A bad pattern is an evaluation error, propagated through groups
+	bad := NewOrGroup(
+		NewSimpleCondition("code", OperatorEq, "nope"),
+		NewAndGroup(NewSimpleCondition("code", "regex", "([")),
+	)
+	result, err = EvaluateConditionE(bad, data)
+	if err == nil || result {
+		t.Errorf("Expected error for invalid pattern, got %v, %v", result, err)
+	}
+	if EvaluateCondition(bad, data) {
+		t.Error("EvaluateCondition should treat an operator error as false")
+	}
+
+	// An erroring child doesn't prevent an OR group from passing in the bool API
+	orGroup := NewOrGroup(
+		NewSimpleCondition("code", "regex", "(["),
+		NewSimpleCondition("code", OperatorEq, "AB-123"),
+	)
+	if !EvaluateCondition(orGroup, data) {
+		t.Error("EvaluateCondition should continue past an operator error")
+	}
+
+	// Panics are reported as ErrOperatorPanicked
+	_, err = EvaluateConditionE(Conditions{Key: "code", Operator: "boom", Value: nil}, data)
+	if !errors.Is(err, ErrOperatorPanicked) {
+		t.Errorf("Expected ErrOperatorPanicked, got %v", err)
+	}
+}
+
+func TestGetRegisteredCustomOperators_Sorted(t *testing.T) {
+	names := []Operator{"zeta", "alpha", "mid", "beta", "omega"}
+	for _, op := range names {
+		RegisterCustomOperator(op, func(fieldValue, expectedValue interface{}) bool { return true })
+		defer UnregisterCustomOperator(op)
+	}
+
+	expected := []Operator{"alpha", "beta", "mid", "omega", "zeta"}
+	for i := 0; i < 10; i++ {
+		if ops := GetRegisteredCustomOperators(); !reflect.DeepEqual(ops, expected) {
+			t.Fatalf("Expected %v, got %v", expected, ops)
+		}
+	}
+}
+
+func TestRegisterCustomOperator_BuiltinConflict(t *testing.T) {
+	validator := func(fieldValue, expectedValue interface{}) bool { return true }
+
+	for _, op := range []Operator{OperatorEq, OperatorBetween, OperatorIsnull} {
+		if !IsBuiltinOperator(op) {
+			t.Errorf("Expected %q to be a built-in operator", op)
+		}
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("Registering built-in operator %q should panic", op)
+				}
+			}()
+			RegisterCustomOperator(op, validator)
+		}()
+	}
+
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("RegisterCustomOperatorE with a built-in operator should panic")
+			}
+		}()
+		RegisterCustomOperatorE(OperatorEq, func(fieldValue, expectedValue interface{}) (bool, error) { return true, nil })
+	}()
+
+	if IsBuiltinOperator("always") {
+		t.Error("Expected \"always\" not to be a built-in operator")
+	}
+	RegisterCustomOperator("always", validator)
+	defer UnregisterCustomOperator("always")
+
+	if !EvaluateCondition(NewSimpleCondition("x", "always", nil), map[string]interface{}{"x": 1}) {
+		t.Error("Non-conflicting custom operator should be registered")
+	}
+	if EvaluateCondition(NewSimpleCondition("x", OperatorEq, 2), map[string]interface{}{"x": 1}) {
+		t.Error("Built-in operator should not be affected")
+	}
+}
+
+func TestRegisterCustomOperatorFunc(t *testing.T) {
+	defer RestoreOperators(SnapshotOperators())
+	ResetCustomOperators()
+
+	always := func(result bool) CustomOperatorValidatorE {
+		return func(fieldValue, expectedValue interface{}) (bool, error) { return result, nil }
+	}
+	data := map[string]interface{}{"x": 1}
+	cond := NewSimpleCondition("x", "plugin_op", nil)
+
+	if HasCustomOperator("plugin_op") {
+		t.Fatal("Expected plugin_op not to be registered yet")
+	}
+	if err := RegisterCustomOperatorFunc("plugin_op", always(true), WithAllowOverride(false)); err != nil {
+		t.Fatalf("RegisterCustomOperatorFunc() = %v, want nil", err)
+	}
+	if !HasCustomOperator("plugin_op") || !EvaluateCondition(cond, data) {
+		t.Fatal("Expected plugin_op to be registered")
+	}
+
+	// A second registration fails without overriding the first
+	err := RegisterCustomOperatorFunc("plugin_op", always(false), WithAllowOverride(false))
+	if !errors.Is(err, ErrOperatorExists) {
+		t.Errorf("RegisterCustomOperatorFunc() = %v, want ErrOperatorExists", err)
+	}
+	if !EvaluateCondition(cond, data) {
+		t.Error("Rejected registration should keep the existing operator")
+	}
+
+	// Overriding is the default, as with RegisterCustomOperator
+	if err := RegisterCustomOperatorFunc("plugin_op", always(false)); err != nil {
+		t.Errorf("RegisterCustomOperatorFunc() = %v, want nil", err)
+	}
+	if EvaluateCondition(cond, data) {
+		t.Error("Expected the operator to be overridden")
+	}
+	if err := RegisterCustomOperatorFunc("plugin_op", always(true), WithAllowOverride(true)); err != nil {
+		t.Errorf("RegisterCustomOperatorFunc() with override = %v, want nil", err)
+	}
+
+	if err := RegisterCustomOperatorFunc(OperatorEq, always(true)); !errors.Is(err, ErrBuiltinOperator) {
+		t.Errorf("RegisterCustomOperatorFunc(==) = %v, want ErrBuiltinOperator", err)
+	}
+	if err := RegisterCustomOperatorFunc("nil_op", nil); !errors.Is(err, ErrNilValidator) {
+		t.Errorf("RegisterCustomOperatorFunc(nil) = %v, want ErrNilValidator", err)
+	}
+	if HasCustomOperator("nil_op") || HasCustomOperator(OperatorEq) {
+		t.Error("Failed registrations should not be recorded")
+	}
+
+	UnregisterCustomOperator("plugin_op")
+	if HasCustomOperator("plugin_op") {
+		t.Error("Expected plugin_op to be unregistered")
+	}
+}
+
+func TestRegisterCustomOperatorField(t *testing.T) {
+	defer RestoreOperators(SnapshotOperators())
+
+	if err := RegisterCustomOperatorField("explicit_null", func(field FieldValue, _ interface{}) (bool, error) {
+		return field.Exists && field.Value == nil, nil
+	}); err != nil {
+		t.Fatalf("RegisterCustomOperatorField() = %v, want nil", err)
+	}
+	var seen []FieldValue
+	if err := RegisterCustomOperatorField("record", func(field FieldValue, _ interface{}) (bool, error) {
+		seen = append(seen, field)
+		return true, nil
+	}); err != nil {
+		t.Fatalf("RegisterCustomOperatorField() = %v, want nil", err)
+	}
+
+	data := map[string]interface{}{"nulled": nil, "set": 1}
+	tests := []struct {
+		cond   Conditions
+		expect bool
+	}{
+		{NewSimpleCondition("nulled", "explicit_null", nil), true},
+		{NewSimpleCondition("missing", "explicit_null", nil), false},
+		{NewSimpleCondition("set", "explicit_null", nil), false},
+		{Conditions{Key: "missing", Operator: "explicit_null", Default: 0}, false},
+	}
+	for _, tt := range tests {
+		if result := EvaluateCondition(tt.cond, data); result != tt.expect {
+			t.Errorf("%s explicit_null = %v, want %v", tt.cond.Key, result, tt.expect)
+		}
+	}
+
+	for _, key := range []string{"nulled", "missing", "set"} {
+		EvaluateCondition(NewSimpleCondition(key, "record", nil), data)
+	}
+	EvaluateCondition(Conditions{Key: "missing", Operator: "record", Default: "x"}, data)
+	want := []FieldValue{{nil, true}, {nil, false}, {1, true}, {"x", true}}
+	if !reflect.DeepEqual(seen, want) {
+		t.Errorf("seen = %v, want %v", seen, want)
+	}
+
+	if err := RegisterCustomOperatorField(OperatorEq, func(FieldValue, interface{}) (bool, error) { return true, nil }); !errors.Is(err, ErrBuiltinOperator) {
+		t.Errorf("RegisterCustomOperatorField(==) = %v, want ErrBuiltinOperator", err)
+	}
+	if err := RegisterCustomOperatorField("nil_op", nil); !errors.Is(err, ErrNilValidator) {
+		t.Errorf("RegisterCustomOperatorField(nil) = %v, want ErrNilValidator", err)
+	}
+	if err := RegisterCustomOperatorField("record", func(FieldValue, interface{}) (bool, error) { return false, nil }, WithAllowOverride(false)); !errors.Is(err, ErrOperatorExists) {
+		t.Errorf("RegisterCustomOperatorField(record) = %v, want ErrOperatorExists", err)
+	}
+}
+
+func TestRegisterCustomOperatorWithValidation(t *testing.T) {
+	defer RestoreOperators(SnapshotOperators())
+
+	// The in_range operator from TestCustomOperators, which silently fails to
+	// match when the value isn't [min, max]
+	inRange := func(fieldValue, expectedValue interface{}) (bool, error) {
+		value, ok := toNumber(fieldValue)
+		if !ok {
+			return false, nil
+		}
+		rv := reflect.ValueOf(expectedValue)
+		if rv.Kind() != reflect.Slice || rv.Len() != 2 {
+			return false, nil
+		}
+		min, okMin := toNumber(rv.Index(0).Interface())
+		max, okMax := toNumber(rv.Index(1).Interface())
+		return okMin && okMax && value >= min && value <= max, nil
+	}
+	errRangeShape := errors.New("want [min, max] numbers")
+	checkRange := func(expectedValue interface{}) error {
+		rv := reflect.ValueOf(expectedValue)
+		if rv.Kind() != reflect.Slice || rv.Len() != 2 {
+			return errRangeShape
+		}
+		for i := 0; i < 2; i++ {
+			if _, ok := toNumber(rv.Index(i).Interface()); !ok {
+				return errRangeShape
+			}
+		}
+		return nil
+	}
+	if err := RegisterCustomOperatorWithValidation("in_range", inRange, checkRange); err != nil {
+		t.Fatalf("RegisterCustomOperatorWithValidation: %v", err)
+	}
+
+	data := map[string]interface{}{"age": 25}
+	good := NewSimpleCondition("age", "in_range", []interface{}{20, 30})
+	bad := NewSimpleCondition("age", "in_range", 20)
+
+	if err := ValidateConditions(NewAndGroup(good)); err != nil {
+		t.Errorf("ValidateConditions(good) = %v", err)
+	}
+	if result, err := EvaluateConditionE(good, data); err != nil || !result {
+		t.Errorf("EvaluateConditionE(good) = %v, %v, want true", result, err)
+	}
+
+	err := ValidateConditions(NewAndGroup(good, bad))
+	if !errors.Is(err, ErrInvalidValue) || !errors.Is(err, errRangeShape) {
+		t.Errorf("ValidateConditions(bad) = %v, want ErrInvalidValue wrapping the check's error", err)
+	}
+	if err == nil || !strings.Contains(err.Error(), "root.children[1]") {
+		t.Errorf("expected the path of the bad condition in %v", err)
+	}
+	if err := ValidateConditions(NewSimpleCondition("age", "in_range", []interface{}{"a", "b"})); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("ValidateConditions(non-numeric bounds) = %v, want ErrInvalidValue", err)
+	}
+	if err := ValidateConditionGroup(NewConditionGroup(NewConditionWithLogic("age", "in_range", "x", ""))); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("ValidateConditionGroup(bad) = %v, want ErrInvalidValue", err)
+	}
+
+	if result, err := EvaluateConditionE(bad, data); !errors.Is(err, ErrInvalidValue) || result {
+		t.Errorf("EvaluateConditionE(bad) = %v, %v, want false, ErrInvalidValue", result, err)
+	}
+	if EvaluateCondition(bad, data) {
+		t.Error("EvaluateCondition(bad) = true, want false")
+	}
+
+	// Parameters are checked once resolved
+	withParam := NewSimpleCondition("age", "in_range", ParamRef("range"))
+	if err := ValidateConditions(withParam); err != nil {
+		t.Errorf("ValidateConditions(param) = %v", err)
+	}
+	e := &Evaluator{Params: map[string]interface{}{"range": "20-30"}}
+	if _, err := e.EvaluateConditionE(withParam, data); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("EvaluateConditionE(param) = %v, want ErrInvalidValue", err)
+	}
+
+	// WithValueCheck works with the other registration functions
+	if err := RegisterCustomOperatorField("has_tag", func(field FieldValue, tag interface{}) (bool, error) {
+		return contains(field.Value, tag), nil
+	}, WithValueCheck(func(tag interface{}) error {
+		if _, ok := tag.(string); !ok {
+			return errors.New("tag must be a string")
+		}
+		return nil
+	})); err != nil {
+		t.Fatalf("RegisterCustomOperatorField: %v", err)
+	}
+	if err := ValidateConditions(NewSimpleCondition("tags", "has_tag", 7)); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("ValidateConditions(has_tag) = %v, want ErrInvalidValue", err)
+	}
+
+	// Snapshots keep value checks
+	snapshot := SnapshotOperators()
+	ResetCustomOperators()
+	RestoreOperators(snapshot)
+	if err := ValidateConditions(bad); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("ValidateConditions after restore = %v, want ErrInvalidValue", err)
+	}
+}
+
+func TestEvaluateConditionWith(t *testing.T) {
+	defer RestoreOperators(SnapshotOperators())
+
+	RegisterCustomOperator("tier_ok", func(fieldValue, expectedValue interface{}) bool {
+		return fieldValue == "gold"
+	})
+	ops := map[Operator]CustomOperatorValidator{
+		"sku_allowed": func(fieldValue, expectedValue interface{}) bool {
+			return isIn(fieldValue, expectedValue)
+		},
+		"tier_ok": func(fieldValue, expectedValue interface{}) bool {
+			return fieldValue == "silver"
+		},
+		"missing_ok": func(fieldValue, expectedValue interface{}) bool {
+			return fieldValue == nil
+		},
+		OperatorEq: func(fieldValue, expectedValue interface{}) bool {
+			return true
+		},
+	}
+	data := map[string]interface{}{"sku": "A-1", "tier": "silver"}
+
+	tests := []struct {
+		name   string
+		cond   Conditions
+		expect bool
+	}{
+		{"scoped operator", NewSimpleCondition("sku", "sku_allowed", []string{"A-1", "B-2"}), true},
+		{"scoped operator fails", NewSimpleCondition("sku", "sku_allowed", []string{"B-2"}), false},
+		{"overrides registry", NewSimpleCondition("tier", "tier_ok", nil), true},
+		{"missing field", NewSimpleCondition("other", "missing_ok", nil), true},
+		{"built-in wins", NewSimpleCondition("sku", OperatorEq, "B-2"), false},
+		{"in a group", NewAndGroup(NewSimpleCondition("sku", "sku_allowed", []string{"A-1"}), NewSimpleCondition("tier", OperatorEq, "silver")), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := EvaluateConditionWith(tt.cond, data, ops); result != tt.expect {
+				t.Errorf("EvaluateConditionWith() = %v, want %v", result, tt.expect)
+			}
+		})
+	}
+
+	// The registry still applies when ops don't override it
+	if !EvaluateConditionWith(NewSimpleCondition("tier", "tier_ok", nil), map[string]interface{}{"tier": "gold"}, nil) {
+		t.Error("expected the registered tier_ok without scoped operators")
+	}
+
+	// Scoped operators are never visible to other evaluations, even concurrent ones
+	scoped := NewSimpleCondition("sku", "sku_allowed", []string{"A-1"})
+	var wg sync.WaitGroup
+	errs := make(chan string, 200)
+	for i := 0; i < 100; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if !EvaluateConditionWith(scoped, data, ops) {
+				errs <- "scoped evaluation = false, want true"
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if EvaluateCondition(scoped, data) {
+				errs <- "global evaluation saw the scoped operator"
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	if HasCustomOperator("sku_allowed") {
+		t.Error("scoped operator leaked into the registry")
+	}
+}
+
+func TestNegateOperator(t *testing.T) {
+	defer RestoreOperators(SnapshotOperators())
+
+	errBadField := errors.New("not a number")
+	inRange := func(fieldValue, expectedValue interface{}) (bool, error) {
+		value, ok := toNumber(fieldValue)
+		if !ok {
+			return false, errBadField
+		}
+		bounds, _ := expectedValue.([]interface{})
+		min, _ := toNumber(bounds[0])
+		max, _ := toNumber(bounds[1])
+		return value >= min && value <= max, nil
+	}
+	checkRange := func(expectedValue interface{}) error {
+		if bounds, ok := expectedValue.([]interface{}); !ok || len(bounds) != 2 {
+			return errors.New("want [min, max]")
+		}
+		return nil
+	}
+	if err := RegisterCustomOperatorWithValidation("in_range", inRange, checkRange); err != nil {
+		t.Fatalf("RegisterCustomOperatorWithValidation: %v", err)
+	}
+	if err := NegateOperator("in_range", "not_in_range"); err != nil {
+		t.Fatalf("NegateOperator: %v", err)
+	}
+
+	bounds := []interface{}{18, 65}
+	for _, age := range []interface{}{10, 18, 40, 65, 70} {
+		data := map[string]interface{}{"age": age}
+		in := EvaluateCondition(NewSimpleCondition("age", "in_range", bounds), data)
+		notIn := EvaluateCondition(NewSimpleCondition("age", "not_in_range", bounds), data)
+		if in == notIn {
+			t.Errorf("age %v: in_range = %v, not_in_range = %v, want opposites", age, in, notIn)
+		}
+	}
+
+	// Errors and value checks carry over rather than being negated
+	if result, err := EvaluateConditionE(NewSimpleCondition("age", "not_in_range", bounds), map[string]interface{}{"age": "n/a"}); result || !errors.Is(err, errBadField) {
+		t.Errorf("not_in_range on a bad field = %v, %v, want false, the validator's error", result, err)
+	}
+	if err := ValidateConditions(NewSimpleCondition("age", "not_in_range", 18)); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("ValidateConditions(not_in_range 18) = %v, want ErrInvalidValue", err)
+	}
+
+	if err := NegateOperator("no_such_op", "not_no_such_op"); !errors.Is(err, ErrUnknownOperator) {
+		t.Errorf("NegateOperator(unregistered) = %v, want ErrUnknownOperator", err)
+	}
+	if err := NegateOperator(OperatorEq, "not_eq"); !errors.Is(err, ErrUnknownOperator) {
+		t.Errorf("NegateOperator(built-in) = %v, want ErrUnknownOperator", err)
+	}
+	if err := NegateOperator("in_range", OperatorNin); !errors.Is(err, ErrBuiltinOperator) {
+		t.Errorf("NegateOperator(to a built-in) = %v, want ErrBuiltinOperator", err)
+	}
+	if HasCustomOperator("not_no_such_op") {
+		t.Error("failed NegateOperator registered an operator")
+	}
+}
+
+func TestSnapshotAndRestoreOperators(t *testing.T) {
+	defer RestoreOperators(SnapshotOperators())
+	ResetCustomOperators()
+
+	RegisterCustomOperator("keep", func(fieldValue, expectedValue interface{}) bool { return true })
+	snapshot := SnapshotOperators()
+
+	// Changes after the snapshot don't affect it
+	RegisterCustomOperator("temporary", func(fieldValue, expectedValue interface{}) bool { return true })
+	RegisterCustomOperator("keep", func(fieldValue, expectedValue interface{}) bool { return false })
+	UnregisterCustomOperator("missing")
+
+	RestoreOperators(snapshot)
+	if ops := GetRegisteredCustomOperators(); !reflect.DeepEqual(ops, []Operator{"keep"}) {
+		t.Errorf("Expected [keep] after restore, got %v", ops)
+	}
+	if !EvaluateCondition(NewSimpleCondition("x", "keep", nil), map[string]interface{}{"x": 1}) {
+		t.Error("Restored operator should use the snapshotted validator")
+	}
+
+	// Restoring doesn't tie the registry to the snapshot
+	RegisterCustomOperator("later", func(fieldValue, expectedValue interface{}) bool { return true })
+	RestoreOperators(snapshot)
+	if ops := GetRegisteredCustomOperators(); !reflect.DeepEqual(ops, []Operator{"keep"}) {
+		t.Errorf("Expected [keep] after second restore, got %v", ops)
+	}
+
+	ResetCustomOperators()
+	if ops := GetRegisteredCustomOperators(); len(ops) != 0 {
+		t.Errorf("Expected empty registry after reset, got %v", ops)
+	}
+}
+
+func TestAllOperators(t *testing.T) {
+	defer RestoreOperators(SnapshotOperators())
+	ResetCustomOperators()
+	RegisterCustomOperator("zeta", func(fieldValue, expectedValue interface{}) bool { return true })
+	RegisterCustomOperator("alpha", func(fieldValue, expectedValue interface{}) bool { return true })
+
+	infos := AllOperators()
+	if len(infos) != len(builtinOperators)+2 {
+		t.Fatalf("Expected %d operators, got %d", len(builtinOperators)+2, len(infos))
+	}
+
+	seen := make(map[Operator]bool)
+	for i, info := range infos[:len(builtinOperators)] {
+		if seen[info.Name] {
+			t.Errorf("Operator %s listed twice", info.Name)
+		}
+		seen[info.Name] = true
+		if !info.Builtin || !IsBuiltinOperator(info.Name) {
+			t.Errorf("infos[%d] = %+v, want a built-in operator", i, info)
+		}
+		if info.Description == "" {
+			t.Errorf("Built-in operator %s has no description", info.Name)
+		}
+	}
+	if infos[0].Name != OperatorEq {
+		t.Errorf("Expected built-ins in declaration order starting with ==, got %s", infos[0].Name)
+	}
+
+	custom := infos[len(builtinOperators):]
+	want := []OperatorInfo{{Name: "alpha", UsesValue: true}, {Name: "zeta", UsesValue: true}}
+	if !reflect.DeepEqual(custom, want) {
+		t.Errorf("Custom operators = %+v, want %+v", custom, want)
+	}
+
+	usesValue := make(map[Operator]bool)
+	for _, info := range infos {
+		usesValue[info.Name] = info.UsesValue
+	}
+	for _, op := range []Operator{OperatorIsnull, OperatorIsnotnull, OperatorIsEmpty, OperatorIsNotEmpty, OperatorIsTrue, OperatorIsFalse} {
+		if usesValue[op] {
+			t.Errorf("Expected %s to ignore Value", op)
+		}
+	}
+	for _, op := range []Operator{OperatorEq, OperatorIn, OperatorBetween, OperatorTypeIs} {
+		if !usesValue[op] {
+			t.Errorf("Expected %s to use Value", op)
+		}
+	}
+}
+
+func TestConditionDefault(t *testing.T) {
+	data := map[string]interface{}{
+		"age":      25,
+		"discount": nil,
+	}
+
+	tests := []struct {
+		name   string
+		cond   Conditions
+		expect bool
+	}{
+		{"missing without default", Conditions{Key: "score", Operator: OperatorGte, Value: 0}, false},
+		{"missing with default", Conditions{Key: "score", Operator: OperatorGte, Value: 0, Default: 10}, true},
+		{"missing with failing default", Conditions{Key: "score", Operator: OperatorGte, Value: 50, Default: 10}, false},
+		{"present ignores default", Conditions{Key: "age", Operator: OperatorEq, Value: 25, Default: 99}, true},
+		{"explicit null ignores default", Conditions{Key: "discount", Operator: OperatorIsnull, Default: 5}, true},
+		{"default makes key not null", Conditions{Key: "score", Operator: OperatorIsnotnull, Default: 0}, true},
+		{"default string", Conditions{Key: "country", Operator: OperatorIn, Value: []interface{}{"TH", "SG"}, Default: "TH"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := EvaluateCondition(tt.cond, data); result != tt.expect {
+				t.Errorf("EvaluateCondition() = %v, want %v", result, tt.expect)
+			}
+		})
+	}
+
+	// Defaults survive JSON decoding and conversion to ConditionGroup
+	var cond Conditions
+	if err := json.Unmarshal([]byte(`{"logic":"AND","children":[{"key":"score","operator":">=","value":5,"default":10}]}`), &cond); err != nil {
+		t.Fatal(err)
+	}
+	if !EvaluateCondition(cond, data) {
+		t.Error("Decoded condition with default should be true")
+	}
+	if !EvaluateConditionGroup(ConvertToConditionGroup(cond), data) {
+		t.Error("Converted condition with default should be true")
+	}
+}
+
+func TestToBool_Strings(t *testing.T) {
+	tests := []struct {
+		value  interface{}
+		expect bool
+	}{
+		{"true", true},
+		{"TRUE", true},
+		{"True", true},
+		{"1", true},
+		{"yes", true},
+		{"YES", true},
+		{"on", true},
+		{"On", true},
+		{"t", true},
+		{" y ", true},
+		{"false", false},
+		{"FALSE", false},
+		{"0", false},
+		{"no", false},
+		{"off", false},
+		{"", false},
+		{"maybe", false},
+		{"2", false},
+		{"truthy", false},
+		// Numeric and bool handling is unchanged
+		{1, true},
+		{0, false},
+		{-1.5, true},
+		{true, true},
+		{false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%v", tt.value), func(t *testing.T) {
+			data := map[string]interface{}{"flag": tt.value}
+			if result := evalSingleCondition("flag", OperatorIsTrue, nil, data); result != tt.expect {
+				t.Errorf("istrue(%q) = %v, want %v", tt.value, result, tt.expect)
+			}
+			if result := evalSingleCondition("flag", OperatorIsFalse, nil, data); result == tt.expect {
+				t.Errorf("isfalse(%q) = %v, want %v", tt.value, result, !tt.expect)
+			}
+		})
+	}
+}
+
+func TestNegate(t *testing.T) {
+	defer RestoreOperators(SnapshotOperators())
+	RegisterCustomOperator("is_even", func(fieldValue, expectedValue interface{}) bool {
+		n, ok := toNumber(fieldValue)
+		return ok && int(n)%2 == 0
+	})
+
+	data := map[string]interface{}{
+		"desc":  "hello world",
+		"age":   25,
+		"count": 4,
+	}
+
+	conds := []Conditions{
+		NewSimpleCondition("desc", OperatorStartsWith, "hello"),
+		NewSimpleCondition("desc", OperatorEndsWith, "hello"),
+		NewSimpleCondition("age", OperatorBetween, []interface{}{20, 30}),
+		NewSimpleCondition("count", "is_even", nil),
+		NewSimpleCondition("missing", OperatorEq, 1),
+		NewOrGroup(
+			NewSimpleCondition("age", OperatorLt, 18),
+			NewSimpleCondition("count", OperatorGt, 3),
+		),
+	}
+
+	for _, cond := range conds {
+		expected := !EvaluateCondition(cond, data)
+		negated := Negate(cond)
+		if result := EvaluateCondition(negated, data); result != expected {
+			t.Errorf("Negate(%+v) = %v, want %v", cond, result, expected)
+		}
+		if err := ValidateConditions(negated); err != nil {
+			t.Errorf("Negated condition should be valid: %v", err)
+		}
+		if result := EvaluateConditionGroup(ConvertToConditionGroup(negated), data); result != expected {
+			t.Errorf("Converted Negate(%+v) = %v, want %v", cond, result, expected)
+		}
+		if !reflect.DeepEqual(Negate(negated), cond) {
+			t.Errorf("Double negation should unwrap to the original condition")
+		}
+	}
+
+	// NOT groups can be written in JSON
+	var cond Conditions
+	if err := json.Unmarshal([]byte(`{"logic":"NOT","children":[{"key":"desc","operator":"contains","value":"bye"}]}`), &cond); err != nil {
+		t.Fatal(err)
+	}
+	if !EvaluateCondition(cond, data) {
+		t.Error("NOT contains should be true")
+	}
+
+	if err := ValidateConditions(Conditions{Logic: LogicNot}); !errors.Is(err, ErrNotArity) {
+		t.Errorf("Expected ErrNotArity, got %v", err)
+	}
+}
+
+func TestEvaluateConditionGroupE(t *testing.T) {
+	defer RestoreOperators(SnapshotOperators())
+	RegisterCustomOperatorE("fails", func(fieldValue, expectedValue interface{}) (bool, error) {
+		return false, errors.New("cannot evaluate")
+	})
+
+	data := map[string]interface{}{"age": 25, "status": "active"}
+
+	ok := NewConditionGroup(
+		NewConditionWithLogic("age", OperatorGt, 18, LogicAnd),
+		NewConditionWithLogic("status", OperatorEq, "active", ""),
+	)
+	result, err := EvaluateConditionGroupE(ok, data)
+	if err != nil || !result {
+		t.Errorf("Expected true without error, got %v, %v", result, err)
+	}
+
+	// Errors propagate from nested groups
+	failing := NewConditionGroup(
+		NewConditionWithLogic("age", OperatorGt, 18, LogicOr),
+		NewGroupConditionWithLogic(NewConditionGroup(NewConditionWithLogic("age", "fails", nil, "")), ""),
+	)
+	if _, err := EvaluateConditionGroupE(failing, data); err == nil {
+		t.Error("Expected operator error from nested group")
+	}
+	if !EvaluateConditionGroup(failing, data) {
+		t.Error("EvaluateConditionGroup should treat the error as false and keep going")
+	}
+
+	// Unknown NextLogic is an error, and AND in the bool API
+	unknown := NewConditionGroup(
+		NewConditionWithLogic("age", OperatorGt, 18, "XOR"),
+		NewConditionWithLogic("status", OperatorEq, "inactive", ""),
+	)
+	if _, err := EvaluateConditionGroupE(unknown, data); !errors.Is(err, ErrUnknownLogic) {
+		t.Errorf("Expected ErrUnknownLogic, got %v", err)
+	}
+	if EvaluateConditionGroup(unknown, data) {
+		t.Error("Unknown NextLogic should default to AND")
+	}
+
+	// EvaluateFlexibleConditionE dispatches to the error-returning variants
+	if _, err := EvaluateFlexibleConditionE(&failing, data); err == nil {
+		t.Error("Expected error from *ConditionGroup")
+	}
+	if _, err := EvaluateFlexibleConditionE(NewSimpleCondition("age", "fails", nil), data); err == nil {
+		t.Error("Expected error from Conditions")
+	}
+	if result, err := EvaluateFlexibleConditionE(ok, data); err != nil || !result {
+		t.Errorf("Expected true without error, got %v, %v", result, err)
+	}
+	if _, err := EvaluateFlexibleConditionE("nope", data); !errors.Is(err, ErrUnsupportedConditionType) {
+		t.Errorf("Expected ErrUnsupportedConditionType, got %v", err)
+	}
+	if _, err := EvaluateFlexibleConditionE((*Conditions)(nil), data); !errors.Is(err, ErrUnsupportedConditionType) {
+		t.Errorf("Expected ErrUnsupportedConditionType for nil pointer, got %v", err)
+	}
+}
+
+func TestAndOrCombine(t *testing.T) {
+	a := NewSimpleCondition("a", OperatorEq, 1)
+	b := NewSimpleCondition("b", OperatorEq, 2)
+	c := NewSimpleCondition("c", OperatorEq, 3)
+	d := NewSimpleCondition("d", OperatorEq, 4)
+
+	tests := []struct {
+		name   string
+		result Conditions
+		expect Conditions
+	}{
+		{"And leaves", And(a, b), NewAndGroup(a, b)},
+		{"And flattens left", And(And(a, b), c), NewAndGroup(a, b, c)},
+		{"And flattens right", And(a, And(b, c)), NewAndGroup(a, b, c)},
+		{"And flattens both", And(And(a, b), And(c, d)), NewAndGroup(a, b, c, d)},
+		{"And keeps OR nested", And(Or(a, b), c), NewAndGroup(NewOrGroup(a, b), c)},
+		{"Or flattens", Or(Or(a, b), c), NewOrGroup(a, b, c)},
+		{"Or keeps AND nested", Or(And(a, b), Or(c, d)), NewOrGroup(NewAndGroup(a, b), c, d)},
+		{"And keeps NOT nested", And(Negate(a), b), NewAndGroup(Negate(a), b)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !reflect.DeepEqual(tt.result, tt.expect) {
+				t.Errorf("got %+v, want %+v", tt.result, tt.expect)
+			}
+		})
+	}
+
+	data := map[string]interface{}{"a": 1, "b": 2, "c": 0}
+	if !EvaluateCondition(And(Or(a, c), b), data) {
+		t.Error("(a OR c) AND b should be true")
+	}
+	if EvaluateCondition(And(And(a, b), c), data) {
+		t.Error("a AND b AND c should be false")
+	}
+}
+
+func TestLikeAnyAll(t *testing.T) {
+	domains := []interface{}{"%.example.com", "partner.org"}
+	data := map[string]interface{}{
+		"api":     "api.example.com",
+		"root":    "example.com",
+		"partner": "partner.org",
+		"lookas":  "partnerXorg",
+		"other":   "evil.com",
+		"path":    "/v1/users/42",
+		"num":     42,
+	}
+
+	tests := []struct {
+		name   string
+		key    string
+		op     Operator
+		value  interface{}
+		expect bool
+	}{
+		{"any subdomain", "api", OperatorLikeAny, domains, true},
+		{"any exact", "partner", OperatorLikeAny, domains, true},
+		{"any no match", "other", OperatorLikeAny, domains, false},
+		{"any bare domain", "root", OperatorLikeAny, domains, false},
+		{"any dot is literal", "lookas", OperatorLikeAny, domains, false},
+		{"any string slice", "path", OperatorLikeAny, []string{"/v2/%", "/v1/users/__"}, true},
+		{"any single pattern", "api", OperatorLikeAny, "api.%", true},
+		{"any empty list", "api", OperatorLikeAny, []interface{}{}, false},
+		{"any number", "num", OperatorLikeAny, []interface{}{"4_"}, true},
+		{"all match", "path", OperatorLikeAll, []interface{}{"/v1/%", "%/42", "%users%"}, true},
+		{"all one fails", "path", OperatorLikeAll, []interface{}{"/v1/%", "%/43"}, false},
+		{"all empty list", "path", OperatorLikeAll, []interface{}{}, true},
+		{"missing key", "missing", OperatorLikeAny, domains, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := evalSingleCondition(tt.key, tt.op, tt.value, data)
+			if result != tt.expect {
+				t.Errorf("evalSingleCondition(%s, %s, %v) = %v, want %v", tt.key, tt.op, tt.value, result, tt.expect)
+			}
+		})
+	}
+}
+
+func TestPrefixInSuffixIn(t *testing.T) {
+	routes := []interface{}{"/api", "/api/v1", "/static/"}
+	data := map[string]interface{}{
+		"users":  "/api/v1/users",
+		"api":    "/api",
+		"apiish": "/apiary",
+		"asset":  "/static/logo.png",
+		"home":   "/home",
+		"file":   "report.final.pdf",
+		"num":    1042,
+		"null":   nil,
+	}
+
+	tests := []struct {
+		name   string
+		key    string
+		op     Operator
+		value  interface{}
+		expect bool
+	}{
+		{"overlapping prefixes", "users", OperatorPrefixIn, routes, true},
+		{"exact prefix", "api", OperatorPrefixIn, routes, true},
+		{"prefix is not a path segment", "apiish", OperatorPrefixIn, routes, true},
+		{"later prefix", "asset", OperatorPrefixIn, routes, true},
+		{"no prefix matches", "home", OperatorPrefixIn, routes, false},
+		{"string slice", "home", OperatorPrefixIn, []string{"/x", "/h"}, true},
+		{"single prefix", "asset", OperatorPrefixIn, "/static", true},
+		{"empty list", "users", OperatorPrefixIn, []interface{}{}, false},
+		{"nil prefix skipped", "users", OperatorPrefixIn, []interface{}{nil, "/api"}, true},
+		{"number", "num", OperatorPrefixIn, []interface{}{"10"}, true},
+		{"null field", "null", OperatorPrefixIn, routes, false},
+		{"missing key", "missing", OperatorPrefixIn, routes, false},
+		{"suffix matches", "file", OperatorSuffixIn, []interface{}{".doc", ".pdf"}, true},
+		{"overlapping suffixes", "file", OperatorSuffixIn, []interface{}{"final.pdf", ".pdf"}, true},
+		{"no suffix matches", "file", OperatorSuffixIn, []interface{}{".doc", ".txt"}, false},
+		{"suffix empty list", "file", OperatorSuffixIn, []string{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := evalSingleCondition(tt.key, tt.op, tt.value, data)
+			if result != tt.expect {
+				t.Errorf("evalSingleCondition(%s, %s, %v) = %v, want %v", tt.key, tt.op, tt.value, result, tt.expect)
+			}
+		})
+	}
+}
+
+func TestKeyInOperator(t *testing.T) {
+	allowedPlans := map[string]int{"pro": 10, "team": 50}
+	data := map[string]interface{}{
+		"plan":         "pro",
+		"free":         "free",
+		"seats":        10,
+		"tier":         2,
+		"allowedPlans": allowedPlans,
+		"tiers":        map[int]string{1: "basic", 2: "plus"},
+		"null":         nil,
+	}
+
+	tests := []struct {
+		name   string
+		key    string
+		value  interface{}
+		expect bool
+	}{
+		{"key present", "plan", allowedPlans, true},
+		{"key absent", "free", allowedPlans, false},
+		{"map values are not keys", "seats", allowedPlans, false},
+		{"field reference", "plan", Ref("allowedPlans"), true},
+		{"field reference absent", "free", Ref("allowedPlans"), false},
+		{"missing reference", "plan", Ref("missingPlans"), false},
+		{"int keys", "tier", Ref("tiers"), true},
+		{"numeric string key", "tier", map[string]bool{"2": true}, true},
+		{"empty map", "plan", map[string]int{}, false},
+		{"slice is not a map", "plan", []string{"pro"}, false},
+		{"string is not a map", "plan", "pro,team", false},
+		{"null field", "null", allowedPlans, false},
+		{"missing key", "missing", allowedPlans, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := evalSingleCondition(tt.key, OperatorKeyIn, tt.value, data)
+			if result != tt.expect {
+				t.Errorf("evalSingleCondition(%s, key_in, %v) = %v, want %v", tt.key, tt.value, result, tt.expect)
+			}
+		})
+	}
+}
+
+func TestLike_RegexpCharactersAreLiteral(t *testing.T) {
+	data := map[string]interface{}{"expr": "a+b (c)", "multi": "line1\nline2"}
+
+	if !evalSingleCondition("expr", OperatorLike, "a+b (%)", data) {
+		t.Error("Regexp metacharacters in LIKE patterns should match literally")
+	}
+	if evalSingleCondition("expr", OperatorLike, "a.b%", data) {
+		t.Error("A dot in a LIKE pattern should not match any character")
+	}
+	if !evalSingleCondition("multi", OperatorLike, "line1%", data) {
+		t.Error("% should match across newlines")
+	}
+}
+
+func TestStringOperators_ByteValues(t *testing.T) {
+	data := map[string]interface{}{
+		"bytes": []byte("hello world"),
+		"raw":   json.RawMessage("hello world"),
+	}
+
+	for _, key := range []string{"bytes", "raw"} {
+		tests := []struct {
+			op     Operator
+			value  interface{}
+			expect bool
+		}{
+			{OperatorContains, "lo wo", true},
+			{OperatorContains, "bye", false},
+			{OperatorStartsWith, "hello", true},
+			{OperatorEndsWith, "world", true},
+			{OperatorLike, "hello%", true},
+			{OperatorIlike, "HELLO%", true},
+			{OperatorEq, "hello world", true},
+			{OperatorIn, []interface{}{"hello world"}, true},
+		}
+		for _, tt := range tests {
+			t.Run(fmt.Sprintf("%s %s", key, tt.op), func(t *testing.T) {
+				if result := evalSingleCondition(key, tt.op, tt.value, data); result != tt.expect {
+					t.Errorf("evalSingleCondition(%s, %s, %v) = %v, want %v", key, tt.op, tt.value, result, tt.expect)
+				}
+			})
+		}
+	}
+}
+
+type namedScore int32
+
+func TestNaNAndInf(t *testing.T) {
+	nan, inf := math.NaN(), math.Inf(1)
+	data := map[string]interface{}{
+		"nan":    nan,
+		"nan32":  float32(nan),
+		"inf":    inf,
+		"neginf": math.Inf(-1),
+		"name":   "Nan",
+		"text":   "Inf",
+	}
+
+	tests := []struct {
+		key    string
+		op     Operator
+		value  interface{}
+		expect bool
+	}{
+		{"nan", OperatorEq, nan, false},
+		{"nan", OperatorNeq, nan, true},
+		{"nan", OperatorEq, 0, false},
+		{"nan", OperatorEq, "NaN", false},
+		{"nan32", OperatorEq, nan, false},
+		{"nan", OperatorIn, []interface{}{nan, 1}, false},
+		{"nan", OperatorNin, []interface{}{nan, 1}, true},
+		{"nan", OperatorGt, 0, false},
+		{"nan", OperatorGte, 0, false},
+		{"nan", OperatorLt, 0, false},
+		{"nan", OperatorLte, 0, false},
+		{"nan", OperatorLte, nan, false},
+		{"nan", OperatorBetween, []interface{}{-1e308, 1e308}, false},
+		{"inf", OperatorBetween, []interface{}{0, nan}, false},
+		{"nan", OperatorIsnotnull, nil, true},
+		{"inf", OperatorEq, inf, true},
+		{"inf", OperatorGt, math.MaxFloat64, true},
+		{"inf", OperatorGte, inf, true},
+		{"inf", OperatorLt, 0, false},
+		{"inf", OperatorGt, math.Inf(-1), true},
+		{"neginf", OperatorLt, -math.MaxFloat64, true},
+		{"inf", OperatorBetween, []interface{}{0, nil}, true},
+		{"inf", OperatorIsInteger, nil, false},
+		{"name", OperatorEq, "Nan", true},
+		{"name", OperatorEq, nan, false},
+		{"name", OperatorEq, 0, false},
+		{"text", OperatorEq, inf, false},
+		{"text", OperatorEq, "Inf", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %s %v", tt.key, tt.op, tt.value), func(t *testing.T) {
+			if result := evalSingleCondition(tt.key, tt.op, tt.value, data); result != tt.expect {
+				t.Errorf("evalSingleCondition(%s, %s, %v) = %v, want %v", tt.key, tt.op, tt.value, result, tt.expect)
+			}
+		})
+	}
+
+	e := NewEvaluator()
+	e.FloatTolerance = 1e-9
+	if !e.EvaluateCondition(NewSimpleCondition("inf", OperatorEq, inf), data) {
+		t.Error("expected Inf to equal Inf with FloatTolerance")
+	}
+	if e.EvaluateCondition(NewSimpleCondition("nan", OperatorEq, nan), data) {
+		t.Error("expected NaN to equal nothing with FloatTolerance")
+	}
+}
+
+func TestNumericOperators_CrossWidth(t *testing.T) {
+	five := []interface{}{
+		int(5), int8(5), int16(5), int32(5), int64(5),
+		uint(5), uint8(5), uint16(5), uint32(5), uint64(5),
+		float32(5), float64(5), namedScore(5),
+	}
+
+	for _, v := range five {
+		for _, value := range five {
+			name := fmt.Sprintf("%T %T", v, value)
+			data := map[string]interface{}{"n": v}
+			t.Run(name, func(t *testing.T) {
+				if !evalSingleCondition("n", OperatorEq, value, data) {
+					t.Errorf("%T(5) == %T(5) = false, want true", v, value)
+				}
+				if evalSingleCondition("n", OperatorNeq, value, data) {
+					t.Errorf("%T(5) != %T(5) = true, want false", v, value)
+				}
+				if !evalSingleCondition("n", OperatorIn, []interface{}{1, value}, data) {
+					t.Errorf("%T(5) in [1, %T(5)] = false, want true", v, value)
+				}
+				if !evalSingleCondition("n", OperatorIn, map[interface{}]bool{value: true}, data) {
+					t.Errorf("%T(5) in map keyed by %T(5) = false, want true", v, value)
+				}
+				if !evalSingleCondition("n", OperatorBetween, []interface{}{value, value}, data) {
+					t.Errorf("%T(5) between %T(5) and %T(5) = false, want true", v, value, value)
+				}
+				if evalSingleCondition("n", OperatorGt, value, data) || evalSingleCondition("n", OperatorLt, value, data) {
+					t.Errorf("%T(5) ordered differently from %T(5)", v, value)
+				}
+			})
+		}
+	}
+
+	tests := []struct {
+		name   string
+		v      interface{}
+		op     Operator
+		value  interface{}
+		expect bool
+	}{
+		{"float32 fraction equals float64", float32(0.1), OperatorEq, 0.1, true},
+		{"float32 fraction in float64 list", float32(0.1), OperatorIn, []interface{}{0.1, 0.2}, true},
+		{"float32 fraction between", float32(0.1), OperatorBetween, []interface{}{0.1, 0.2}, true},
+		{"float32 fraction not greater", float32(0.1), OperatorGt, 0.1, false},
+		{"large int64 vs uint64 differ", int64(9007199254740993), OperatorEq, uint64(9007199254740992), false},
+		{"large int64 ordered exactly", int64(9007199254740993), OperatorGt, uint64(9007199254740992), true},
+		{"large uint64 above negative", uint64(1 << 63), OperatorGt, int64(-1), true},
+		{"negative ints across widths", int8(-3), OperatorLt, int64(-2), true},
+		{"named type compared numerically", namedScore(10), OperatorGt, 9, true},
+		{"named type between", namedScore(10), OperatorBetween, []interface{}{9, 11}, true},
+		{"json number in int list", float64(3), OperatorIn, []int{1, 2, 3}, true},
+		{"int in json number list", int(3), OperatorIn, []interface{}{1.0, 2.0, 3.0}, true},
+		{"int equals json.Number", 20, OperatorEq, json.Number("20"), true},
+		{"int equals json.Number exponent", 20, OperatorEq, json.Number("2e1"), true},
+		{"int above json.Number", 100, OperatorGte, json.Number("20"), true},
+		{"large int64 vs json.Number differ", int64(9007199254740993), OperatorEq, json.Number("9007199254740992"), false},
+		{"int between json.Number bounds", 5, OperatorBetween, []interface{}{json.Number("1"), json.Number("10")}, true},
+		{"fraction not equal to int", 3.5, OperatorEq, int64(3), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := map[string]interface{}{"n": tt.v}
+			if result := evalSingleCondition("n", tt.op, tt.value, data); result != tt.expect {
+				t.Errorf("%T(%v) %s %v = %v, want %v", tt.v, tt.v, tt.op, tt.value, result, tt.expect)
+			}
+		})
+	}
+}
+
+func TestPointerFieldValues(t *testing.T) {
+	age := 25
+	name := "Alice"
+	score := 9.5
+	active := true
+	created := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	agePtr := &age
+
+	data := map[string]interface{}{
+		"age":       &age,
+		"name":      &name,
+		"score":     &score,
+		"active":    &active,
+		"created":   &created,
+		"ageptrptr": &agePtr,
+		"nilint":    (*int)(nil),
+		"nilstring": (*string)(nil),
+		"niltime":   (*time.Time)(nil),
+	}
+
+	tests := []struct {
+		key    string
+		op     Operator
+		value  interface{}
+		expect bool
+	}{
+		{"age", OperatorEq, 25, true},
+		{"age", OperatorGt, 18, true},
+		{"age", OperatorIn, []interface{}{25.0, 30.0}, true},
+		{"age", OperatorBetween, []interface{}{20, 30}, true},
+		{"ageptrptr", OperatorEq, 25, true},
+		{"name", OperatorEq, "Alice", true},
+		{"name", OperatorStartsWith, "Al", true},
+		{"score", OperatorGte, 9.5, true},
+		{"active", OperatorIsTrue, nil, true},
+		{"active", OperatorEq, true, true},
+		{"created", OperatorGt, "2024-01-01", true},
+		{"created", OperatorYearEq, 2024, true},
+		{"age", OperatorIsnotnull, nil, true},
+		{"age", OperatorIsnull, nil, false},
+		// Nil pointers behave like null
+		{"nilint", OperatorIsnull, nil, true},
+		{"nilint", OperatorIsnotnull, nil, false},
+		{"nilstring", OperatorIsnull, nil, true},
+		{"nilstring", OperatorIsEmpty, nil, true},
+		{"niltime", OperatorIsnull, nil, true},
+		{"nilint", OperatorEq, 0, false},
+		{"nilint", OperatorGt, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %s", tt.key, tt.op), func(t *testing.T) {
+			if result := evalSingleCondition(tt.key, tt.op, tt.value, data); result != tt.expect {
+				t.Errorf("evalSingleCondition(%s, %s, %v) = %v, want %v", tt.key, tt.op, tt.value, result, tt.expect)
+			}
+		})
+	}
+}
+
+func TestPointerConditionValues(t *testing.T) {
+	age, low, high := 25, 18, 30
+	name := "Alice"
+	created := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	agePtr := &age
+	var nilInt *int
+
+	data := map[string]interface{}{
+		"age":     25,
+		"name":    "Alice",
+		"created": "2024-06-01",
+		"nothing": nil,
+	}
+
+	tests := []struct {
+		name   string
+		key    string
+		op     Operator
+		value  interface{}
+		expect bool
+	}{
+		{"equal", "age", OperatorEq, &age, true},
+		{"not equal", "age", OperatorNeq, &age, false},
+		{"pointer to pointer", "age", OperatorEq, &agePtr, true},
+		{"ordered", "age", OperatorGte, &low, true},
+		{"string", "name", OperatorEq, &name, true},
+		{"text operator", "name", OperatorStartsWith, &name, true},
+		{"time", "created", OperatorGt, &created, true},
+		{"nil pointer is null", "nothing", OperatorEq, nilInt, true},
+		{"nil pointer is not a number", "age", OperatorEq, nilInt, false},
+		{"in elements", "age", OperatorIn, []*int{&low, &age}, true},
+		{"in mixed elements", "age", OperatorIn, []interface{}{&low, 25.0}, true},
+		{"nin elements", "age", OperatorNin, []*int{&low, &high}, true},
+		{"in nil element", "age", OperatorIn, []*int{nil, &low}, false},
+		{"pointer to list", "age", OperatorIn, &[]int{18, 25}, true},
+		{"between bounds", "age", OperatorBetween, []*int{&low, &high}, true},
+		{"between map bounds", "age", OperatorBetween, map[string]interface{}{"min": &high, "max": &low}, true},
+		{"between nil bound is open", "age", OperatorBetween, []interface{}{nilInt, &high}, true},
+		{"notbetween bounds", "age", OperatorNotBetween, []*int{&low, &high}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := evalSingleCondition(tt.key, tt.op, tt.value, data); result != tt.expect {
+				t.Errorf("evalSingleCondition(%s, %s, %v) = %v, want %v", tt.key, tt.op, tt.value, result, tt.expect)
+			}
+		})
+	}
+
+	params := NewEvaluator()
+	params.Params = map[string]interface{}{"adult": &low}
+	if !params.EvaluateCondition(NewSimpleCondition("age", OperatorGte, ParamRef("adult")), data) {
+		t.Error("expected a pointer parameter to be dereferenced")
+	}
+}
+
+func TestSignOperators(t *testing.T) {
+	data := map[string]interface{}{
+		"amount":  120.5,
+		"balance": -30,
+		"delta":   0,
+		"count":   uint8(3),
+		"text":    "-2",
+		"zeroStr": "0.0",
+		"name":    "alice",
+		"flag":    true,
+		"nil":     nil,
+	}
+
+	tests := []struct {
+		key                      string
+		positive, negative, zero bool
+	}{
+		{"amount", true, false, false},
+		{"balance", false, true, false},
+		{"delta", false, false, true},
+		{"count", true, false, false},
+		{"text", false, true, false},
+		{"zeroStr", false, false, true},
+		// Non-numeric and missing values have no sign
+		{"name", false, false, false},
+		{"flag", false, false, false},
+		{"nil", false, false, false},
+		{"missing", false, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			for op, expect := range map[Operator]bool{
+				OperatorIsPositive: tt.positive,
+				OperatorIsNegative: tt.negative,
+				OperatorIsZero:     tt.zero,
+			} {
+				// Value is ignored
+				if result := evalSingleCondition(tt.key, op, 100, data); result != expect {
+					t.Errorf("%s %s = %v, want %v", tt.key, op, result, expect)
+				}
+			}
+		})
+	}
+}
+
+func TestBetween_MapBounds(t *testing.T) {
+	data := map[string]interface{}{"age": 25, "score": 88.5}
+
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(`{"min": 18, "max": 30}`), &decoded); err != nil {
+		t.Fatalf("unmarshal bounds: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		key    string
+		op     Operator
+		value  interface{}
+		expect bool
+	}{
+		{"inside", "age", OperatorBetween, map[string]interface{}{"min": 18, "max": 30}, true},
+		{"inclusive min", "age", OperatorBetween, map[string]interface{}{"min": 25, "max": 30}, true},
+		{"inclusive max", "age", OperatorBetween, map[string]interface{}{"min": 18, "max": 25}, true},
+		{"outside", "age", OperatorBetween, map[string]interface{}{"min": 30, "max": 40}, false},
+		{"swapped bounds", "age", OperatorBetween, map[string]interface{}{"min": 30, "max": 18}, true},
+		{"float field", "score", OperatorBetween, map[string]interface{}{"min": 80, "max": 90.5}, true},
+		{"decoded JSON", "age", OperatorBetween, decoded, true},
+		{"notbetween inside", "age", OperatorNotBetween, map[string]interface{}{"min": 18, "max": 30}, false},
+		{"notbetween swapped outside", "age", OperatorNotBetween, map[string]interface{}{"min": 40, "max": 30}, true},
+		{"missing max", "age", OperatorBetween, map[string]interface{}{"min": 18}, false},
+		{"wrong keys", "age", OperatorBetween, map[string]interface{}{"from": 18, "to": 30}, false},
+		{"missing field", "missing", OperatorBetween, map[string]interface{}{"min": 18, "max": 30}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := evalSingleCondition(tt.key, tt.op, tt.value, data); result != tt.expect {
+				t.Errorf("evalSingleCondition(%s, %s, %v) = %v, want %v", tt.key, tt.op, tt.value, result, tt.expect)
+			}
+		})
+	}
+}
+
+func TestBetween_OpenBounds(t *testing.T) {
+	data := map[string]interface{}{"amount": 150, "small": 50, "name": "mango", "null": nil}
+
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(`[100, null]`), &decoded); err != nil {
+		t.Fatalf("unmarshal bounds: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		key    string
+		op     Operator
+		value  interface{}
+		expect bool
+	}{
+		{"nil max above min", "amount", OperatorBetween, []interface{}{100, nil}, true},
+		{"nil max at min", "amount", OperatorBetween, []interface{}{150, nil}, true},
+		{"nil max below min", "small", OperatorBetween, []interface{}{100, nil}, false},
+		{"nil min below max", "small", OperatorBetween, []interface{}{nil, 100}, true},
+		{"nil min at max", "small", OperatorBetween, []interface{}{nil, 50}, true},
+		{"nil min above max", "amount", OperatorBetween, []interface{}{nil, 100}, false},
+		{"both nil", "amount", OperatorBetween, []interface{}{nil, nil}, true},
+		{"both nil string", "name", OperatorBetween, []interface{}{nil, nil}, true},
+		{"both nil null field", "null", OperatorBetween, []interface{}{nil, nil}, false},
+		{"both nil missing field", "missing", OperatorBetween, []interface{}{nil, nil}, false},
+		{"map null max", "amount", OperatorBetween, map[string]interface{}{"min": 100, "max": nil}, true},
+		{"map null min", "amount", OperatorBetween, map[string]interface{}{"min": nil, "max": 100}, false},
+		{"decoded JSON", "amount", OperatorBetween, decoded, true},
+		{"string bound", "name", OperatorBetween, []interface{}{"m", nil}, true},
+		{"notbetween nil max", "small", OperatorNotBetween, []interface{}{100, nil}, true},
+		{"notbetween nil min", "small", OperatorNotBetween, []interface{}{nil, 100}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := evalSingleCondition(tt.key, tt.op, tt.value, data); result != tt.expect {
+				t.Errorf("evalSingleCondition(%s, %s, %v) = %v, want %v", tt.key, tt.op, tt.value, result, tt.expect)
+			}
+		})
+	}
+}
+
+func TestNewInAndBetweenCondition(t *testing.T) {
+	data := map[string]interface{}{"status": "pending", "age": 30, "score": 1.5}
+
+	tests := []struct {
+		name   string
+		cond   Conditions
+		expect bool
+	}{
+		{"in", NewInCondition("status", "active", "pending"), true},
+		{"not in", NewInCondition("status", "active", "closed"), false},
+		{"single value", NewInCondition("status", "pending"), true},
+		{"no values", NewInCondition("status"), false},
+		{"mixed types", NewInCondition("age", "x", 30.0), true},
+		{"between", NewBetweenCondition("age", 18, 65), true},
+		{"between bounds inclusive", NewBetweenCondition("age", 30, 30), true},
+		{"outside", NewBetweenCondition("age", 40, 65), false},
+		{"open max", NewBetweenCondition("score", 1, nil), true},
+		{"open min", NewBetweenCondition("score", nil, 1), false},
+		{"in a group", NewAndGroup(NewInCondition("status", "pending"), NewBetweenCondition("age", 18, 65)), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := EvaluateCondition(tt.cond, data); result != tt.expect {
+				t.Errorf("EvaluateCondition() = %v, want %v", result, tt.expect)
+			}
+		})
+	}
+
+	want := Conditions{Key: "status", Operator: OperatorIn, Value: []interface{}{"active", "pending"}}
+	if got := NewInCondition("status", "active", "pending"); !reflect.DeepEqual(got, want) {
+		t.Errorf("NewInCondition() = %+v, want %+v", got, want)
+	}
+	want = Conditions{Key: "age", Operator: OperatorBetween, Value: []interface{}{18, 65}}
+	if got := NewBetweenCondition("age", 18, 65); !reflect.DeepEqual(got, want) {
+		t.Errorf("NewBetweenCondition() = %+v, want %+v", got, want)
+	}
+	if err := ValidateConditions(NewInCondition("status")); err != nil {
+		t.Errorf("ValidateConditions(empty in) = %v", err)
+	}
+}
+
+func TestTypedBuilders(t *testing.T) {
+	type tier string
+	joined := time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC)
+	countries := []string{"TH", "SG"}
+
+	tests := []struct {
+		name    string
+		typed   Conditions
+		untyped Conditions
+	}{
+		{"eq", Eq("status", "active"), NewSimpleCondition("status", OperatorEq, "active")},
+		{"neq", Neq("age", 30), NewSimpleCondition("age", OperatorNeq, 30)},
+		{"gt", Gt("age", 18), NewSimpleCondition("age", OperatorGt, 18)},
+		{"gte float", Gte("score", 1.5), NewSimpleCondition("score", OperatorGte, 1.5)},
+		{"lt string", Lt("status", "b"), NewSimpleCondition("status", OperatorLt, "b")},
+		{"lte duration", Lte("session", 90*time.Minute), NewSimpleCondition("session", OperatorLte, 90*time.Minute)},
+		{"gt time", Gt("joined", joined), NewSimpleCondition("joined", OperatorGt, joined)},
+		{"named type", Eq("tier", tier("gold")), NewSimpleCondition("tier", OperatorEq, tier("gold"))},
+		{"between", Between("age", 18, 65), NewBetweenCondition("age", 18, 65)},
+		{"in", In("status", "active", "pending"), NewInCondition("status", "active", "pending")},
+		{"in slice", In("country", countries...), NewInCondition("country", "TH", "SG")},
+		{"in ints", In("age", 20, 30), NewInCondition("age", 20, 30)},
+		{"in nothing", In[string]("status"), NewInCondition("status")},
+		{"nin", Nin("country", "US"), NewSimpleCondition("country", OperatorNin, []interface{}{"US"})},
+	}
+
+	rows := []map[string]interface{}{
+		{"status": "active", "age": 30, "score": 1.5, "session": "1h", "joined": "2024-01-01", "tier": "gold", "country": "TH"},
+		{"status": "closed", "age": 16, "score": 0.5, "session": 2 * time.Hour, "joined": joined, "tier": "silver", "country": "US"},
+		{"status": "pending", "age": 65, "score": 1.5, "session": 90 * time.Minute, "joined": "2020-01-01T00:00:00Z", "country": "SG"},
+		{},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !reflect.DeepEqual(tt.typed, tt.untyped) {
+				t.Errorf("typed = %+v, want %+v", tt.typed, tt.untyped)
+			}
+			for _, row := range rows {
+				if got, want := EvaluateCondition(tt.typed, row), EvaluateCondition(tt.untyped, row); got != want {
+					t.Errorf("EvaluateCondition(typed, %v) = %v, untyped = %v", row, got, want)
+				}
+			}
+		})
+	}
+
+	rule := NewAndGroup(Gte("age", 18), In("country", countries...), Between("score", 1.0, 2.0))
+	if !EvaluateCondition(rule, rows[0]) || EvaluateCondition(rule, rows[1]) {
+		t.Error("expected typed builders to compose into groups")
+	}
+}
+
+func TestImpliesGroup(t *testing.T) {
+	// A minor needs guardian consent
+	rule := NewImpliesGroup(
+		NewSimpleCondition("age", OperatorLt, 18),
+		NewSimpleCondition("guardian_consent", OperatorIsTrue, nil),
+	)
+
+	tests := []struct {
+		name   string
+		data   map[string]interface{}
+		expect bool
+		tri    TriState
+	}{
+		{"minor with consent", map[string]interface{}{"age": 15, "guardian_consent": true}, true, TriStateTrue},
+		{"minor without consent", map[string]interface{}{"age": 15, "guardian_consent": false}, false, TriStateFalse},
+		{"adult with consent", map[string]interface{}{"age": 30, "guardian_consent": true}, true, TriStateTrue},
+		{"adult without consent", map[string]interface{}{"age": 30, "guardian_consent": false}, true, TriStateTrue},
+		{"adult, consent unknown", map[string]interface{}{"age": 30}, true, TriStateTrue},
+		{"minor, consent unknown", map[string]interface{}{"age": 15}, false, TriStateUnknown},
+		{"age unknown, consent given", map[string]interface{}{"guardian_consent": true}, true, TriStateTrue},
+		{"age unknown, no consent", map[string]interface{}{"guardian_consent": false}, true, TriStateUnknown},
+	}
+
+	groupRule := ConvertToConditionGroup(rule)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := EvaluateCondition(rule, tt.data); result != tt.expect {
+				t.Errorf("EvaluateCondition() = %v, want %v", result, tt.expect)
+			}
+			if result := EvaluateConditionGroup(groupRule, tt.data); result != tt.expect {
+				t.Errorf("EvaluateConditionGroup(converted) = %v, want %v", result, tt.expect)
+			}
+			if result := EvaluateTriState(rule, tt.data); result != tt.tri {
+				t.Errorf("EvaluateTriState() = %v, want %v", result, tt.tri)
+			}
+		})
+	}
+
+	// The consequent is only evaluated for a true antecedent
+	var evaluated []string
+	e := NewEvaluator()
+	e.OnEvaluate = func(key string, op Operator, result bool, dur time.Duration) {
+		evaluated = append(evaluated, key)
+	}
+	e.EvaluateCondition(rule, map[string]interface{}{"age": 30})
+	if !reflect.DeepEqual(evaluated, []string{"age"}) {
+		t.Errorf("evaluated %v, want only the antecedent", evaluated)
+	}
+
+	var parsed Conditions
+	if err := json.Unmarshal([]byte(`{"logic": "IMPLIES", "children": [
+		{"key": "age", "operator": "<", "value": 18},
+		{"key": "guardian_consent", "operator": "istrue"}
+	]}`), &parsed); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if err := ValidateConditions(parsed); err != nil {
+		t.Errorf("ValidateConditions() = %v", err)
+	}
+	if EvaluateCondition(parsed, map[string]interface{}{"age": 15}) {
+		t.Error("expected a minor without consent to fail the parsed rule")
+	}
+
+	wrongArity := Conditions{Logic: LogicImplies, Children: []Conditions{NewSimpleCondition("age", OperatorLt, 18)}}
+	if err := ValidateConditions(wrongArity); !errors.Is(err, ErrImpliesArity) {
+		t.Errorf("ValidateConditions() = %v, want ErrImpliesArity", err)
+	}
+	if EvaluateCondition(wrongArity, map[string]interface{}{"age": 30}) {
+		t.Error("expected an IMPLIES group without two children to be false")
+	}
+}
+
+func TestAtLeastGroup(t *testing.T) {
+	data := map[string]interface{}{
+		"age":     25,
+		"country": "TH",
+		"score":   40,
+		"vip":     false,
+	}
+	// Two of these four are true
+	children := []Conditions{
+		NewSimpleCondition("age", OperatorGte, 18),
+		NewSimpleCondition("country", OperatorEq, "TH"),
+		NewSimpleCondition("score", OperatorGt, 50),
+		NewSimpleCondition("vip", OperatorIsTrue, nil),
+	}
+
+	tests := []struct {
+		name   string
+		cond   Conditions
+		expect bool
+	}{
+		{"zero threshold", NewAtLeastGroup(0, children...), true},
+		{"zero threshold without children", NewAtLeastGroup(0), true},
+		{"below passing count", NewAtLeastGroup(1, children...), true},
+		{"equal to passing count", NewAtLeastGroup(2, children...), true},
+		{"above passing count", NewAtLeastGroup(3, children...), false},
+		{"threshold equals children", NewAtLeastGroup(4, children...), false},
+		{"threshold equals children all true", NewAtLeastGroup(2, children[:2]...), true},
+		{"threshold above children", NewAtLeastGroup(5, children...), false},
+		{"nested", NewAndGroup(NewAtLeastGroup(2, children...), NewSimpleCondition("age", OperatorLt, 30)), true},
+		{"negated", Negate(NewAtLeastGroup(3, children...)), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := EvaluateCondition(tt.cond, data); result != tt.expect {
+				t.Errorf("EvaluateCondition() = %v, want %v", result, tt.expect)
+			}
+			group := ConvertToConditionGroup(tt.cond)
+			if err := ValidateConditionGroup(group); err != nil {
+				t.Errorf("ValidateConditionGroup() of converted group = %v", err)
+			}
+			if result := EvaluateConditionGroup(group, data); result != tt.expect {
+				t.Errorf("EvaluateConditionGroup() of converted group = %v, want %v", result, tt.expect)
+			}
+		})
+	}
+
+	var cond Conditions
+	err := json.Unmarshal([]byte(`{
+		"logic": "ATLEAST",
+		"threshold": 2,
+		"children": [
+			{"key": "age", "operator": ">=", "value": 18},
+			{"key": "score", "operator": ">", "value": 50},
+			{"key": "country", "operator": "==", "value": "TH"}
+		]
+	}`), &cond)
+	if err != nil {
+		t.Fatalf("unmarshal condition: %v", err)
+	}
+	if !reflect.DeepEqual(cond, NewAtLeastGroup(2, NewSimpleCondition("age", OperatorGte, 18.0), NewSimpleCondition("score", OperatorGt, 50.0), NewSimpleCondition("country", OperatorEq, "TH"))) {
+		t.Errorf("Unexpected decoded condition: %+v", cond)
+	}
+	if !EvaluateCondition(cond, data) {
+		t.Error("Expected decoded ATLEAST group to be true")
+	}
+}
+
+func TestAtLeastGroup_ShortCircuit(t *testing.T) {
+	defer RestoreOperators(SnapshotOperators())
+	calls := 0
+	RegisterCustomOperator("counted", func(fieldValue, expectedValue interface{}) bool {
+		calls++
+		return expectedValue == true
+	})
+
+	data := map[string]interface{}{"x": 1}
+	leaf := func(result bool) Conditions { return NewSimpleCondition("x", "counted", result) }
+
+	// Stops once the threshold is reached
+	if !EvaluateCondition(NewAtLeastGroup(2, leaf(true), leaf(true), leaf(false), leaf(false)), data) || calls != 2 {
+		t.Errorf("Expected true after 2 calls, got %d calls", calls)
+	}
+
+	// Stops once the threshold can no longer be reached
+	calls = 0
+	if EvaluateCondition(NewAtLeastGroup(3, leaf(false), leaf(false), leaf(true), leaf(true)), data) || calls != 2 {
+		t.Errorf("Expected false after 2 calls, got %d calls", calls)
+	}
+}
+
+func TestBooleanComparison(t *testing.T) {
+	data := map[string]interface{}{
+		"yes":      true,
+		"no":       false,
+		"yesStr":   "true",
+		"noStr":    "No",
+		"one":      1,
+		"unknown":  "maybe",
+		"emptyStr": "",
+	}
+
+	tests := []struct {
+		key    string
+		op     Operator
+		value  interface{}
+		expect bool
+	}{
+		// == and != read truthy and falsy strings as booleans
+		{"yes", OperatorEq, true, true},
+		{"yes", OperatorEq, "true", true},
+		{"yes", OperatorEq, "YES", true},
+		{"yes", OperatorEq, "1", true},
+		{"yes", OperatorEq, "false", false},
+		{"no", OperatorEq, "false", true},
+		{"no", OperatorEq, "off", true},
+		{"no", OperatorEq, "0", true},
+		{"no", OperatorNeq, "true", true},
+		{"yesStr", OperatorEq, true, true},
+		{"noStr", OperatorEq, false, true},
+		// Unrecognized strings and numbers never equal a boolean
+		{"no", OperatorEq, "maybe", false},
+		{"unknown", OperatorEq, false, false},
+		{"unknown", OperatorNeq, false, true},
+		{"emptyStr", OperatorEq, false, false},
+		{"one", OperatorEq, true, false},
+		{"no", OperatorEq, 0, false},
+		{"yes", OperatorIn, []interface{}{"true", "yes"}, true},
+		{"no", OperatorIn, []interface{}{true}, false},
+		// Ordering puts false before true
+		{"yes", OperatorGt, false, true},
+		{"yes", OperatorGte, true, true},
+		{"no", OperatorLt, true, true},
+		{"no", OperatorGt, true, false},
+		{"yes", OperatorGt, "false", true},
+		{"no", OperatorLte, "false", true},
+		// Ordering a boolean against anything else is false
+		{"yes", OperatorGt, 0, false},
+		{"yes", OperatorLt, 5, false},
+		{"yes", OperatorGte, "maybe", false},
+		{"one", OperatorLte, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %s %v", tt.key, tt.op, tt.value), func(t *testing.T) {
+			if result := evalSingleCondition(tt.key, tt.op, tt.value, data); result != tt.expect {
+				t.Errorf("evalSingleCondition(%s, %s, %v) = %v, want %v", tt.key, tt.op, tt.value, result, tt.expect)
+			}
+		})
+	}
+}
+
+func TestDurationComparison(t *testing.T) {
+	data := map[string]interface{}{
+		"timeout":  "1h",
+		"interval": 90 * time.Second,
+		"retry":    "1m30s",
+		"count":    30,
+	}
+
+	tests := []struct {
+		key    string
+		op     Operator
+		value  interface{}
+		expect bool
+	}{
+		// "1h" < "30m" as strings, but not as durations
+		{"timeout", OperatorGt, "30m", true},
+		{"timeout", OperatorLt, "30m", false},
+		{"timeout", OperatorGte, "60m", true},
+		{"timeout", OperatorEq, "60m", true},
+		{"timeout", OperatorEq, time.Hour, true},
+		{"timeout", OperatorNeq, "59m", true},
+		{"interval", OperatorGt, "1m", true},
+		{"interval", OperatorEq, "1m30s", true},
+		{"interval", OperatorLt, 2 * time.Minute, true},
+		{"retry", OperatorEq, 90 * time.Second, true},
+		{"retry", OperatorBetween, []interface{}{"1m", "2m"}, true},
+		{"timeout", OperatorIn, []interface{}{"30m", "60m"}, true},
+		// Bare numbers are not durations
+		{"count", OperatorEq, "30ns", false},
+		{"count", OperatorLt, "1m", false},
+		{"timeout", OperatorEq, 3600, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %s %v", tt.key, tt.op, tt.value), func(t *testing.T) {
+			if result := evalSingleCondition(tt.key, tt.op, tt.value, data); result != tt.expect {
+				t.Errorf("evalSingleCondition(%s, %s, %v) = %v, want %v", tt.key, tt.op, tt.value, result, tt.expect)
+			}
+		})
+	}
+
+	e := NewEvaluator()
+	e.StrictCompare = true
+	if ok, err := e.EvaluateConditionE(NewSimpleCondition("timeout", OperatorGt, 30*time.Minute), data); !ok || err != nil {
+		t.Errorf("strict duration comparison = %v, %v, want true, nil", ok, err)
+	}
+}
+
+func TestIndexOf(t *testing.T) {
+	data := map[string]interface{}{
+		"code":  "AB-123-XY",
+		"bytes": []byte("hello"),
+		"num":   12345,
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(`["-", 2]`), &decoded); err != nil {
+		t.Fatalf("unmarshal spec: %v", err)
+	}
+
+	tests := []struct {
+		key    string
+		value  interface{}
+		expect bool
+	}{
+		{"code", []interface{}{"AB", 0}, true},
+		{"code", []interface{}{"AB", 1}, false},
+		{"code", []interface{}{"-", 2}, true},
+		{"code", decoded, true},
+		{"code", []interface{}{"123", 3}, true},
+		{"code", []interface{}{"-", 6}, false}, // first occurrence only
+		{"code", []interface{}{"ZZ", -1}, true},
+		{"code", []interface{}{"AB", -1}, false},
+		{"code", []interface{}{"ZZ", 0}, false},
+		{"code", []interface{}{"", 0}, true},
+		{"bytes", []interface{}{"l", 2}, true},
+		{"num", []interface{}{"3", 2}, true},
+		{"missing", []interface{}{"AB", -1}, false},
+		// Malformed specs never match
+		{"code", "AB", false},
+		{"code", []interface{}{"AB"}, false},
+		{"code", []interface{}{"AB", "first"}, false},
+		{"code", []interface{}{"AB", 0.5}, false},
+		{"code", []interface{}{nil, -1}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %v", tt.key, tt.value), func(t *testing.T) {
+			if result := evalSingleCondition(tt.key, OperatorIndexOf, tt.value, data); result != tt.expect {
+				t.Errorf("indexof(%s, %v) = %v, want %v", tt.key, tt.value, result, tt.expect)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package jsonvaluate
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+)
+
+// DerivedField computes the value of a derived field from the data being
+// evaluated, returning false if the value can't be computed, in which case
+// the field is treated as missing. It must not modify data.
+type DerivedField func(data map[string]interface{}) (interface{}, bool)
+
+// ErrNilDerivedField is returned by RegisterDerivedField for a nil function.
+var ErrNilDerivedField = errors.New("derived field function cannot be nil")
+
+// Thread-safe registry for derived fields
+var (
+	derivedFields      = make(map[string]DerivedField)
+	derivedFieldsMutex sync.RWMutex
+)
+
+// RegisterDerivedField registers a field computed from the data by fn, so
+// that conditions can use it as a key like any field of the data, replacing
+// any derived field registered under the same name. It returns
+// ErrNilDerivedField if fn is nil.
+//
+// Keys are resolved in this order: the key in the data, then the key as a
+// dot-separated path through the data, then the derived field of that name,
+// then the key as a path into the value of a derived field, so data always
+// shadows a derived field of the same name. A derived field is computed at
+// most once per evaluation, and only when a condition needs it.
+//
+// fn may evaluate conditions against the data it is given, including
+// conditions on other derived fields; a derived field that is needed again
+// while it is being computed is treated as missing rather than recursing.
+// Derived fields are computed from map data, so EvaluateConditionSource
+// only consults them when its source is a MapSource.
+//
+// Example:
+//
+//	RegisterDerivedField("age", func(data map[string]interface{}) (interface{}, bool) {
+//	    birthdate, err := time.Parse("2006-01-02", fmt.Sprint(data["birthdate"]))
+//	    if err != nil {
+//	        return nil, false
+//	    }
+//	    return int(time.Since(birthdate).Hours() / 24 / 365.25), true
+//	})
+//	NewSimpleCondition("age", OperatorGte, 18)
+func RegisterDerivedField(name string, fn DerivedField) error {
+	if fn == nil {
+		return ErrNilDerivedField
+	}
+
+	derivedFieldsMutex.Lock()
+	defer derivedFieldsMutex.Unlock()
+	derivedFields[name] = fn
+	return nil
+}
+
+// UnregisterDerivedField removes a derived field from the registry.
+func UnregisterDerivedField(name string) {
+	derivedFieldsMutex.Lock()
+	defer derivedFieldsMutex.Unlock()
+	delete(derivedFields, name)
+}
+
+// derivedResult is a computed derived field, or a value returned by the
+// Resolver, cached for the evaluation.
+type derivedResult struct {
+	value  interface{}
+	exists bool
+}
+
+// Derived fields being computed, by the copy of the data handed to their
+// function. Each computation gets its own copy, so a nested evaluation of
+// that copy can tell which derived fields are in progress, while concurrent
+// evaluations of the same data never see each other's computations.
+var (
+	derivingFields      = make(map[uintptr]map[string]bool)
+	derivingFieldsMutex sync.Mutex
+)
+
+// derive returns the value of the derived field name, computing it on first
+// use in the evaluation.
+func (ev *evaluation) derive(name string) (interface{}, bool) {
+	derivedFieldsMutex.RLock()
+	fn, ok := derivedFields[name]
+	derivedFieldsMutex.RUnlock()
+	if !ok || ev.source != nil {
+		return nil, false
+	}
+	if result, ok := ev.derived[name]; ok {
+		return result.value, result.exists
+	}
+
+	inProgress := derivingFieldsOf(ev.data)
+	if inProgress[name] {
+		return nil, false
+	}
+
+	view := make(map[string]interface{}, len(ev.data))
+	for k, v := range ev.data {
+		view[k] = v
+	}
+	id := reflect.ValueOf(view).Pointer()
+	derivingFieldsMutex.Lock()
+	derivingFields[id] = make(map[string]bool, len(inProgress)+1)
+	for field := range inProgress {
+		derivingFields[id][field] = true
+	}
+	derivingFields[id][name] = true
+	derivingFieldsMutex.Unlock()
+	defer func() {
+		derivingFieldsMutex.Lock()
+		delete(derivingFields, id)
+		derivingFieldsMutex.Unlock()
+	}()
+
+	v, exists := fn(view)
+	if ev.derived == nil {
+		ev.derived = make(map[string]derivedResult)
+	}
+	ev.derived[name] = derivedResult{value: v, exists: exists}
+	return v, exists
+}
+
+// derivingFieldsOf returns the derived fields in progress when data is the
+// copy handed to a derived field's function, or nil otherwise.
+func derivingFieldsOf(data map[string]interface{}) map[string]bool {
+	if data == nil {
+		return nil
+	}
+	derivingFieldsMutex.Lock()
+	defer derivingFieldsMutex.Unlock()
+	return derivingFields[reflect.ValueOf(data).Pointer()]
+}
@@ -0,0 +1,128 @@
+package jsonvaluate
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRegisterDerivedField(t *testing.T) {
+	defer UnregisterDerivedField("ageFromBirthdate")
+	defer UnregisterDerivedField("profile")
+
+	today := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	err := RegisterDerivedField("ageFromBirthdate", func(data map[string]interface{}) (interface{}, bool) {
+		birthdate, ok := toTime(data["birthdate"])
+		if !ok {
+			return nil, false
+		}
+		age := today.Year() - birthdate.Year()
+		if today.YearDay() < birthdate.YearDay() {
+			age--
+		}
+		return age, true
+	})
+	if err != nil {
+		t.Fatalf("RegisterDerivedField: %v", err)
+	}
+	if err := RegisterDerivedField("profile", func(data map[string]interface{}) (interface{}, bool) {
+		return map[string]interface{}{"name": data["first"], "vip": true}, true
+	}); err != nil {
+		t.Fatalf("RegisterDerivedField: %v", err)
+	}
+
+	adult := NewSimpleCondition("ageFromBirthdate", OperatorGt, 18)
+	tests := []struct {
+		name   string
+		cond   Conditions
+		data   map[string]interface{}
+		expect bool
+	}{
+		{"derived adult", adult, map[string]interface{}{"birthdate": "1990-03-15"}, true},
+		{"derived minor", adult, map[string]interface{}{"birthdate": "2010-03-15"}, false},
+		{"cannot compute", adult, map[string]interface{}{}, false},
+		{"missing when not computed", NewSimpleCondition("ageFromBirthdate", OperatorIsnull, nil), map[string]interface{}{}, true},
+		{"data shadows derived", adult, map[string]interface{}{"birthdate": "1990-03-15", "ageFromBirthdate": 12}, false},
+		{"path into derived value", NewSimpleCondition("profile.name", OperatorEq, "Ann"), map[string]interface{}{"first": "Ann"}, true},
+		{"nested data shadows derived", NewSimpleCondition("profile.vip", OperatorIsFalse, nil), map[string]interface{}{"profile": map[string]interface{}{"vip": false}}, true},
+		{"unregistered", NewSimpleCondition("unknownField", OperatorIsnull, nil), map[string]interface{}{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := EvaluateCondition(tt.cond, tt.data); result != tt.expect {
+				t.Errorf("EvaluateCondition() = %v, want %v", result, tt.expect)
+			}
+		})
+	}
+
+	if err := RegisterDerivedField("nothing", nil); !errors.Is(err, ErrNilDerivedField) {
+		t.Errorf("err = %v, want ErrNilDerivedField", err)
+	}
+
+	UnregisterDerivedField("profile")
+	if EvaluateCondition(NewSimpleCondition("profile.vip", OperatorIsTrue, nil), map[string]interface{}{}) {
+		t.Error("expected unregistered derived field to be missing")
+	}
+}
+
+func TestDerivedField_ComputedOncePerEvaluation(t *testing.T) {
+	defer UnregisterDerivedField("total")
+
+	calls := 0
+	RegisterDerivedField("total", func(data map[string]interface{}) (interface{}, bool) {
+		calls++
+		sum := 0.0
+		for _, key := range []string{"a", "b"} {
+			n, _ := toNumber(data[key])
+			sum += n
+		}
+		return sum, true
+	})
+
+	cond := NewAndGroup(
+		NewSimpleCondition("total", OperatorGt, 5),
+		NewSimpleCondition("total", OperatorLt, 10),
+		NewSimpleCondition("total", OperatorNeq, 7),
+	)
+	if !EvaluateCondition(cond, map[string]interface{}{"a": 3, "b": 5}) {
+		t.Error("expected 3 + 5 to be in (5, 10) and not 7")
+	}
+	if calls != 1 {
+		t.Errorf("derived field computed %d times, want 1", calls)
+	}
+}
+
+func TestDerivedField_Recursion(t *testing.T) {
+	defer UnregisterDerivedField("loop")
+	defer UnregisterDerivedField("ping")
+	defer UnregisterDerivedField("pong")
+	defer UnregisterDerivedField("double")
+
+	// A derived field that needs itself sees it as missing
+	RegisterDerivedField("loop", func(data map[string]interface{}) (interface{}, bool) {
+		return EvaluateCondition(NewSimpleCondition("loop", OperatorIsnull, nil), data), true
+	})
+	if !EvaluateCondition(NewSimpleCondition("loop", OperatorIsTrue, nil), map[string]interface{}{}) {
+		t.Error("expected loop to see itself as missing")
+	}
+
+	// So does a cycle through another derived field
+	RegisterDerivedField("ping", func(data map[string]interface{}) (interface{}, bool) {
+		return EvaluateCondition(NewSimpleCondition("pong", OperatorIsTrue, nil), data), true
+	})
+	RegisterDerivedField("pong", func(data map[string]interface{}) (interface{}, bool) {
+		return EvaluateCondition(NewSimpleCondition("ping", OperatorIsnotnull, nil), data), true
+	})
+	if !EvaluateCondition(NewSimpleCondition("ping", OperatorIsFalse, nil), map[string]interface{}{}) {
+		t.Error("expected the ping/pong cycle to be cut at ping")
+	}
+
+	// Derived fields can still build on each other
+	RegisterDerivedField("double", func(data map[string]interface{}) (interface{}, bool) {
+		return EvaluateCondition(NewSimpleCondition("loop", OperatorIsTrue, nil), data), true
+	})
+	if !EvaluateCondition(NewSimpleCondition("double", OperatorIsTrue, nil), map[string]interface{}{}) {
+		t.Error("expected double to read loop")
+	}
+}
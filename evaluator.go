@@ -0,0 +1,667 @@
+package jsonvaluate
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Evaluator evaluates condition trees with configurable behavior. Create one
+// with NewEvaluator, which applies the defaults used by the package-level
+// functions, then adjust its fields before use. An Evaluator must not be
+// modified while it is evaluating; it is otherwise safe for concurrent use.
+//
+// Example:
+//
+//	e := NewEvaluator()
+//	e.EmptyResult = false // an empty rule never passes
+//	ok := e.EvaluateCondition(cond, data)
+type Evaluator struct {
+	// EmptyResult is the result of evaluating an empty condition, i.e. one
+	// with neither Logic nor Key/Operator set, such as Conditions{}.
+	// Defaults to true. Empty groups are not affected: an empty AND group is
+	// always true and an empty OR group is always false.
+	EmptyResult bool
+
+	// Now returns the current time for relative time operators such as
+	// "within" and "olderthan". It is called at most once per evaluation.
+	// Defaults to time.Now when nil.
+	Now func() time.Time
+
+	// CaseInsensitiveKeys matches condition keys against data keys ignoring
+	// case. An exact match always wins; when several data keys differ only in
+	// case (e.g. "Age" and "AGE") and none matches exactly, the
+	// lexicographically smallest one is used. Nested keys such as
+	// "user.address.city" are matched ignoring case at every level.
+	CaseInsensitiveKeys bool
+
+	// TrimEmpty makes "isempty" and "isnotempty" treat whitespace-only
+	// strings such as "   " as empty. By default only "" is an empty string.
+	TrimEmpty bool
+
+	// HonorPrecedence makes EvaluateConditionGroup give AND precedence over
+	// OR, so "A OR B AND C" is "A OR (B AND C)" as in SQL. By default the
+	// NextLogic chain is folded from left to right: "(A OR B) AND C".
+	HonorPrecedence bool
+
+	// FloatTolerance is the maximum absolute difference at which numbers are
+	// considered equal by "==" and "!=", absorbing floating-point noise such
+	// as 85.49999999 vs 85.5. Defaults to 0, which requires exact equality.
+	FloatTolerance float64
+
+	// InDelimiter, when set, makes "in" and "nin" split a string value on the
+	// delimiter into a list, so "TH,SG,MY" with delimiter "," matches exactly
+	// "TH", "SG" or "MY". Elements are trimmed of surrounding whitespace.
+	// By default a string value is searched for the field as a substring,
+	// which "in_string" always does.
+	InDelimiter string
+
+	// SortBetweenBounds makes "between" and "notbetween" accept [max, min]
+	// bounds by swapping them into order, so [10, 1] means 1 to 10. By
+	// default out-of-order slice bounds match nothing. Bounds given as a
+	// {"min": ..., "max": ...} map are always put in order.
+	SortBetweenBounds bool
+
+	// StrictCompare makes ">", ">=", "<" and "<=" fail with ErrIncomparable,
+	// and so evaluate to false, unless both operands are numbers (numeric
+	// strings included), durations, times, strings or booleans. By default
+	// incomparable operands, such as 25 and "N/A", are compared as strings,
+	// which is deterministic but meaningless.
+	StrictCompare bool
+
+	// MaxDepth is the maximum nesting depth of groups, where a top-level
+	// group has depth 1. Evaluating a deeper tree fails with
+	// ErrMaxDepthExceeded, so EvaluateCondition returns false, instead of
+	// recursing without bound on rules from untrusted input, and the
+	// Evaluator's ValidateConditions and ValidateConditionGroup reject it with
+	// the same error. Defaults to
+	// DefaultMaxDepth when zero; a negative value disables the limit.
+	MaxDepth int
+
+	// BatchWorkers is the number of goroutines EvaluateBatch uses to evaluate
+	// rows in parallel. Defaults to 0, which evaluates rows one after another
+	// on the calling goroutine; parallelism pays off for large batches or
+	// expensive custom operators, which must then be safe for concurrent use.
+	BatchWorkers int
+
+	// OnEvaluate, when set, is called after each single condition is
+	// evaluated with its key, operator, result and the time the evaluation
+	// took, e.g. to count which rules fire or to time custom operators. A
+	// condition that fails with an error, including a custom operator that
+	// panics, is reported as false. The result is that of the operator, before
+	// any negation by an enclosing NOT. With BatchWorkers set the callback is
+	// called from several goroutines and must be safe for concurrent use.
+	OnEvaluate func(key string, op Operator, result bool, dur time.Duration)
+
+	// Params holds static parameters, such as feature flags or the deployment
+	// region, that conditions refer to with a ParamRef value instead of
+	// merging them into every data map. A parameter may be a lazy value, a
+	// func() interface{}, like a data value.
+	Params map[string]interface{}
+
+	// NoStringNumberCoercion stops "==", "!=", "in" and "nin" from reading
+	// strings as numbers, so that opaque string IDs such as the ZIP code "012"
+	// equal neither 12 nor "12". A string then never equals a number, and two
+	// strings are equal only when their text is (duration strings such as
+	// "1h" and "60m" still compare as durations). By default "25" == 25.
+	NoStringNumberCoercion bool
+
+	// TrimIn makes "in" and "nin" ignore whitespace around strings, both the
+	// field and the elements of the collection, so a user-entered " TH "
+	// matches ["TH", "SG"] and "TH" matches [" TH "]. By default strings must
+	// match exactly.
+	TrimIn bool
+
+	// StrictContains makes "contains" and "ncontains" search only strings,
+	// for a substring, and slices and arrays, for an element equal to the
+	// value; on any other field, such as the number 12345, both fail with
+	// ErrNotContainer and so evaluate to false. By default both operands are
+	// converted to text, so 12345 contains 234 and ["ab", "c"] contains "b c";
+	// "str_contains" always does that.
+	StrictContains bool
+
+	// Resolver, when set, is called with the key of a condition, or of a
+	// FieldRef, that neither the data nor a derived field has, to fetch the
+	// value just in time, e.g. from a cache or database. It returns false if
+	// the key doesn't exist, in which case the field is missing as usual.
+	// Resolver is called at most once per key per evaluation, and only for
+	// conditions that are evaluated, so a short-circuited branch never
+	// triggers a fetch. With BatchWorkers set it must be safe for concurrent
+	// use.
+	Resolver func(key string) (interface{}, bool)
+
+	// ZeroIsEmpty makes "isempty" and "isnotempty" treat the zero value of a
+	// number, a bool and a time.Time as empty, so 0, 0.0, false and
+	// time.Time{} are empty. Strings such as "0" are still only empty when
+	// they are "". By default numbers, booleans and times are never empty.
+	ZeroIsEmpty bool
+
+	// AllowedOperators, when not nil, lists the only operators conditions may
+	// use, e.g. to keep rules from untrusted sources to cheap comparisons
+	// without regular expressions or custom operators. A condition using any
+	// other operator, including one nested in the value of "any_op", "any",
+	// "all" or "regex_extract" or in a "submatch" rule, fails with
+	// ErrOperatorNotAllowed and so evaluates to false. The ValidateConditions
+	// and ValidateConditionGroup methods reject such rules up front.
+	AllowedOperators map[Operator]bool
+}
+
+// ParamRef is a condition value that refers to the Evaluator parameter with
+// the given name, so the condition compares the field with the parameter's
+// value. A reference to a parameter missing from Params fails with
+// ErrUnknownParam.
+//
+// Example:
+//
+//	e := NewEvaluator()
+//	e.Params = map[string]interface{}{"deploy_region": "eu-west-1"}
+//	cond := NewSimpleCondition("region", OperatorEq, ParamRef("deploy_region"))
+type ParamRef string
+
+// FieldRef is a condition value that refers to another field of the data,
+// resolved like a condition's key, so the condition compares two fields:
+// {key: "selectedId", operator: "in", value: Ref("allowedIds")} passes when
+// selectedId is one of the values in the allowedIds field. A condition
+// referring to a field missing from the data evaluates to false.
+type FieldRef string
+
+// Ref returns a FieldRef to the field with the given key.
+//
+// Example:
+//
+//	cond := NewSimpleCondition("selectedId", OperatorIn, Ref("user.allowedIds"))
+func Ref(key string) FieldRef {
+	return FieldRef(key)
+}
+
+// NowRef is a condition value that stands for the current time, as given by
+// the Evaluator's Now clock, so a rule can compare a field with the time of
+// evaluation without the caller putting it in the data. Like every condition
+// of an evaluation it sees the same instant.
+//
+// Example:
+//
+//	cond := NewSimpleCondition("expires_at", OperatorGt, Now())
+type NowRef struct{}
+
+// Now returns a NowRef, the current time as a condition value.
+func Now() NowRef {
+	return NowRef{}
+}
+
+// errMissingRef is returned by resolveValue for a FieldRef to a missing field
+var errMissingRef = errors.New("referenced field is missing")
+
+// DefaultMaxDepth is the group nesting depth allowed when Evaluator.MaxDepth
+// is zero, and by ValidateConditions and ValidateConditionGroup.
+const DefaultMaxDepth = 1000
+
+// defaultEvaluator backs the package-level evaluation functions.
+var defaultEvaluator = NewEvaluator()
+
+// NewEvaluator creates an Evaluator with the default settings.
+func NewEvaluator() *Evaluator {
+	return &Evaluator{
+		EmptyResult: true,
+	}
+}
+
+// EvaluateCondition evaluates a condition tree against the provided data.
+// See the package-level EvaluateCondition for details.
+func (e *Evaluator) EvaluateCondition(cond Conditions, data map[string]interface{}) bool {
+	result, _ := e.newEvaluation(data, false).evalCondition(cond)
+	return result
+}
+
+// EvaluateConditionE evaluates a condition tree against the provided data,
+// returning the first error encountered. See the package-level
+// EvaluateConditionE for details.
+func (e *Evaluator) EvaluateConditionE(cond Conditions, data map[string]interface{}) (bool, error) {
+	return e.newEvaluation(data, true).evalCondition(cond)
+}
+
+// EvaluateConditionWith evaluates a condition tree against the provided data
+// with operators scoped to this call. See the package-level
+// EvaluateConditionWith for details.
+func (e *Evaluator) EvaluateConditionWith(cond Conditions, data map[string]interface{}, ops map[Operator]CustomOperatorValidator) bool {
+	ev := e.newEvaluation(data, false)
+	ev.ops = ops
+	result, _ := ev.evalCondition(cond)
+	return result
+}
+
+// EvaluateConditionGroup evaluates a ConditionGroup against the provided data.
+// See the package-level EvaluateConditionGroup for details.
+func (e *Evaluator) EvaluateConditionGroup(group ConditionGroup, data map[string]interface{}) bool {
+	result, _ := e.newEvaluation(data, false).evalGroup(group, nil)
+	return result
+}
+
+// EvaluateConditionGroupE evaluates a ConditionGroup against the provided data,
+// returning the first error encountered. See the package-level
+// EvaluateConditionGroupE for details.
+func (e *Evaluator) EvaluateConditionGroupE(group ConditionGroup, data map[string]interface{}) (bool, error) {
+	return e.newEvaluation(data, true).evalGroup(group, nil)
+}
+
+// EvaluateBatch evaluates a condition tree against each row of data, returning
+// the results in row order. See the package-level EvaluateBatch for details.
+func (e *Evaluator) EvaluateBatch(cond Conditions, rows []map[string]interface{}) []bool {
+	results := make([]bool, len(rows))
+	workers := e.BatchWorkers
+	if workers > len(rows) {
+		workers = len(rows)
+	}
+	if workers <= 1 {
+		for i, row := range rows {
+			results[i] = e.EvaluateCondition(cond, row)
+		}
+		return results
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = e.EvaluateCondition(cond, rows[i])
+			}
+		}()
+	}
+	for i := range rows {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return results
+}
+
+// EvaluateAny reports whether the condition tree is satisfied by at least one
+// row. See the package-level EvaluateAny for details. Rows are evaluated one
+// after another so evaluation can stop early; BatchWorkers is not used.
+func (e *Evaluator) EvaluateAny(cond Conditions, rows []map[string]interface{}) bool {
+	for _, row := range rows {
+		if e.EvaluateCondition(cond, row) {
+			return true
+		}
+	}
+	return false
+}
+
+// EvaluateAll reports whether the condition tree is satisfied by every row.
+// See the package-level EvaluateAll for details. Rows are evaluated one after
+// another so evaluation can stop early; BatchWorkers is not used.
+func (e *Evaluator) EvaluateAll(cond Conditions, rows []map[string]interface{}) bool {
+	for _, row := range rows {
+		if !e.EvaluateCondition(cond, row) {
+			return false
+		}
+	}
+	return true
+}
+
+// evaluation holds the state of a single evaluation call.
+type evaluation struct {
+	*Evaluator
+	data map[string]interface{}
+	// source, when set, provides the top-level values instead of data
+	source DataSource
+	// strict propagates errors from single conditions instead of treating them as false
+	strict bool
+	// now caches the current time so all conditions see the same instant,
+	// once hasNow is set
+	now    time.Time
+	hasNow bool
+	// foldedKeys maps lowercased keys to data keys, built on first use when
+	// CaseInsensitiveKeys is set
+	foldedKeys map[string]string
+	// depth is the nesting depth of the group being evaluated
+	depth int
+	// submatchKeys holds the keys of the submatch rules being evaluated
+	submatchKeys map[string]bool
+	// derived caches the derived fields computed so far
+	derived map[string]derivedResult
+	// resolved caches the values returned by Resolver so far
+	resolved map[string]derivedResult
+	// lazy caches the results of the lazy values called so far
+	lazy map[lazyKey]interface{}
+	// ops holds operators scoped to this evaluation, which take precedence
+	// over the custom operator registry
+	ops map[Operator]CustomOperatorValidator
+}
+
+// newEvaluation prepares the evaluation of data.
+func (e *Evaluator) newEvaluation(data map[string]interface{}, strict bool) *evaluation {
+	return &evaluation{
+		Evaluator: e,
+		data:      data,
+		strict:    strict,
+	}
+}
+
+// maxDepth returns the group nesting depth allowed by MaxDepth, with zero
+// replaced by DefaultMaxDepth; a negative value means no limit.
+func (e *Evaluator) maxDepth() int {
+	if e.MaxDepth == 0 {
+		return DefaultMaxDepth
+	}
+	return e.MaxDepth
+}
+
+// enterGroup records entering a nested group, failing when the group is
+// nested deeper than MaxDepth. Each successful call must be paired with
+// leaveGroup.
+func (ev *evaluation) enterGroup() error {
+	maxDepth := ev.maxDepth()
+	if maxDepth > 0 && ev.depth >= maxDepth {
+		return fmt.Errorf("%w (%d)", ErrMaxDepthExceeded, maxDepth)
+	}
+	ev.depth++
+	return nil
+}
+
+// leaveGroup records leaving a group entered with enterGroup.
+func (ev *evaluation) leaveGroup() {
+	ev.depth--
+}
+
+// resolveValue replaces a reference in a condition value, such as a
+// ParamRef, FieldRef or NowRef, with the value it refers to. Pointers are
+// dereferenced, a nil pointer giving nil, so a Value of &age compares like
+// age; other values are returned unchanged.
+func (ev *evaluation) resolveValue(value interface{}) (interface{}, error) {
+	switch ref := value.(type) {
+	case ParamRef:
+		param, exists := ev.Params[string(ref)]
+		if !exists {
+			return nil, fmt.Errorf("%w %q", ErrUnknownParam, string(ref))
+		}
+		return deref(ev.force(reflect.ValueOf(ev.Params).Pointer(), string(ref), param)), nil
+	case FieldRef:
+		v, exists := ev.lookup(string(ref))
+		if !exists {
+			return nil, errMissingRef
+		}
+		return deref(v), nil
+	case NowRef:
+		return ev.currentTime(), nil
+	default:
+		return deref(value), nil
+	}
+}
+
+// currentTime returns the evaluation's current time, reading the clock on first use.
+func (ev *evaluation) currentTime() time.Time {
+	if !ev.hasNow {
+		if ev.Now != nil {
+			ev.now = ev.Now()
+		} else {
+			ev.now = time.Now()
+		}
+		ev.hasNow = true
+	}
+	return ev.now
+}
+
+// lookup returns the data value for key, honoring CaseInsensitiveKeys. A key
+// that isn't in the data is resolved as a dot-separated path through nested
+// maps, so "user.address.city" reaches data["user"]["address"]["city"]. A "*"
+// segment stands for every value of a map, in key order, or every element of
+// a slice, and the path resolves to the collection of matching values, so
+// "scores.*" is the list of scores and "users.*.age" the list of ages. A "[]"
+// suffix on a segment is the same as a following "*" segment, so
+// "orders[].items[].sku" is "orders.*.items.*.sku", unless the data has the
+// key as written. Keys the data doesn't have are then resolved through the
+// derived fields and finally the Resolver. Lazy values met on the way, at the
+// top level or nested, are replaced by their result.
+func (ev *evaluation) lookup(key string) (interface{}, bool) {
+	original := key
+	if strings.Contains(key, "[]") {
+		if v, exists := ev.lookupTop(key); exists {
+			return v, true
+		}
+		key = strings.ReplaceAll(key, "[]", ".*")
+	}
+	v, exists := ev.resolvePath(key, func(key string) (interface{}, bool) {
+		if v, exists := ev.lookupTop(key); exists || key != "*" || ev.source != nil {
+			return v, exists
+		}
+		return ev.wildcardOf(ev.data)
+	})
+	if !exists {
+		v, exists = ev.resolvePath(key, ev.derive)
+	}
+	if !exists && ev.Resolver != nil {
+		v, exists = ev.resolve(original)
+	}
+	if values, ok := v.(wildcardValues); ok {
+		return []interface{}(values), exists
+	}
+	return v, exists
+}
+
+// resolve returns the value the Resolver gives for key, calling it on first
+// use in the evaluation.
+func (ev *evaluation) resolve(key string) (interface{}, bool) {
+	if result, ok := ev.resolved[key]; ok {
+		return result.value, result.exists
+	}
+	v, exists := ev.Resolver(key)
+	if ev.resolved == nil {
+		ev.resolved = make(map[string]derivedResult)
+	}
+	ev.resolved[key] = derivedResult{value: v, exists: exists}
+	return v, exists
+}
+
+// resolvePath looks up path with get, or, failing that, splits it at each dot
+// in turn and resolves the rest of the path in the map found for the prefix.
+// Keys that themselves contain dots are thus found whichever way the data
+// nests them.
+func (ev *evaluation) resolvePath(path string, get func(key string) (interface{}, bool)) (interface{}, bool) {
+	if v, exists := get(path); exists {
+		return v, true
+	}
+	for i := 0; i < len(path); i++ {
+		if path[i] != '.' {
+			continue
+		}
+		parent, exists := get(path[:i])
+		if !exists {
+			continue
+		}
+		if values, ok := parent.(wildcardValues); ok {
+			return ev.resolveEach(values, path[i+1:]), true
+		}
+		v, exists := ev.resolvePath(path[i+1:], func(key string) (interface{}, bool) {
+			return ev.child(parent, key)
+		})
+		if exists {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// wildcardValues holds the values a "*" path segment resolved to, so that
+// the rest of the path is resolved in each of them.
+type wildcardValues []interface{}
+
+// resolveEach resolves path in each of the values a wildcard matched,
+// skipping values that don't have it. A further wildcard flattens its values
+// into the result.
+func (ev *evaluation) resolveEach(values wildcardValues, path string) wildcardValues {
+	found := wildcardValues{}
+	for _, value := range values {
+		v, exists := ev.resolvePath(path, func(key string) (interface{}, bool) {
+			return ev.child(value, key)
+		})
+		if !exists {
+			continue
+		}
+		if nested, ok := v.(wildcardValues); ok {
+			found = append(found, nested...)
+		} else {
+			found = append(found, v)
+		}
+	}
+	return found
+}
+
+// child returns the value for key in the map parent or, when key is "*" and
+// parent has no such key, the values of parent as with wildcardOf.
+func (ev *evaluation) child(parent interface{}, key string) (interface{}, bool) {
+	if v, exists := ev.mapIndex(parent, key); exists || key != "*" {
+		return v, exists
+	}
+	return ev.wildcardOf(parent)
+}
+
+// wildcardOf returns the values of the map v, ordered by key, or the elements
+// of the slice or array v, calling lazy values.
+func (ev *evaluation) wildcardOf(v interface{}) (interface{}, bool) {
+	rv := reflect.ValueOf(deref(v))
+	switch rv.Kind() {
+	case reflect.Map:
+		keys := rv.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return toString(keys[i].Interface()) < toString(keys[j].Interface())
+		})
+		values := make(wildcardValues, len(keys))
+		for i, k := range keys {
+			values[i] = ev.force(rv.Pointer(), toString(k.Interface()), rv.MapIndex(k).Interface())
+		}
+		return values, true
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			// Byte slices hold text
+			return nil, false
+		}
+		values := make(wildcardValues, rv.Len())
+		for i := range values {
+			values[i] = rv.Index(i).Interface()
+			if rv.Kind() == reflect.Slice {
+				values[i] = ev.force(rv.Pointer(), strconv.Itoa(i), values[i])
+			}
+		}
+		return values, true
+	}
+	return nil, false
+}
+
+// mapIndex returns the value for key in m, which may be any map with string
+// or interface keys, such as the map[string]interface{} produced by
+// encoding/json or the map[interface{}]interface{} produced by YAML decoders.
+// Pointers and interfaces are followed.
+func (ev *evaluation) mapIndex(m interface{}, key string) (interface{}, bool) {
+	rv := reflect.ValueOf(deref(m))
+	if rv.Kind() != reflect.Map || rv.Len() == 0 {
+		return nil, false
+	}
+
+	keyType := rv.Type().Key()
+	switch {
+	case keyType.Kind() == reflect.String:
+		if v := rv.MapIndex(reflect.ValueOf(key).Convert(keyType)); v.IsValid() {
+			return ev.force(rv.Pointer(), key, v.Interface()), true
+		}
+	case keyType.Kind() == reflect.Interface && reflect.TypeOf(key).Implements(keyType):
+		if v := rv.MapIndex(reflect.ValueOf(key)); v.IsValid() {
+			return ev.force(rv.Pointer(), key, v.Interface()), true
+		}
+	default:
+		return nil, false
+	}
+	if !ev.CaseInsensitiveKeys {
+		return nil, false
+	}
+
+	// Like the top level, prefer the lexicographically smallest key
+	var found reflect.Value
+	var foundKey string
+	iter := rv.MapRange()
+	for iter.Next() {
+		k := iter.Key()
+		if k.Kind() == reflect.Interface {
+			k = k.Elem()
+		}
+		if k.Kind() != reflect.String {
+			continue
+		}
+		if s := k.String(); strings.EqualFold(s, key) && (!found.IsValid() || s < foundKey) {
+			found, foundKey = iter.Value(), s
+		}
+	}
+	if found.IsValid() {
+		return ev.force(rv.Pointer(), foundKey, found.Interface()), true
+	}
+	return nil, false
+}
+
+// lookupTop returns the top-level data value for key, honoring
+// CaseInsensitiveKeys unless the data comes from a DataSource.
+func (ev *evaluation) lookupTop(key string) (interface{}, bool) {
+	if ev.source != nil {
+		v, exists := ev.source.Get(key)
+		return ev.force(0, key, v), exists
+	}
+
+	owner := reflect.ValueOf(ev.data).Pointer()
+	v, exists := ev.data[key]
+	if exists || !ev.CaseInsensitiveKeys {
+		return ev.force(owner, key, v), exists
+	}
+
+	if ev.foldedKeys == nil {
+		ev.foldedKeys = make(map[string]string, len(ev.data))
+		for k := range ev.data {
+			folded := strings.ToLower(k)
+			if existing, ok := ev.foldedKeys[folded]; !ok || k < existing {
+				ev.foldedKeys[folded] = k
+			}
+		}
+	}
+
+	if k, ok := ev.foldedKeys[strings.ToLower(key)]; ok {
+		return ev.force(owner, k, ev.data[k]), true
+	}
+	return nil, false
+}
+
+// lazyKey identifies a lazy value by the address of the map holding it, or 0
+// for a DataSource, and its key
+type lazyKey struct {
+	owner uintptr
+	key   string
+}
+
+// force returns the result of calling v if it is a lazy value, a
+// func() interface{}, calling it on first use in the evaluation, and v
+// itself otherwise. owner and key identify where v was found. A nil function
+// is a nil value.
+func (ev *evaluation) force(owner uintptr, key string, v interface{}) interface{} {
+	fn, ok := v.(func() interface{})
+	if !ok {
+		return v
+	}
+	if fn == nil {
+		return nil
+	}
+	id := lazyKey{owner: owner, key: key}
+	if result, ok := ev.lazy[id]; ok {
+		return result
+	}
+	result := fn()
+	if ev.lazy == nil {
+		ev.lazy = make(map[lazyKey]interface{})
+	}
+	ev.lazy[id] = result
+	return result
+}
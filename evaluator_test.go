@@ -0,0 +1,1306 @@
+package jsonvaluate
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEvaluator_EmptyConditions(t *testing.T) {
+	data := map[string]interface{}{"age": 25}
+
+	tests := []struct {
+		name        string
+		cond        Conditions
+		emptyResult bool
+		expect      bool
+	}{
+		{"zero condition default", Conditions{}, true, true},
+		{"zero condition configured false", Conditions{}, false, false},
+		{"empty AND group", NewAndGroup(), false, true},
+		{"empty OR group", NewOrGroup(), true, false},
+		{"AND group with empty child", NewAndGroup(Conditions{}, NewSimpleCondition("age", OperatorGt, 18)), false, false},
+		{"OR group with empty child", NewOrGroup(Conditions{}, NewSimpleCondition("age", OperatorLt, 18)), true, true},
+		{"unknown logic", Conditions{Logic: "XOR", Children: []Conditions{{}}}, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewEvaluator()
+			e.EmptyResult = tt.emptyResult
+			if result := e.EvaluateCondition(tt.cond, data); result != tt.expect {
+				t.Errorf("EvaluateCondition() = %v, want %v", result, tt.expect)
+			}
+		})
+	}
+}
+
+func TestEvaluator_Defaults(t *testing.T) {
+	data := map[string]interface{}{"age": 25}
+
+	if !EvaluateCondition(Conditions{}, data) {
+		t.Error("Empty condition should be true by default")
+	}
+	if EvaluateCondition(NewOrGroup(), data) {
+		t.Error("Empty OR group should be false")
+	}
+	if !EvaluateCondition(NewAndGroup(), data) {
+		t.Error("Empty AND group should be true")
+	}
+
+	_, err := EvaluateConditionE(Conditions{Logic: "XOR"}, data)
+	if !errors.Is(err, ErrUnknownLogic) {
+		t.Errorf("Expected ErrUnknownLogic, got %v", err)
+	}
+}
+
+func TestEvaluator_CaseInsensitiveKeys(t *testing.T) {
+	data := map[string]interface{}{
+		"Age":     25,
+		"COUNTRY": "TH",
+		"Status":  "inactive",
+		"status":  "active",
+		"TIER":    "gold",
+		"Tier":    "silver",
+	}
+
+	cond := NewAndGroup(
+		NewSimpleCondition("age", OperatorGt, 18),
+		NewSimpleCondition("country", OperatorEq, "TH"),
+		NewSimpleCondition("status", OperatorEq, "active"), // exact match wins
+		NewSimpleCondition("tier", OperatorEq, "gold"),     // "TIER" < "Tier"
+		NewSimpleCondition("MISSING", OperatorIsnull, nil),
+	)
+
+	if EvaluateCondition(cond, data) {
+		t.Error("Keys should be case-sensitive by default")
+	}
+
+	e := NewEvaluator()
+	e.CaseInsensitiveKeys = true
+	if !e.EvaluateCondition(cond, data) {
+		t.Error("Keys should match ignoring case")
+	}
+	for _, c := range cond.Children {
+		if !e.EvaluateCondition(c, data) {
+			t.Errorf("Condition on %q should be true", c.Key)
+		}
+	}
+}
+
+func TestEvaluator_TrimEmpty(t *testing.T) {
+	data := map[string]interface{}{
+		"blank":  "   ",
+		"tabs":   "\t\n",
+		"empty":  "",
+		"text":   "  hi  ",
+		"zero":   0,
+		"false":  false,
+		"spaces": []string{" "},
+	}
+
+	tests := []struct {
+		key     string
+		strict  bool
+		trimmed bool
+	}{
+		{"blank", false, true},
+		{"tabs", false, true},
+		{"empty", true, true},
+		{"text", false, false},
+		{"zero", false, false},
+		{"false", false, false},
+		{"spaces", false, false},
+	}
+
+	e := NewEvaluator()
+	e.TrimEmpty = true
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			cond := NewSimpleCondition(tt.key, OperatorIsEmpty, nil)
+			if result := EvaluateCondition(cond, data); result != tt.strict {
+				t.Errorf("isempty(%s) = %v, want %v", tt.key, result, tt.strict)
+			}
+			if result := e.EvaluateCondition(cond, data); result != tt.trimmed {
+				t.Errorf("isempty(%s) with TrimEmpty = %v, want %v", tt.key, result, tt.trimmed)
+			}
+			notEmpty := NewSimpleCondition(tt.key, OperatorIsNotEmpty, nil)
+			if result := e.EvaluateCondition(notEmpty, data); result == tt.trimmed {
+				t.Errorf("isnotempty(%s) with TrimEmpty = %v, want %v", tt.key, result, !tt.trimmed)
+			}
+		})
+	}
+}
+
+func TestEvaluator_HonorPrecedence(t *testing.T) {
+	data := map[string]interface{}{"a": true, "b": false, "c": false, "d": true}
+	cond := func(key string, next Logic) ConditionWithLogic {
+		return NewConditionWithLogic(key, OperatorIsTrue, nil, next)
+	}
+
+	tests := []struct {
+		name       string
+		group      ConditionGroup
+		leftFold   bool
+		precedence bool
+	}{
+		// (a OR b) AND c = false; a OR (b AND c) = true
+		{"a OR b AND c", NewConditionGroup(cond("a", LogicOr), cond("b", LogicAnd), cond("c", "")), false, true},
+		// (b AND c) OR a = true either way
+		{"b AND c OR a", NewConditionGroup(cond("b", LogicAnd), cond("c", LogicOr), cond("a", "")), true, true},
+		// ((c OR a) AND b) OR d = true; c OR (a AND b) OR d = true
+		{"c OR a AND b OR d", NewConditionGroup(cond("c", LogicOr), cond("a", LogicAnd), cond("b", LogicOr), cond("d", "")), true, true},
+		// (b OR a) AND d AND c = false; b OR (a AND d AND c) = false
+		{"b OR a AND d AND c", NewConditionGroup(cond("b", LogicOr), cond("a", LogicAnd), cond("d", LogicAnd), cond("c", "")), false, false},
+		// Missing logic defaults to AND: (a OR b) AND c AND d = false; a OR (b AND c AND d) = true
+		{"a OR b c d", NewConditionGroup(cond("a", LogicOr), cond("b", ""), cond("c", ""), cond("d", "")), false, true},
+		{"single", NewConditionGroup(cond("b", LogicOr)), false, false},
+	}
+
+	e := NewEvaluator()
+	e.HonorPrecedence = true
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := EvaluateConditionGroup(tt.group, data); result != tt.leftFold {
+				t.Errorf("left fold = %v, want %v", result, tt.leftFold)
+			}
+			if result := e.EvaluateConditionGroup(tt.group, data); result != tt.precedence {
+				t.Errorf("with precedence = %v, want %v", result, tt.precedence)
+			}
+		})
+	}
+}
+
+func TestEvaluator_FloatTolerance(t *testing.T) {
+	data := map[string]interface{}{
+		"score":  85.49999999,
+		"amount": 100.0001,
+		"count":  3,
+	}
+
+	tests := []struct {
+		name      string
+		key       string
+		op        Operator
+		value     interface{}
+		exact     bool
+		tolerated bool
+	}{
+		{"just inside", "score", OperatorEq, 85.5, false, true},
+		{"just inside neq", "score", OperatorNeq, 85.5, true, false},
+		{"just outside", "amount", OperatorEq, 100, false, false},
+		{"just outside neq", "amount", OperatorNeq, 100, true, true},
+		{"boundary", "amount", OperatorEq, 100.0001 + 1e-6, false, true},
+		{"integers", "count", OperatorEq, 3, true, true},
+		{"numeric string", "score", OperatorEq, "85.5", false, true},
+		{"non-numeric", "score", OperatorEq, "high", false, false},
+	}
+
+	e := NewEvaluator()
+	e.FloatTolerance = 1e-6
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cond := NewSimpleCondition(tt.key, tt.op, tt.value)
+			if result := EvaluateCondition(cond, data); result != tt.exact {
+				t.Errorf("exact = %v, want %v", result, tt.exact)
+			}
+			if result := e.EvaluateCondition(cond, data); result != tt.tolerated {
+				t.Errorf("with tolerance = %v, want %v", result, tt.tolerated)
+			}
+		})
+	}
+}
+
+func TestEvaluator_InDelimiter(t *testing.T) {
+	data := map[string]interface{}{
+		"th":      "TH",
+		"t":       "T",
+		"sg":      "SG",
+		"pair":    "TH,SG",
+		"us":      "US",
+		"padded":  "MY",
+		"code":    44,
+		"comma":   ",",
+		"nothing": "",
+	}
+
+	tests := []struct {
+		name      string
+		key       string
+		op        Operator
+		value     interface{}
+		substring bool
+		split     bool
+	}{
+		{"exact element", "th", OperatorIn, "TH,SG,MY", true, true},
+		{"partial element", "t", OperatorIn, "TH,SG,MY", true, false},
+		{"spanning elements", "pair", OperatorIn, "TH,SG,MY", true, false},
+		{"absent", "us", OperatorIn, "TH,SG,MY", false, false},
+		{"whitespace around elements", "padded", OperatorIn, "TH, SG , MY ", true, true},
+		{"number", "code", OperatorIn, "1,44,81", true, true},
+		{"delimiter itself", "comma", OperatorIn, "TH,SG", true, false},
+		{"empty value", "nothing", OperatorIn, "TH,,SG", true, false},
+		{"nin partial element", "t", OperatorNin, "TH,SG,MY", false, true},
+		{"nin exact element", "sg", OperatorNin, "TH,SG,MY", false, false},
+		{"slices unaffected", "sg", OperatorIn, []interface{}{"TH", "SG"}, true, true},
+		{"in_string keeps substring", "t", OperatorInString, "TH,SG,MY", true, true},
+		{"in_string absent", "us", OperatorInString, "TH,SG,MY", false, false},
+	}
+
+	e := NewEvaluator()
+	e.InDelimiter = ","
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cond := NewSimpleCondition(tt.key, tt.op, tt.value)
+			if result := EvaluateCondition(cond, data); result != tt.substring {
+				t.Errorf("default = %v, want %v", result, tt.substring)
+			}
+			if result := e.EvaluateCondition(cond, data); result != tt.split {
+				t.Errorf("with delimiter = %v, want %v", result, tt.split)
+			}
+		})
+	}
+}
+
+func TestEvaluator_SortBetweenBounds(t *testing.T) {
+	data := map[string]interface{}{"age": 25, "date": "2024-07-01"}
+
+	tests := []struct {
+		name     string
+		key      string
+		op       Operator
+		value    interface{}
+		unsorted bool
+		sorted   bool
+	}{
+		{"ordered slice", "age", OperatorBetween, []interface{}{18, 30}, true, true},
+		{"swapped slice", "age", OperatorBetween, []interface{}{30, 18}, false, true},
+		{"swapped slice outside", "age", OperatorBetween, []interface{}{60, 40}, false, false},
+		{"swapped notbetween", "age", OperatorNotBetween, []interface{}{30, 18}, true, false},
+		{"swapped dates", "date", OperatorBetween, []interface{}{"2024-12-31", "2024-01-01"}, false, true},
+		{"swapped map always sorted", "age", OperatorBetween, map[string]interface{}{"min": 30, "max": 18}, true, true},
+	}
+
+	e := NewEvaluator()
+	e.SortBetweenBounds = true
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cond := NewSimpleCondition(tt.key, tt.op, tt.value)
+			if result := EvaluateCondition(cond, data); result != tt.unsorted {
+				t.Errorf("default = %v, want %v", result, tt.unsorted)
+			}
+			if result := e.EvaluateCondition(cond, data); result != tt.sorted {
+				t.Errorf("with SortBetweenBounds = %v, want %v", result, tt.sorted)
+			}
+		})
+	}
+}
+
+// nestedCondition builds an AND group nested depth levels deep around leaf
+func nestedCondition(depth int, leaf Conditions) Conditions {
+	cond := leaf
+	for i := 0; i < depth; i++ {
+		cond = NewAndGroup(cond)
+	}
+	return cond
+}
+
+// nestedGroup builds a ConditionGroup nested depth levels deep around leaf
+func nestedGroup(depth int, leaf ConditionWithLogic) ConditionGroup {
+	group := NewConditionGroup(leaf)
+	for i := 1; i < depth; i++ {
+		inner := group
+		group = NewConditionGroup(ConditionWithLogic{Group: &inner})
+	}
+	return group
+}
+
+func TestEvaluator_MaxDepth(t *testing.T) {
+	data := map[string]interface{}{"age": 25}
+	leaf := NewSimpleCondition("age", OperatorGt, 18)
+
+	deep := nestedCondition(10000, leaf)
+	if EvaluateCondition(deep, data) {
+		t.Error("Expected a 10,000-deep tree to evaluate to false")
+	}
+	if _, err := EvaluateConditionE(deep, data); !errors.Is(err, ErrMaxDepthExceeded) {
+		t.Errorf("EvaluateConditionE() error = %v, want ErrMaxDepthExceeded", err)
+	}
+	if err := ValidateConditions(deep); !errors.Is(err, ErrMaxDepthExceeded) {
+		t.Errorf("ValidateConditions() error = %v, want ErrMaxDepthExceeded", err)
+	}
+
+	deepGroup := nestedGroup(10000, NewConditionWithLogic("age", OperatorGt, 18, ""))
+	if _, err := EvaluateConditionGroupE(deepGroup, data); !errors.Is(err, ErrMaxDepthExceeded) {
+		t.Errorf("EvaluateConditionGroupE() error = %v, want ErrMaxDepthExceeded", err)
+	}
+	if err := ValidateConditionGroup(deepGroup); !errors.Is(err, ErrMaxDepthExceeded) {
+		t.Errorf("ValidateConditionGroup() error = %v, want ErrMaxDepthExceeded", err)
+	}
+
+	// Trees up to the limit are allowed
+	atLimit := nestedCondition(DefaultMaxDepth, leaf)
+	if result, err := EvaluateConditionE(atLimit, data); err != nil || !result {
+		t.Errorf("EvaluateConditionE() at the default limit = %v, %v, want true, nil", result, err)
+	}
+	if err := ValidateConditions(atLimit); err != nil {
+		t.Errorf("ValidateConditions() at the default limit = %v, want nil", err)
+	}
+	if err := ValidateConditionGroup(nestedGroup(DefaultMaxDepth, NewConditionWithLogic("age", OperatorGt, 18, ""))); err != nil {
+		t.Errorf("ValidateConditionGroup() at the default limit = %v, want nil", err)
+	}
+
+	e := NewEvaluator()
+	e.MaxDepth = 3
+	if result, err := e.EvaluateConditionE(nestedCondition(3, leaf), data); err != nil || !result {
+		t.Errorf("MaxDepth 3 with depth 3 = %v, %v, want true, nil", result, err)
+	}
+	if _, err := e.EvaluateConditionE(nestedCondition(4, leaf), data); !errors.Is(err, ErrMaxDepthExceeded) {
+		t.Errorf("MaxDepth 3 with depth 4 error = %v, want ErrMaxDepthExceeded", err)
+	}
+	if _, err := e.EvaluateConditionGroupE(nestedGroup(4, NewConditionWithLogic("age", OperatorGt, 18, "")), data); !errors.Is(err, ErrMaxDepthExceeded) {
+		t.Errorf("MaxDepth 3 with group depth 4 error = %v, want ErrMaxDepthExceeded", err)
+	}
+
+	if err := e.ValidateConditions(nestedCondition(3, leaf)); err != nil {
+		t.Errorf("ValidateConditions() with MaxDepth 3 and depth 3 = %v, want nil", err)
+	}
+	if err := e.ValidateConditions(nestedCondition(4, leaf)); !errors.Is(err, ErrMaxDepthExceeded) {
+		t.Errorf("ValidateConditions() with MaxDepth 3 and depth 4 = %v, want ErrMaxDepthExceeded", err)
+	}
+	if err := e.ValidateConditionGroup(nestedGroup(3, NewConditionWithLogic("age", OperatorGt, 18, ""))); err != nil {
+		t.Errorf("ValidateConditionGroup() with MaxDepth 3 and depth 3 = %v, want nil", err)
+	}
+	if err := e.ValidateConditionGroup(nestedGroup(4, NewConditionWithLogic("age", OperatorGt, 18, ""))); !errors.Is(err, ErrMaxDepthExceeded) {
+		t.Errorf("ValidateConditionGroup() with MaxDepth 3 and depth 4 = %v, want ErrMaxDepthExceeded", err)
+	}
+
+	// Siblings don't add to the depth
+	wide := NewAndGroup(nestedCondition(2, leaf), nestedCondition(2, leaf), nestedCondition(2, leaf))
+	if result, err := e.EvaluateConditionE(wide, data); err != nil || !result {
+		t.Errorf("MaxDepth 3 with wide tree = %v, %v, want true, nil", result, err)
+	}
+
+	e.MaxDepth = -1
+	if result, err := e.EvaluateConditionE(deep, data); err != nil || !result {
+		t.Errorf("Unlimited depth = %v, %v, want true, nil", result, err)
+	}
+	if err := e.ValidateConditions(nestedCondition(DefaultMaxDepth+1, leaf)); err != nil {
+		t.Errorf("ValidateConditions() with unlimited depth = %v, want nil", err)
+	}
+	if err := e.ValidateConditionGroup(nestedGroup(DefaultMaxDepth+1, NewConditionWithLogic("age", OperatorGt, 18, ""))); err != nil {
+		t.Errorf("ValidateConditionGroup() with unlimited depth = %v, want nil", err)
+	}
+}
+
+// batchRows returns n rows whose "id" is the row index, so odd rows can be
+// told apart from even ones
+func batchRows(n int) []map[string]interface{} {
+	rows := make([]map[string]interface{}, n)
+	for i := range rows {
+		rows[i] = map[string]interface{}{"id": i, "odd": i%2 == 1, "status": "active"}
+	}
+	return rows
+}
+
+func TestEvaluateBatch(t *testing.T) {
+	cond := NewAndGroup(
+		NewSimpleCondition("odd", OperatorIsTrue, nil),
+		NewSimpleCondition("status", OperatorEq, "active"),
+	)
+	rows := batchRows(1000)
+
+	for _, workers := range []int{0, 1, 4, 2000} {
+		e := NewEvaluator()
+		e.BatchWorkers = workers
+		results := e.EvaluateBatch(cond, rows)
+		if len(results) != len(rows) {
+			t.Fatalf("workers %d: got %d results, want %d", workers, len(results), len(rows))
+		}
+		for i, result := range results {
+			if result != (i%2 == 1) {
+				t.Fatalf("workers %d: results[%d] = %v, want %v", workers, i, result, i%2 == 1)
+			}
+		}
+	}
+
+	if results := EvaluateBatch(cond, nil); len(results) != 0 {
+		t.Errorf("EvaluateBatch(nil) = %v, want empty", results)
+	}
+	e := NewEvaluator()
+	e.BatchWorkers = 4
+	if results := e.EvaluateBatch(cond, nil); len(results) != 0 {
+		t.Errorf("parallel EvaluateBatch(nil) = %v, want empty", results)
+	}
+}
+
+func TestEvaluateAnyAll(t *testing.T) {
+	rows := []map[string]interface{}{
+		{"age": 15, "country": "TH"},
+		{"age": 30, "country": "TH"},
+		{"age": 45, "country": "SG"},
+	}
+	adult := NewSimpleCondition("age", OperatorGte, 18)
+	asian := NewSimpleCondition("country", OperatorIn, []string{"TH", "SG"})
+	senior := NewSimpleCondition("age", OperatorGte, 65)
+
+	tests := []struct {
+		name     string
+		cond     Conditions
+		rows     []map[string]interface{}
+		any, all bool
+	}{
+		{"some rows match", adult, rows, true, false},
+		{"every row matches", asian, rows, true, true},
+		{"no row matches", senior, rows, false, false},
+		{"no rows", adult, nil, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := EvaluateAny(tt.cond, tt.rows); result != tt.any {
+				t.Errorf("EvaluateAny() = %v, want %v", result, tt.any)
+			}
+			if result := EvaluateAll(tt.cond, tt.rows); result != tt.all {
+				t.Errorf("EvaluateAll() = %v, want %v", result, tt.all)
+			}
+		})
+	}
+
+	// Evaluation stops at the first deciding row
+	var evaluated int
+	e := NewEvaluator()
+	e.OnEvaluate = func(string, Operator, bool, time.Duration) { evaluated++ }
+	if !e.EvaluateAny(adult, rows) || evaluated != 2 {
+		t.Errorf("EvaluateAny evaluated %d rows, want 2", evaluated)
+	}
+	evaluated = 0
+	if e.EvaluateAll(adult, rows) || evaluated != 1 {
+		t.Errorf("EvaluateAll evaluated %d rows, want 1", evaluated)
+	}
+}
+
+func BenchmarkEvaluateConditionLoop(b *testing.B) {
+	cond := NewAndGroup(NewSimpleCondition("id", OperatorGte, 500), NewSimpleCondition("status", OperatorLike, "act%"))
+	rows := batchRows(10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		results := make([]bool, len(rows))
+		for j, row := range rows {
+			results[j] = EvaluateCondition(cond, row)
+		}
+	}
+}
+
+func BenchmarkEvaluateBatch(b *testing.B) {
+	cond := NewAndGroup(NewSimpleCondition("id", OperatorGte, 500), NewSimpleCondition("status", OperatorLike, "act%"))
+	rows := batchRows(10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = EvaluateBatch(cond, rows)
+	}
+}
+
+func BenchmarkEvaluateBatch_Parallel(b *testing.B) {
+	cond := NewAndGroup(NewSimpleCondition("id", OperatorGte, 500), NewSimpleCondition("status", OperatorLike, "act%"))
+	rows := batchRows(10000)
+	e := NewEvaluator()
+	e.BatchWorkers = 4
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = e.EvaluateBatch(cond, rows)
+	}
+}
+
+func TestEvaluator_StrictCompare(t *testing.T) {
+	data := map[string]interface{}{
+		"age":     25,
+		"ageStr":  "25",
+		"name":    "bob",
+		"created": "2024-07-01",
+		"active":  true,
+		"tags":    []string{"a"},
+		"nothing": nil,
+	}
+
+	tests := []struct {
+		name    string
+		key     string
+		op      Operator
+		value   interface{}
+		lenient bool
+		strict  bool
+		err     bool
+	}{
+		{"number vs garbage string", "age", OperatorGt, "N/A", false, false, true},
+		{"number vs garbage string reversed", "age", OperatorLt, "N/A", true, false, true},
+		{"number vs number", "age", OperatorGt, 18, true, true, false},
+		{"number vs numeric string", "age", OperatorGte, "18", true, true, false},
+		{"numeric string vs number", "ageStr", OperatorLt, 30, true, true, false},
+		{"string vs string", "name", OperatorGt, "alice", true, true, false},
+		{"time vs time string", "created", OperatorGt, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), true, true, false},
+		{"time vs garbage string", "created", OperatorGt, "soon", false, false, true},
+		{"bool vs bool", "active", OperatorGt, false, true, true, false},
+		{"slice vs number", "tags", OperatorGte, 0, true, false, true},
+		{"nil vs number", "nothing", OperatorLt, 5, true, false, true},
+		{"equality unaffected", "age", OperatorNeq, "N/A", true, true, false},
+	}
+
+	e := NewEvaluator()
+	e.StrictCompare = true
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cond := NewSimpleCondition(tt.key, tt.op, tt.value)
+			if result := EvaluateCondition(cond, data); result != tt.lenient {
+				t.Errorf("lenient = %v, want %v", result, tt.lenient)
+			}
+			if result := e.EvaluateCondition(cond, data); result != tt.strict {
+				t.Errorf("strict = %v, want %v", result, tt.strict)
+			}
+			_, err := e.EvaluateConditionE(cond, data)
+			if tt.err != errors.Is(err, ErrIncomparable) {
+				t.Errorf("strict error = %v, want ErrIncomparable: %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestEvaluator_OnEvaluate(t *testing.T) {
+	defer RestoreOperators(SnapshotOperators())
+	RegisterCustomOperatorE("explodes", func(fieldValue, conditionValue interface{}) (bool, error) {
+		panic("boom")
+	})
+
+	type call struct {
+		key    string
+		op     Operator
+		result bool
+	}
+	var calls []call
+	e := NewEvaluator()
+	e.OnEvaluate = func(key string, op Operator, result bool, dur time.Duration) {
+		if dur < 0 {
+			t.Errorf("negative duration %v for %s", dur, key)
+		}
+		calls = append(calls, call{key, op, result})
+	}
+
+	data := map[string]interface{}{"age": 25, "country": "TH", "score": 10}
+	cond := NewOrGroup(
+		NewAndGroup(
+			NewSimpleCondition("age", OperatorGte, 18),
+			NewSimpleCondition("score", "explodes", nil),
+		),
+		Conditions{Logic: LogicNot, Children: []Conditions{NewSimpleCondition("country", OperatorEq, "US")}},
+	)
+	if !e.EvaluateCondition(cond, data) {
+		t.Fatal("expected condition to pass")
+	}
+
+	want := []call{
+		{"age", OperatorGte, true},
+		{"score", "explodes", false},
+		{"country", OperatorEq, false},
+	}
+	if len(calls) != len(want) {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Errorf("call %d = %v, want %v", i, calls[i], want[i])
+		}
+	}
+
+	// The strict variant reports the panic but still invokes the callback
+	calls = nil
+	if _, err := e.EvaluateConditionE(NewSimpleCondition("score", "explodes", nil), data); !errors.Is(err, ErrOperatorPanicked) {
+		t.Errorf("err = %v, want ErrOperatorPanicked", err)
+	}
+	if len(calls) != 1 || calls[0].result {
+		t.Errorf("calls = %v, want one false call", calls)
+	}
+
+	// Group conditions report their leaves too
+	calls = nil
+	group := ConditionGroup{Conditions: []ConditionWithLogic{
+		{Key: "age", Operator: OperatorLt, Value: 18, Not: true},
+	}}
+	if !e.EvaluateConditionGroup(group, data) {
+		t.Error("expected group to pass")
+	}
+	if len(calls) != 1 || calls[0] != (call{"age", OperatorLt, false}) {
+		t.Errorf("calls = %v, want one false call for age", calls)
+	}
+}
+
+func TestEvaluator_NestedKeys(t *testing.T) {
+	type labels map[string]string
+
+	data := map[string]interface{}{
+		"user": map[string]interface{}{
+			"name": "alice",
+			"address": map[interface{}]interface{}{
+				"city": "Bangkok",
+				"geo":  map[interface{}]interface{}{"lat": 13.75},
+				1:      "numeric key",
+			},
+			"labels": labels{"tier": "gold"},
+		},
+		"meta":     &map[string]interface{}{"version": 2},
+		"a.b":      map[string]interface{}{"c": "flat prefix"},
+		"x.y":      "flat key",
+		"x":        map[string]interface{}{"y": "nested key"},
+		"settings": map[string]interface{}{"Theme": "dark"},
+		"count":    3,
+	}
+
+	tests := []struct {
+		key    string
+		op     Operator
+		value  interface{}
+		expect bool
+	}{
+		{"user.name", OperatorEq, "alice", true},
+		// Crosses a map[interface{}]interface{} node, as decoded from YAML
+		{"user.address.city", OperatorEq, "Bangkok", true},
+		{"user.address.geo.lat", OperatorGt, 13, true},
+		{"user.labels.tier", OperatorEq, "gold", true},
+		{"meta.version", OperatorEq, 2, true},
+		{"a.b.c", OperatorEq, "flat prefix", true},
+		// An exact key wins over a nested path
+		{"x.y", OperatorEq, "flat key", true},
+		{"user.address.zip", OperatorIsnull, nil, true},
+		{"user.name.first", OperatorIsnull, nil, true},
+		{"count.value", OperatorIsnull, nil, true},
+		{"user.address.1", OperatorIsnull, nil, true},
+		{"settings.theme", OperatorIsnull, nil, true},
+		{"user.", OperatorIsnull, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if result := evalSingleCondition(tt.key, tt.op, tt.value, data); result != tt.expect {
+				t.Errorf("%s %s %v = %v, want %v", tt.key, tt.op, tt.value, result, tt.expect)
+			}
+		})
+	}
+
+	e := NewEvaluator()
+	e.CaseInsensitiveKeys = true
+	for _, key := range []string{"settings.theme", "USER.Address.CITY"} {
+		if !e.EvaluateCondition(NewSimpleCondition(key, OperatorIsnotnull, nil), data) {
+			t.Errorf("case-insensitive %s: expected to resolve", key)
+		}
+	}
+}
+
+func TestEvaluator_WildcardKeys(t *testing.T) {
+	data := map[string]interface{}{
+		"scores": map[string]interface{}{"science": 85, "math": 90, "art": 70},
+		"users": []interface{}{
+			map[string]interface{}{"name": "alice", "age": 30, "tags": []interface{}{"a", "b"}},
+			map[string]interface{}{"name": "bob", "tags": []interface{}{"c"}},
+			map[interface{}]interface{}{"name": "carol", "age": 17},
+		},
+		"teams": map[string]interface{}{
+			"red":  map[string]interface{}{"members": map[string]interface{}{"x": 1, "y": 2}},
+			"blue": map[string]interface{}{"members": map[string]interface{}{"z": 3}},
+		},
+		"empty": map[string]interface{}{},
+		"*":     "literal",
+		"name":  "top",
+	}
+
+	tests := []struct {
+		key    string
+		expect interface{}
+	}{
+		// Map values are ordered by key
+		{"scores.*", []interface{}{70, 90, 85}},
+		{"users.*.name", []interface{}{"alice", "bob", "carol"}},
+		// Elements without the rest of the path are skipped
+		{"users.*.age", []interface{}{30, 17}},
+		// A value that is itself a list is kept as one element
+		{"users.*.tags", []interface{}{[]interface{}{"a", "b"}, []interface{}{"c"}}},
+		// Each further wildcard flattens one level
+		{"users.*.tags.*", []interface{}{"a", "b", "c"}},
+		{"teams.*.members.*", []interface{}{3, 1, 2}},
+		{"empty.*", []interface{}{}},
+		{"users.*.missing", []interface{}{}},
+		// A literal "*" key wins
+		{"*", "literal"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			v, exists := NewEvaluator().newEvaluation(data, false).lookup(tt.key)
+			if !exists {
+				t.Fatalf("lookup(%q) did not resolve", tt.key)
+			}
+			if !reflect.DeepEqual(v, tt.expect) {
+				t.Errorf("lookup(%q) = %#v, want %#v", tt.key, v, tt.expect)
+			}
+		})
+	}
+
+	// Only maps and slices have a wildcard
+	for _, key := range []string{"name.*", "missing.*", "missing.*.name"} {
+		if v, exists := NewEvaluator().newEvaluation(data, false).lookup(key); exists {
+			t.Errorf("lookup(%q) = %v, want missing", key, v)
+		}
+	}
+
+	// Wildcards combine with the any and all quantifiers
+	rules := []struct {
+		cond   Conditions
+		expect bool
+	}{
+		{NewSimpleCondition("scores.*", OperatorAny, OperatorValue{Operator: OperatorGte, Value: 90}), true},
+		{NewSimpleCondition("scores.*", OperatorAll, OperatorValue{Operator: OperatorGte, Value: 60}), true},
+		{NewSimpleCondition("scores.*", OperatorAll, OperatorValue{Operator: OperatorGte, Value: 80}), false},
+		{NewSimpleCondition("users.*.age", OperatorAny, map[string]interface{}{"operator": "<", "value": 18}), true},
+		{NewSimpleCondition("users.*.age", OperatorAll, map[string]interface{}{"operator": ">=", "value": 18}), false},
+		{NewSimpleCondition("scores.*", OperatorContains, 90), true},
+		{NewSimpleCondition("empty.*", OperatorAll, OperatorValue{Operator: OperatorGt, Value: 0}), true},
+		{NewSimpleCondition("empty.*", OperatorAny, OperatorValue{Operator: OperatorGt, Value: 0}), false},
+		{NewSimpleCondition("missing.*", OperatorAll, OperatorValue{Operator: OperatorGt, Value: 0}), false},
+	}
+	for _, tt := range rules {
+		if result := EvaluateCondition(tt.cond, data); result != tt.expect {
+			t.Errorf("%s %s %v = %v, want %v", tt.cond.Key, tt.cond.Operator, tt.cond.Value, result, tt.expect)
+		}
+	}
+}
+
+func TestEvaluator_FlattenArrayPaths(t *testing.T) {
+	data := map[string]interface{}{
+		"orders": []interface{}{
+			map[string]interface{}{"id": 1, "items": []interface{}{
+				map[string]interface{}{"sku": "A1", "qty": 2},
+				map[string]interface{}{"sku": "B2", "qty": 1},
+			}},
+			// Orders without items, and items without a sku, are skipped
+			map[string]interface{}{"id": 2},
+			map[string]interface{}{"id": 3, "items": []interface{}{
+				map[string]interface{}{"qty": 5},
+				map[string]interface{}{"sku": "A1", "qty": 1},
+			}},
+			map[string]interface{}{"id": 4, "items": "none"},
+		},
+		"matrix":     [][]int{{1, 2}, {3}},
+		"tags[]":     "literal",
+		"emptyLists": []interface{}{},
+	}
+
+	tests := []struct {
+		key    string
+		expect interface{}
+	}{
+		// Results are flattened across both levels and not deduplicated
+		{"orders[].items[].sku", []interface{}{"A1", "B2", "A1"}},
+		{"orders[].items[].qty", []interface{}{2, 1, 5, 1}},
+		{"orders[].id", []interface{}{1, 2, 3, 4}},
+		{"orders.*.items[].sku", []interface{}{"A1", "B2", "A1"}},
+		{"matrix[][]", []interface{}{1, 2, 3}},
+		{"matrix[]", []interface{}{[]int{1, 2}, []int{3}}},
+		{"emptyLists[].sku", []interface{}{}},
+		// A literal key wins
+		{"tags[]", "literal"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			v, exists := NewEvaluator().newEvaluation(data, false).lookup(tt.key)
+			if !exists {
+				t.Fatalf("lookup(%q) did not resolve", tt.key)
+			}
+			if !reflect.DeepEqual(v, tt.expect) {
+				t.Errorf("lookup(%q) = %#v, want %#v", tt.key, v, tt.expect)
+			}
+		})
+	}
+
+	rules := []struct {
+		cond   Conditions
+		expect bool
+	}{
+		{NewSimpleCondition("orders[].items[].sku", OperatorContains, "B2"), true},
+		{NewSimpleCondition("orders[].items[].sku", OperatorContains, "C3"), false},
+		{NewSimpleCondition("orders[].items[].sku", OperatorAny, OperatorValue{Operator: OperatorIn, Value: []string{"B2", "C3"}}), true},
+		{NewSimpleCondition("orders[].items[].sku", OperatorAll, OperatorValue{Operator: OperatorStartsWith, Value: "A"}), false},
+		{NewSimpleCondition("orders[].items[].qty", OperatorAll, OperatorValue{Operator: OperatorGt, Value: 0}), true},
+		{NewSimpleCondition("orders[].items[].sku", OperatorCount, 3), true},
+		{NewSimpleCondition("orders[].items[].sku", OperatorIsUnique, nil), false},
+		{NewSimpleCondition("missing[].sku", OperatorIsnull, nil), true},
+	}
+	for _, tt := range rules {
+		if result := EvaluateCondition(tt.cond, data); result != tt.expect {
+			t.Errorf("%s %s %v = %v, want %v", tt.cond.Key, tt.cond.Operator, tt.cond.Value, result, tt.expect)
+		}
+	}
+}
+
+func TestEvaluator_Params(t *testing.T) {
+	e := NewEvaluator()
+	e.Params = map[string]interface{}{
+		"deploy_region": "eu-west-1",
+		"min_age":       18,
+		"beta_regions":  []string{"eu-west-1", "ap-southeast-1"},
+	}
+
+	// A rule mixing a data field and a param
+	cond := NewAndGroup(
+		NewSimpleCondition("region", OperatorEq, ParamRef("deploy_region")),
+		NewSimpleCondition("age", OperatorGte, ParamRef("min_age")),
+	)
+	tests := []struct {
+		data   map[string]interface{}
+		expect bool
+	}{
+		{map[string]interface{}{"region": "eu-west-1", "age": 30}, true},
+		{map[string]interface{}{"region": "us-east-1", "age": 30}, false},
+		{map[string]interface{}{"region": "eu-west-1", "age": 16}, false},
+	}
+	for _, tt := range tests {
+		if result := e.EvaluateCondition(cond, tt.data); result != tt.expect {
+			t.Errorf("EvaluateCondition(%v) = %v, want %v", tt.data, result, tt.expect)
+		}
+	}
+
+	data := map[string]interface{}{"region": "ap-southeast-1", "scores": []interface{}{20, 15}}
+	if !e.EvaluateCondition(NewSimpleCondition("region", OperatorIn, ParamRef("beta_regions")), data) {
+		t.Error("expected region to be in the beta_regions param")
+	}
+	if !e.EvaluateCondition(NewSimpleCondition("scores", OperatorAny, OperatorValue{Operator: OperatorGte, Value: ParamRef("min_age")}), data) {
+		t.Error("expected params to resolve inside quantifiers")
+	}
+
+	// Unknown params fail, including when no params are set
+	missing := NewSimpleCondition("region", OperatorEq, ParamRef("unknown"))
+	if e.EvaluateCondition(missing, data) || EvaluateCondition(missing, data) {
+		t.Error("expected unknown param to evaluate to false")
+	}
+	if _, err := e.EvaluateConditionE(missing, data); !errors.Is(err, ErrUnknownParam) {
+		t.Errorf("err = %v, want ErrUnknownParam", err)
+	}
+}
+
+func TestFieldRef(t *testing.T) {
+	data := map[string]interface{}{
+		"selectedId":   "b",
+		"otherId":      "z",
+		"allowedIds":   []string{"a", "b", "c"},
+		"decodedIds":   []interface{}{"a", "b"},
+		"selectedCode": 2,
+		"codes":        []interface{}{1.0, 2.0},
+		"user": map[string]interface{}{
+			"allowedIds": []interface{}{"b", "d"},
+			"limit":      100,
+		},
+		"amount":  80,
+		"nullIds": nil,
+	}
+
+	tests := []struct {
+		name   string
+		cond   Conditions
+		expect bool
+	}{
+		{"in []string field", NewSimpleCondition("selectedId", OperatorIn, Ref("allowedIds")), true},
+		{"not in []string field", NewSimpleCondition("otherId", OperatorIn, Ref("allowedIds")), false},
+		{"in []interface{} field", NewSimpleCondition("selectedId", OperatorIn, Ref("decodedIds")), true},
+		{"nin []interface{} field", NewSimpleCondition("otherId", OperatorNin, Ref("decodedIds")), true},
+		{"nin when present", NewSimpleCondition("selectedId", OperatorNin, Ref("allowedIds")), false},
+		{"numbers across types", NewSimpleCondition("selectedCode", OperatorIn, Ref("codes")), true},
+		{"nested path", NewSimpleCondition("selectedId", OperatorIn, Ref("user.allowedIds")), true},
+		{"compare two fields", NewSimpleCondition("amount", OperatorLte, Ref("user.limit")), true},
+		{"equal fields", NewSimpleCondition("selectedId", OperatorEq, Ref("otherId")), false},
+		{"in missing field", NewSimpleCondition("selectedId", OperatorIn, Ref("missing")), false},
+		{"nin missing field", NewSimpleCondition("selectedId", OperatorNin, Ref("missing")), false},
+		{"in null field", NewSimpleCondition("selectedId", OperatorIn, Ref("nullIds")), false},
+		{"inside a quantifier", NewSimpleCondition("decodedIds", OperatorAll, OperatorValue{Operator: OperatorIn, Value: Ref("allowedIds")}), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := EvaluateCondition(tt.cond, data); result != tt.expect {
+				t.Errorf("EvaluateCondition() = %v, want %v", result, tt.expect)
+			}
+		})
+	}
+
+	// A missing referenced field is not an error
+	if result, err := EvaluateConditionE(NewSimpleCondition("selectedId", OperatorIn, Ref("missing")), data); result || err != nil {
+		t.Errorf("EvaluateConditionE(missing ref) = %v, %v, want false, nil", result, err)
+	}
+}
+
+func TestEvaluator_NoStringNumberCoercion(t *testing.T) {
+	strict := NewEvaluator()
+	strict.NoStringNumberCoercion = true
+
+	tests := []struct {
+		name    string
+		v       interface{}
+		op      Operator
+		value   interface{}
+		lenient bool
+		strict  bool
+	}{
+		{"zip code vs number", "012", OperatorEq, 12, true, false},
+		{"number vs numeric string", 25, OperatorEq, "25", true, false},
+		{"numeric strings with different text", "1.0", OperatorEq, "1", true, false},
+		{"same text", "012", OperatorEq, "012", true, true},
+		{"numbers of different types", 12, OperatorEq, 12.0, true, true},
+		{"json.Number is a number", json.Number("12"), OperatorEq, 12, true, true},
+		{"durations", "1h", OperatorEq, "60m", true, true},
+		{"bool vs truthy string", true, OperatorEq, "yes", true, true},
+		{"not equal", "012", OperatorNeq, 12, false, true},
+		{"in numbers", "012", OperatorIn, []interface{}{12, 13}, true, false},
+		{"in strings", "012", OperatorIn, []string{"012", "013"}, true, true},
+		{"in numeric strings", 12, OperatorIn, []string{"12"}, true, false},
+		{"nin", "012", OperatorNin, []interface{}{12}, false, true},
+		{"ordering still coerces", "25", OperatorGt, 18, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cond := NewSimpleCondition("field", tt.op, tt.value)
+			data := map[string]interface{}{"field": tt.v}
+			if result := EvaluateCondition(cond, data); result != tt.lenient {
+				t.Errorf("lenient %v %s %v = %v, want %v", tt.v, tt.op, tt.value, result, tt.lenient)
+			}
+			if result := strict.EvaluateCondition(cond, data); result != tt.strict {
+				t.Errorf("strict %v %s %v = %v, want %v", tt.v, tt.op, tt.value, result, tt.strict)
+			}
+		})
+	}
+
+	if !strict.EvaluateCondition(NewSimpleCondition("ids", OperatorIsUnique, nil), map[string]interface{}{"ids": []interface{}{"012", "12", 12.5}}) {
+		t.Error("expected \"012\" and \"12\" to be distinct")
+	}
+}
+
+func TestEvaluator_TrimIn(t *testing.T) {
+	countries := []interface{}{"TH", "SG", "MY"}
+	data := map[string]interface{}{
+		"padded":   " TH ",
+		"tab":      "\tSG\n",
+		"clean":    "MY",
+		"inside":   "T H",
+		"absent":   " US ",
+		"blank":    "   ",
+		"number":   " 44 ",
+		"notAText": 44,
+	}
+
+	tests := []struct {
+		name    string
+		key     string
+		op      Operator
+		value   interface{}
+		exact   bool
+		trimmed bool
+	}{
+		{"padded value", "padded", OperatorIn, countries, false, true},
+		{"tabs and newlines", "tab", OperatorIn, countries, false, true},
+		{"padded elements", "clean", OperatorIn, []string{" TH", "MY "}, false, true},
+		{"string slice", "padded", OperatorIn, []string{"TH", "SG"}, false, true},
+		{"inner whitespace kept", "inside", OperatorIn, countries, false, false},
+		{"absent", "absent", OperatorIn, countries, false, false},
+		{"blank is not empty element", "blank", OperatorIn, countries, false, false},
+		{"blank matches empty element", "blank", OperatorIn, []string{""}, false, true},
+		{"numeric string", "number", OperatorIn, []interface{}{1, 44}, false, true},
+		{"number field", "notAText", OperatorIn, []interface{}{" 44 "}, false, true},
+		{"map keys", "padded", OperatorIn, map[string]int{"TH": 1}, false, true},
+		{"nin padded value", "padded", OperatorNin, countries, true, false},
+		{"nin absent", "absent", OperatorNin, countries, true, true},
+		{"substring search", "padded", OperatorIn, "TH,SG", false, true},
+		{"equality unaffected", "padded", OperatorEq, "TH", false, false},
+	}
+
+	e := NewEvaluator()
+	e.TrimIn = true
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cond := NewSimpleCondition(tt.key, tt.op, tt.value)
+			if result := EvaluateCondition(cond, data); result != tt.exact {
+				t.Errorf("default = %v, want %v", result, tt.exact)
+			}
+			if result := e.EvaluateCondition(cond, data); result != tt.trimmed {
+				t.Errorf("with TrimIn = %v, want %v", result, tt.trimmed)
+			}
+		})
+	}
+
+	// Combines with InDelimiter, whose elements are always trimmed
+	e.InDelimiter = ","
+	if !e.EvaluateCondition(NewSimpleCondition("padded", OperatorIn, "SG , TH"), data) {
+		t.Error("expected \" TH \" in \"SG , TH\" with TrimIn and InDelimiter")
+	}
+}
+
+func TestEvaluator_StrictContains(t *testing.T) {
+	data := map[string]interface{}{
+		"zip":   12345,
+		"price": 19.99,
+		"text":  "order 12345",
+		"bytes": []byte("hello world"),
+		"tags":  []string{"ab", "c"},
+		"ids":   []interface{}{1, 2, 3},
+		"flag":  true,
+		"null":  nil,
+	}
+
+	tests := []struct {
+		name    string
+		key     string
+		op      Operator
+		value   interface{}
+		lenient bool
+		strict  bool
+	}{
+		{"number digits", "zip", OperatorContains, 234, true, false},
+		{"number digits ncontains", "zip", OperatorNcontains, 234, false, false},
+		{"float digits", "price", OperatorContains, ".9", true, false},
+		{"boolean", "flag", OperatorContains, "ru", true, false},
+		{"string substring", "text", OperatorContains, "123", true, true},
+		{"string number needle", "text", OperatorContains, 234, true, true},
+		{"string ncontains", "text", OperatorNcontains, "xyz", true, true},
+		{"byte slice is text", "bytes", OperatorContains, "lo wo", true, true},
+		{"slice element", "tags", OperatorContains, "c", true, true},
+		{"slice text across elements", "tags", OperatorContains, "b c", true, false},
+		{"slice ncontains across elements", "tags", OperatorNcontains, "b c", false, true},
+		{"slice numbers", "ids", OperatorContains, 2, true, true},
+		{"slice numeric string", "ids", OperatorContains, "2", true, true},
+		{"null field", "null", OperatorContains, "a", false, false},
+		{"str_contains number digits", "zip", OperatorStrContains, 234, true, true},
+		{"str_contains slice text", "tags", OperatorStrContains, "b c", true, true},
+		{"str_contains absent", "zip", OperatorStrContains, 999, false, false},
+	}
+
+	strict := NewEvaluator()
+	strict.StrictContains = true
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cond := NewSimpleCondition(tt.key, tt.op, tt.value)
+			if result := EvaluateCondition(cond, data); result != tt.lenient {
+				t.Errorf("default %s %s %v = %v, want %v", tt.key, tt.op, tt.value, result, tt.lenient)
+			}
+			if result := strict.EvaluateCondition(cond, data); result != tt.strict {
+				t.Errorf("strict %s %s %v = %v, want %v", tt.key, tt.op, tt.value, result, tt.strict)
+			}
+		})
+	}
+
+	for _, op := range []Operator{OperatorContains, OperatorNcontains} {
+		if _, err := strict.EvaluateConditionE(NewSimpleCondition("zip", op, 234), data); !errors.Is(err, ErrNotContainer) {
+			t.Errorf("%s on a number: err = %v, want ErrNotContainer", op, err)
+		}
+	}
+	if _, err := strict.EvaluateConditionE(NewSimpleCondition("text", OperatorContains, "123"), data); err != nil {
+		t.Errorf("contains on a string: err = %v", err)
+	}
+}
+
+func TestEvaluator_Resolver(t *testing.T) {
+	defer UnregisterDerivedField("tier")
+
+	calls := map[string]int{}
+	e := NewEvaluator()
+	e.Resolver = func(key string) (interface{}, bool) {
+		calls[key]++
+		switch key {
+		case "credit_score":
+			return 720, true
+		case "limits.daily":
+			return 500, true
+		case "orders[].total":
+			return []interface{}{10, 20}, true
+		case "tier":
+			return "resolved", true
+		}
+		return nil, false
+	}
+	RegisterDerivedField("tier", func(data map[string]interface{}) (interface{}, bool) {
+		return "derived", true
+	})
+
+	data := map[string]interface{}{"amount": 300, "credit_score": 500}
+	tests := []struct {
+		name   string
+		cond   Conditions
+		expect bool
+		calls  map[string]int
+	}{
+		{"data wins", NewSimpleCondition("credit_score", OperatorEq, 500), true, map[string]int{}},
+		{"resolved key", NewSimpleCondition("limits.daily", OperatorGte, 500), true, map[string]int{"limits.daily": 1}},
+		{"once per key", NewAndGroup(
+			NewSimpleCondition("limits.daily", OperatorGt, 100),
+			NewSimpleCondition("limits.daily", OperatorLt, 1000),
+			NewSimpleCondition("amount", OperatorLte, Ref("limits.daily")),
+		), true, map[string]int{"limits.daily": 1}},
+		{"missing once", NewOrGroup(
+			NewSimpleCondition("unknown", OperatorIsnull, nil),
+			NewSimpleCondition("unknown", OperatorEq, 1),
+		), true, map[string]int{"unknown": 1}},
+		{"missing key stays missing", NewAndGroup(
+			NewSimpleCondition("unknown", OperatorEq, 1),
+			NewSimpleCondition("unknown", OperatorIsnull, nil),
+		), false, map[string]int{"unknown": 1}},
+		{"short circuit skips fetch", NewOrGroup(
+			NewSimpleCondition("amount", OperatorGt, 0),
+			NewSimpleCondition("limits.daily", OperatorGt, 0),
+		), true, map[string]int{}},
+		{"key as written", NewSimpleCondition("orders[].total", OperatorAny, OperatorValue{Operator: OperatorGt, Value: 15}), true, map[string]int{"orders[].total": 1}},
+		{"derived fields first", NewSimpleCondition("tier", OperatorEq, "derived"), true, map[string]int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = map[string]int{}
+			if result := e.EvaluateCondition(tt.cond, data); result != tt.expect {
+				t.Errorf("EvaluateCondition() = %v, want %v", result, tt.expect)
+			}
+			if !reflect.DeepEqual(calls, tt.calls) {
+				t.Errorf("resolver calls = %v, want %v", calls, tt.calls)
+			}
+		})
+	}
+
+	// Each evaluation fetches again
+	calls = map[string]int{}
+	cond := NewSimpleCondition("limits.daily", OperatorEq, 500)
+	e.EvaluateCondition(cond, data)
+	e.EvaluateCondition(cond, data)
+	if calls["limits.daily"] != 2 {
+		t.Errorf("resolver called %d times over two evaluations, want 2", calls["limits.daily"])
+	}
+}
+
+func TestEvaluator_ZeroIsEmpty(t *testing.T) {
+	type level int
+	data := map[string]interface{}{
+		"int":        0,
+		"int64":      int64(0),
+		"uint":       uint8(0),
+		"float":      0.0,
+		"negZero":    math.Copysign(0, -1),
+		"named":      level(0),
+		"number":     json.Number("0.0"),
+		"false":      false,
+		"zeroTime":   time.Time{},
+		"one":        1,
+		"fraction":   0.5,
+		"numberOne":  json.Number("1"),
+		"true":       true,
+		"time":       time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		"zeroString": "0",
+		"falseText":  "false",
+		"empty":      "",
+		"list":       []int{0},
+	}
+
+	tests := []struct {
+		key   string
+		plain bool
+		zero  bool
+	}{
+		{"int", false, true},
+		{"int64", false, true},
+		{"uint", false, true},
+		{"float", false, true},
+		{"negZero", false, true},
+		{"named", false, true},
+		{"number", false, true},
+		{"false", false, true},
+		{"zeroTime", false, true},
+		{"one", false, false},
+		{"fraction", false, false},
+		{"numberOne", false, false},
+		{"true", false, false},
+		{"time", false, false},
+		{"zeroString", false, false},
+		{"falseText", false, false},
+		{"empty", true, true},
+		{"list", false, false},
+		{"missing", true, true},
+	}
+
+	e := NewEvaluator()
+	e.ZeroIsEmpty = true
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			cond := NewSimpleCondition(tt.key, OperatorIsEmpty, nil)
+			if result := EvaluateCondition(cond, data); result != tt.plain {
+				t.Errorf("isempty(%s) = %v, want %v", tt.key, result, tt.plain)
+			}
+			if result := e.EvaluateCondition(cond, data); result != tt.zero {
+				t.Errorf("isempty(%s) with ZeroIsEmpty = %v, want %v", tt.key, result, tt.zero)
+			}
+			notEmpty := NewSimpleCondition(tt.key, OperatorIsNotEmpty, nil)
+			if result := EvaluateCondition(notEmpty, data); result == tt.plain {
+				t.Errorf("isnotempty(%s) = %v, want %v", tt.key, result, !tt.plain)
+			}
+			if result := e.EvaluateCondition(notEmpty, data); result == tt.zero {
+				t.Errorf("isnotempty(%s) with ZeroIsEmpty = %v, want %v", tt.key, result, !tt.zero)
+			}
+		})
+	}
+}
+
+func TestLazyValues(t *testing.T) {
+	calls := map[string]int{}
+	lazy := func(name string, v interface{}) func() interface{} {
+		return func() interface{} {
+			calls[name]++
+			return v
+		}
+	}
+	data := map[string]interface{}{
+		"score":   lazy("score", 720),
+		"profile": lazy("profile", map[string]interface{}{"tier": "gold", "age": lazy("age", 30)}),
+		"scores":  []interface{}{lazy("first", 90), 80},
+		"Country": lazy("country", "TH"),
+		"unused":  lazy("unused", true),
+		"nothing": (func() interface{})(nil),
+		"getter":  func() string { return "not lazy" },
+	}
+
+	e := NewEvaluator()
+	e.CaseInsensitiveKeys = true
+	e.Params = map[string]interface{}{"minScore": lazy("minScore", 700)}
+	cond := NewAndGroup(
+		NewSimpleCondition("score", OperatorGte, ParamRef("minScore")),
+		NewSimpleCondition("score", OperatorLt, 800),
+		NewSimpleCondition("profile.tier", OperatorEq, "gold"),
+		NewSimpleCondition("profile.age", OperatorBetween, []interface{}{18, 65}),
+		NewSimpleCondition("profile.age", OperatorEq, FieldRef("profile.age")),
+		NewSimpleCondition("scores.*", OperatorAll, OperatorValue{Operator: OperatorGte, Value: 80}),
+		NewSimpleCondition("country", OperatorEq, "TH"),
+		NewSimpleCondition("nothing", OperatorIsnull, nil),
+		NewSimpleCondition("getter", OperatorIsnotnull, nil),
+		NewOrGroup(
+			NewSimpleCondition("score", OperatorGt, 0),
+			NewSimpleCondition("unused", OperatorIsTrue, nil),
+		),
+	)
+	if result, err := e.EvaluateConditionE(cond, data); !result || err != nil {
+		t.Fatalf("EvaluateConditionE() = %v, %v, want true, nil", result, err)
+	}
+	want := map[string]int{"score": 1, "profile": 1, "age": 1, "first": 1, "country": 1, "minScore": 1}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+
+	// Each evaluation calls the lazy values it needs again
+	if !e.EvaluateCondition(NewSimpleCondition("score", OperatorEq, 720), data) {
+		t.Error("expected score to be 720")
+	}
+	if calls["score"] != 2 {
+		t.Errorf("score called %d times after two evaluations, want 2", calls["score"])
+	}
+}
@@ -0,0 +1,125 @@
+package jsonvaluate
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"sync"
+)
+
+// FormatChecker reports whether a string is in a named format for the
+// isformat operator.
+type FormatChecker func(s string) bool
+
+// Errors returned by RegisterFormat and the isformat operator.
+var (
+	ErrNilFormatChecker = errors.New("format checker cannot be nil")
+	ErrBuiltinFormat    = errors.New("format conflicts with a built-in format")
+	ErrUnknownFormat    = errors.New("unknown format")
+)
+
+// uuidPattern matches UUIDs in their canonical 8-4-4-4-12 hex form.
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// builtinFormats are the formats known to isformat without registration.
+var builtinFormats = map[string]FormatChecker{
+	"email": isEmail,
+	"url":   isURL,
+	"uuid":  uuidPattern.MatchString,
+	"ip":    isIP,
+}
+
+// Thread-safe registry for custom formats
+var (
+	customFormats      = make(map[string]FormatChecker)
+	customFormatsMutex sync.RWMutex
+)
+
+// RegisterFormat registers a named format for the isformat operator,
+// replacing any custom format registered under the same name. It returns
+// ErrNilFormatChecker if check is nil and ErrBuiltinFormat if name is one of
+// the built-in formats.
+//
+// Example:
+//
+//	RegisterFormat("sku", regexp.MustCompile(`^[A-Z]{3}-\d{4}$`).MatchString)
+func RegisterFormat(name string, check FormatChecker) error {
+	if check == nil {
+		return ErrNilFormatChecker
+	}
+	if _, builtin := builtinFormats[name]; builtin {
+		return fmt.Errorf("%w: %q", ErrBuiltinFormat, name)
+	}
+
+	customFormatsMutex.Lock()
+	defer customFormatsMutex.Unlock()
+	customFormats[name] = check
+	return nil
+}
+
+// UnregisterFormat removes a custom format from the registry. Built-in
+// formats cannot be unregistered.
+func UnregisterFormat(name string) {
+	customFormatsMutex.Lock()
+	defer customFormatsMutex.Unlock()
+	delete(customFormats, name)
+}
+
+// HasFormat reports whether name is a built-in or registered format.
+func HasFormat(name string) bool {
+	_, ok := lookupFormat(name)
+	return ok
+}
+
+// lookupFormat returns the checker for a built-in or registered format.
+func lookupFormat(name string) (FormatChecker, bool) {
+	if check, ok := builtinFormats[name]; ok {
+		return check, true
+	}
+	customFormatsMutex.RLock()
+	defer customFormatsMutex.RUnlock()
+	check, ok := customFormats[name]
+	return check, ok
+}
+
+// isFormat checks if v is a string in the format named by format. Values that
+// aren't strings never match; an unknown format fails with ErrUnknownFormat.
+func isFormat(v, format interface{}) (bool, error) {
+	name, ok := format.(string)
+	if !ok {
+		return false, fmt.Errorf("%w: %v", ErrUnknownFormat, format)
+	}
+	check, ok := lookupFormat(name)
+	if !ok {
+		return false, fmt.Errorf("%w: %q", ErrUnknownFormat, name)
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return false, nil
+	}
+	return check(s), nil
+}
+
+// isEmail checks if s is a bare email address such as "bob@example.com".
+// Addresses with a display name, like "Bob <bob@example.com>", don't match.
+func isEmail(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Address == s
+}
+
+// isURL checks if s is an absolute URL with a scheme and a host, such as
+// "https://example.com/path".
+func isURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
+
+// isIP checks if s is an IPv4 or IPv6 address.
+func isIP(s string) bool {
+	_, err := netip.ParseAddr(s)
+	return err == nil
+}
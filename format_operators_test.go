@@ -0,0 +1,101 @@
+package jsonvaluate
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+)
+
+func TestIsFormatOperator(t *testing.T) {
+	tests := []struct {
+		value  interface{}
+		format interface{}
+		expect bool
+	}{
+		{"bob@example.com", "email", true},
+		{"bob.smith+tag@mail.example.co.uk", "email", true},
+		{"Bob <bob@example.com>", "email", false},
+		{"bob@", "email", false},
+		{"@example.com", "email", false},
+		{"bob@@example.com", "email", false},
+		{"bob example.com", "email", false},
+		{"https://example.com/path?q=1", "url", true},
+		{"ftp://files.example.com", "url", true},
+		{"example.com", "url", false},
+		{"/relative/path", "url", false},
+		{"https://", "url", false},
+		{"123e4567-e89b-12d3-a456-426614174000", "uuid", true},
+		{"123E4567-E89B-12D3-A456-426614174000", "uuid", true},
+		{"123e4567e89b12d3a456426614174000", "uuid", false},
+		{"123e4567-e89b-12d3-a456-42661417400", "uuid", false},
+		{"g23e4567-e89b-12d3-a456-426614174000", "uuid", false},
+		{"192.168.1.1", "ip", true},
+		{"2001:db8::1", "ip", true},
+		{"256.1.1.1", "ip", false},
+		// Non-strings never match
+		{42, "email", false},
+		{nil, "uuid", false},
+		{[]byte("bob@example.com"), "email", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%v isformat %v", tt.value, tt.format), func(t *testing.T) {
+			data := map[string]interface{}{"field": tt.value}
+			if result := evalSingleCondition("field", OperatorIsFormat, tt.format, data); result != tt.expect {
+				t.Errorf("isformat(%v, %v) = %v, want %v", tt.value, tt.format, result, tt.expect)
+			}
+		})
+	}
+}
+
+func TestIsFormatOperator_UnknownFormat(t *testing.T) {
+	data := map[string]interface{}{"email": "bob@example.com"}
+	for _, format := range []interface{}{"phone", nil, 3} {
+		cond := NewSimpleCondition("email", OperatorIsFormat, format)
+		if EvaluateCondition(cond, data) {
+			t.Errorf("format %v: expected false", format)
+		}
+		if _, err := EvaluateConditionE(cond, data); !errors.Is(err, ErrUnknownFormat) {
+			t.Errorf("format %v: err = %v, want ErrUnknownFormat", format, err)
+		}
+	}
+}
+
+func TestRegisterFormat(t *testing.T) {
+	defer UnregisterFormat("sku")
+
+	if HasFormat("sku") {
+		t.Fatal("sku should not be registered yet")
+	}
+	if err := RegisterFormat("sku", regexp.MustCompile(`^[A-Z]{3}-\d{4}$`).MatchString); err != nil {
+		t.Fatalf("RegisterFormat: %v", err)
+	}
+	if !HasFormat("sku") || !HasFormat("email") {
+		t.Error("expected sku and email to be known formats")
+	}
+
+	data := map[string]interface{}{"good": "ABC-1234", "bad": "abc-12"}
+	if !evalSingleCondition("good", OperatorIsFormat, "sku", data) {
+		t.Error("expected ABC-1234 to be a sku")
+	}
+	if evalSingleCondition("bad", OperatorIsFormat, "sku", data) {
+		t.Error("expected abc-12 not to be a sku")
+	}
+
+	if err := RegisterFormat("email", isURL); !errors.Is(err, ErrBuiltinFormat) {
+		t.Errorf("err = %v, want ErrBuiltinFormat", err)
+	}
+	if err := RegisterFormat("nothing", nil); !errors.Is(err, ErrNilFormatChecker) {
+		t.Errorf("err = %v, want ErrNilFormatChecker", err)
+	}
+
+	UnregisterFormat("sku")
+	if HasFormat("sku") {
+		t.Error("sku should be unregistered")
+	}
+	UnregisterFormat("email")
+	if !HasFormat("email") {
+		t.Error("built-in formats cannot be unregistered")
+	}
+}
@@ -0,0 +1,1154 @@
+package jsonvaluate
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/netip"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// typeIs checks if the runtime type of v matches the type name, or one of the
+// type names, in types. Supported names are "number", "string", "bool",
+// "array", "object", "time" and "null". No coercion is applied: a numeric
+// string is a "string", not a "number", and a date string is not a "time".
+func typeIs(v, types interface{}) bool {
+	if name, ok := types.(string); ok {
+		return hasTypeName(v, name)
+	}
+
+	tv := reflect.ValueOf(types)
+	if tv.Kind() != reflect.Slice && tv.Kind() != reflect.Array {
+		return false
+	}
+	for i := 0; i < tv.Len(); i++ {
+		if hasTypeName(v, toString(tv.Index(i).Interface())) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasTypeName checks if the runtime type of v matches a single type name
+func hasTypeName(v interface{}, name string) bool {
+	if v == nil {
+		return name == "null"
+	}
+	if _, ok := v.(time.Time); ok {
+		return name == "time"
+	}
+
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return name == "number"
+	case reflect.String:
+		return name == "string"
+	case reflect.Bool:
+		return name == "bool"
+	case reflect.Slice, reflect.Array:
+		return name == "array"
+	case reflect.Map, reflect.Struct:
+		return name == "object"
+	default:
+		return false
+	}
+}
+
+// withinPct checks if the number v is within percent percent of target, with
+// spec given as [target, percent]: |v - target| <= |target| * percent / 100.
+// With a zero target only an exact match passes. Non-numeric values and
+// malformed specs never match.
+func withinPct(v, spec interface{}) bool {
+	sv := reflect.ValueOf(spec)
+	if (sv.Kind() != reflect.Slice && sv.Kind() != reflect.Array) || sv.Len() != 2 {
+		return false
+	}
+	n, ok := toNumber(v)
+	if !ok {
+		return false
+	}
+	target, ok := toNumber(sv.Index(0).Interface())
+	if !ok {
+		return false
+	}
+	percent, ok := toNumber(sv.Index(1).Interface())
+	if !ok || percent < 0 {
+		return false
+	}
+	return math.Abs(n-target) <= math.Abs(target)*percent/100
+}
+
+// pctBetween checks if v is a number between lowPct and highPct percent of the
+// number in the field basisKey, given as [basisKey, lowPct, highPct], so
+// ["sum_insured", 10, 90] accepts 10% to 90% of sum_insured, inclusive. The
+// key may also be a FieldRef. A null percentage is an open bound, as for
+// between, and the bounds are put in order, so a negative basis works too.
+// A missing or non-numeric basis never matches.
+func (ev *evaluation) pctBetween(v, spec interface{}) bool {
+	sv := reflect.ValueOf(spec)
+	if (sv.Kind() != reflect.Slice && sv.Kind() != reflect.Array) || sv.Len() != 3 {
+		return false
+	}
+	var key string
+	switch k := sv.Index(0).Interface().(type) {
+	case string:
+		key = k
+	case FieldRef:
+		key = string(k)
+	default:
+		return false
+	}
+	n, ok := toNumber(v)
+	if !ok {
+		return false
+	}
+	basisValue, exists := ev.lookup(key)
+	if !exists {
+		return false
+	}
+	basis, ok := toNumber(deref(basisValue))
+	if !ok {
+		return false
+	}
+
+	var bounds [2]*float64
+	for i := range bounds {
+		pct := sv.Index(i + 1).Interface()
+		if pct == nil {
+			continue
+		}
+		p, ok := toNumber(pct)
+		if !ok {
+			return false
+		}
+		bound := basis * p / 100
+		bounds[i] = &bound
+	}
+	low, high := bounds[0], bounds[1]
+	if low != nil && high != nil && *low > *high {
+		low, high = high, low
+	}
+	return (low == nil || n >= *low) && (high == nil || n <= *high)
+}
+
+// Bucket is one entry of the ordered list of the "bucket_is" operator: the
+// largest number in the bucket and its label. A nil Max means no upper bound,
+// for a last bucket that takes every remaining number. In JSON it is written
+// as {"max": 12, "label": "child"}, or {"label": "senior"} without a bound.
+type Bucket struct {
+	Max   interface{} `json:"max,omitempty"`
+	Label string      `json:"label"`
+}
+
+// bucketIs puts the number v in the first of an ordered list of buckets whose
+// max it doesn't exceed and checks the bucket's label, given as [buckets,
+// label]. Bounds are inclusive, so with [{max: 12, label: "child"}, {max: 19,
+// label: "teen"}] 12 is a child and 12.5 a teen. The buckets are a slice of
+// Bucket or of decoded JSON objects. A number above every max has no bucket,
+// so it never matches; neither do non-numeric values, malformed specs, nor a
+// non-numeric max reached before the number's bucket.
+func (ev *evaluation) bucketIs(v, spec interface{}) bool {
+	sv := reflect.ValueOf(spec)
+	if (sv.Kind() != reflect.Slice && sv.Kind() != reflect.Array) || sv.Len() != 2 {
+		return false
+	}
+	buckets := reflect.ValueOf(sv.Index(0).Interface())
+	if buckets.Kind() != reflect.Slice && buckets.Kind() != reflect.Array {
+		return false
+	}
+	n, ok := toNumber(v)
+	if !ok || math.IsNaN(n) {
+		return false
+	}
+
+	for i := 0; i < buckets.Len(); i++ {
+		bucket, ok := toBucket(buckets.Index(i).Interface())
+		if !ok {
+			return false
+		}
+		if bucket.Max != nil {
+			max, ok := toNumber(bucket.Max)
+			if !ok || math.IsNaN(max) {
+				return false
+			}
+			if n > max {
+				continue
+			}
+		}
+		return ev.equals(bucket.Label, sv.Index(1).Interface())
+	}
+	return false
+}
+
+// toBucket converts a Bucket or decoded JSON object to a Bucket
+func toBucket(v interface{}) (Bucket, bool) {
+	switch val := v.(type) {
+	case Bucket:
+		return val, true
+	case map[string]interface{}:
+		label, ok := val["label"].(string)
+		return Bucket{Max: val["max"], Label: label}, ok
+	}
+	return Bucket{}, false
+}
+
+// hasBits checks if every bit of mask is set in v (bitset) or none is
+// (bitclear), so a permission bitmask of 0x05 has the bits 0x04 and 0x01 set
+// and 0x02 clear. Both must be non-negative integers, or numeric strings or
+// floats with no fractional part; anything else never matches.
+func hasBits(v interface{}, op Operator, mask interface{}) bool {
+	bits, ok := toBits(v)
+	if !ok {
+		return false
+	}
+	m, ok := toBits(mask)
+	if !ok {
+		return false
+	}
+	if op == OperatorBitSet {
+		return bits&m == m
+	}
+	return bits&m == 0
+}
+
+// toBits converts a non-negative integer value to a uint64. Integer types
+// are converted exactly; other numbers must be whole and in range.
+func toBits(v interface{}) (uint64, bool) {
+	if negative, magnitude, ok := toInteger(v); ok {
+		return magnitude, !negative
+	}
+	n, ok := toNumber(v)
+	if !ok || n < 0 || n >= 1<<64 || n != math.Trunc(n) {
+		return 0, false
+	}
+	return uint64(n), true
+}
+
+// isInteger checks if v is a finite number, or numeric string, with no
+// fractional part, so 3, 3.0 and "10" are integers but 3.5 and "10.5" aren't.
+func isInteger(v interface{}) bool {
+	n, ok := toNumber(v)
+	return ok && !math.IsInf(n, 0) && n == math.Trunc(n)
+}
+
+// isUnique checks if no two elements of the slice or array v are equal, as
+// by ==. An empty collection is unique; values that aren't collections never
+// are. Elements are grouped by a hash where equality allows it, so that only
+// elements sharing a hash are compared; collections holding booleans, times,
+// durations or nested values, or evaluated with FloatTolerance, are compared
+// pairwise.
+func (ev *evaluation) isUnique(v interface{}) bool {
+	switch v.(type) {
+	case []byte, json.RawMessage:
+		// Byte slices hold text
+		return false
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return false
+	}
+
+	elems := make([]interface{}, rv.Len())
+	for i := range elems {
+		elems[i] = deref(rv.Index(i).Interface())
+	}
+	if ev.FloatTolerance == 0 {
+		if buckets, ok := hashElements(elems); ok {
+			for _, bucket := range buckets {
+				if !ev.allDistinct(bucket) {
+					return false
+				}
+			}
+			return true
+		}
+	}
+	return ev.allDistinct(elems)
+}
+
+// allDistinct compares elems pairwise, reporting whether no two are equal
+func (ev *evaluation) allDistinct(elems []interface{}) bool {
+	for i := range elems {
+		for j := i + 1; j < len(elems); j++ {
+			if ev.isEqual(elems[i], elems[j]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// hashElements groups elems so that elements equal under isEqual share a
+// group: numbers and numeric strings by their float64 value, duration strings
+// by their length, and other strings by their text. It fails for elements
+// whose equality crosses these groups, such as booleans (true equals "yes"),
+// time.Duration values and strings like "0" that are both numbers and
+// durations.
+func hashElements(elems []interface{}) (map[string][]interface{}, bool) {
+	buckets := make(map[string][]interface{}, len(elems))
+	for _, elem := range elems {
+		var key string
+		switch val := elem.(type) {
+		case nil:
+			key = "null"
+		case time.Duration:
+			return nil, false
+		case string:
+			n, isNumber := toNumber(val)
+			d, isDuration := toDuration(val)
+			switch {
+			case isNumber && isDuration:
+				return nil, false
+			case isNumber:
+				key = numberKey(n)
+			case isDuration:
+				key = "d:" + strconv.FormatInt(int64(d), 10)
+			default:
+				key = "s:" + val
+			}
+		default:
+			if isBool(val) {
+				return nil, false
+			}
+			n, ok := toNumber(val)
+			if !ok {
+				return nil, false
+			}
+			key = numberKey(n)
+		}
+		buckets[key] = append(buckets[key], elem)
+	}
+	return buckets, true
+}
+
+// numberKey returns the hash key of a number for hashElements
+func numberKey(n float64) string {
+	if n == 0 {
+		// -0 equals 0
+		n = 0
+	}
+	return "n:" + strconv.FormatFloat(n, 'g', -1, 64)
+}
+
+// onStep checks if the number v is in [min, max] and (v - min) is a whole
+// multiple of step, with spec given as [min, max, step]. Floating-point noise
+// is absorbed, so 0.3 is on the 0.1 grid. Non-numeric values, malformed specs
+// and steps that aren't positive never match.
+func onStep(v, spec interface{}) bool {
+	sv := reflect.ValueOf(spec)
+	if (sv.Kind() != reflect.Slice && sv.Kind() != reflect.Array) || sv.Len() != 3 {
+		return false
+	}
+	var bounds [3]float64
+	for i := range bounds {
+		n, ok := toNumber(sv.Index(i).Interface())
+		if !ok {
+			return false
+		}
+		bounds[i] = n
+	}
+	min, max, step := bounds[0], bounds[1], bounds[2]
+	n, ok := toNumber(v)
+	if !ok || step <= 0 || n < min || n > max {
+		return false
+	}
+	steps := (n - min) / step
+	return math.Abs(steps-math.Round(steps)) <= 1e-9*math.Max(1, math.Abs(steps))
+}
+
+// lengthBetween checks if the length of v is in [min, max], inclusive, with
+// bounds given as a [min, max] slice. The length of a string, or byte slice,
+// is its number of characters and that of a slice, array or map its number of
+// elements; other values have no length. A nil bound is open, as for between.
+func lengthBetween(v, bounds interface{}) bool {
+	var length int
+	switch val := v.(type) {
+	case string:
+		length = utf8.RuneCountInString(val)
+	case []byte:
+		length = utf8.RuneCount(val)
+	case json.RawMessage:
+		length = utf8.RuneCount(val)
+	default:
+		rv := reflect.ValueOf(v)
+		switch rv.Kind() {
+		case reflect.String:
+			length = utf8.RuneCountInString(rv.String())
+		case reflect.Slice, reflect.Array, reflect.Map:
+			length = rv.Len()
+		default:
+			return false
+		}
+	}
+
+	bv := reflect.ValueOf(bounds)
+	if (bv.Kind() != reflect.Slice && bv.Kind() != reflect.Array) || bv.Len() != 2 {
+		return false
+	}
+	if min := bv.Index(0).Interface(); min != nil {
+		n, ok := toNumber(min)
+		if !ok || float64(length) < n {
+			return false
+		}
+	}
+	if max := bv.Index(1).Interface(); max != nil {
+		n, ok := toNumber(max)
+		if !ok || float64(length) > n {
+			return false
+		}
+	}
+	return true
+}
+
+// fuzzyEq checks if the text of v is within a Levenshtein distance of the
+// text of target, with spec given as [target, maxDistance], so ["Jonathan",
+// 2] accepts "Jonathon" and "Johnathan". Distances count inserted, deleted
+// and substituted characters, and case matters. A null field, a malformed
+// spec and a negative or fractional distance never match.
+func fuzzyEq(v, spec interface{}) bool {
+	sv := reflect.ValueOf(spec)
+	if (sv.Kind() != reflect.Slice && sv.Kind() != reflect.Array) || sv.Len() != 2 || v == nil {
+		return false
+	}
+	target := sv.Index(0).Interface()
+	if target == nil {
+		return false
+	}
+	maxDistance, ok := toNumber(sv.Index(1).Interface())
+	if !ok || maxDistance < 0 || maxDistance != math.Trunc(maxDistance) {
+		return false
+	}
+	return levenshtein([]rune(toString(v)), []rune(toString(target)), int(math.Min(maxDistance, math.MaxInt32))) >= 0
+}
+
+// levenshtein returns the edit distance between a and b, or -1 as soon as it
+// is known to exceed max
+func levenshtein(a, b []rune, max int) int {
+	if len(a) < len(b) {
+		a, b = b, a
+	}
+	if len(a)-len(b) > max {
+		return -1
+	}
+
+	// Distances from a prefix of a to each prefix of b, one row at a time
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		rowMin := curr[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			if curr[j] < rowMin {
+				rowMin = curr[j]
+			}
+		}
+		if rowMin > max {
+			return -1
+		}
+		prev, curr = curr, prev
+	}
+	if prev[len(b)] > max {
+		return -1
+	}
+	return prev[len(b)]
+}
+
+// countIs compares the number of elements in a slice, array or map with
+// expected, which is either a count (compared with ==) or an [operator, count]
+// pair such as [">=", 1]. The operator must be one of ==, !=, >, >=, < or <=.
+// Other field values, including strings and byte slices, are not collections
+// and never match.
+func countIs(v, expected interface{}) bool {
+	switch v.(type) {
+	case []byte, json.RawMessage:
+		return false
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array && rv.Kind() != reflect.Map {
+		return false
+	}
+
+	op, n := OperatorEq, expected
+	if pair := reflect.ValueOf(expected); pair.Kind() == reflect.Slice || pair.Kind() == reflect.Array {
+		if pair.Len() != 2 {
+			return false
+		}
+		name, ok := pair.Index(0).Interface().(string)
+		if !ok {
+			return false
+		}
+		op, n = Operator(name), pair.Index(1).Interface()
+	}
+	if count, ok := toNumber(n); !ok || math.IsNaN(count) {
+		return false
+	}
+
+	c := compareValues(rv.Len(), n)
+	switch op {
+	case OperatorEq:
+		return c == 0
+	case OperatorNeq:
+		return c != 0
+	case OperatorGt:
+		return c > 0
+	case OperatorGte:
+		return c >= 0
+	case OperatorLt:
+		return c < 0
+	case OperatorLte:
+		return c <= 0
+	default:
+		return false
+	}
+}
+
+// compareSets compares the field and expected value as sets for the superset,
+// subset and set_eq operators. Both must be slices or arrays; elements are
+// matched with isEqual, so order and duplicates don't matter.
+func compareSets(v interface{}, op Operator, expected interface{}) bool {
+	a, ok := toElements(v)
+	if !ok {
+		return false
+	}
+	b, ok := toElements(expected)
+	if !ok {
+		return false
+	}
+
+	switch op {
+	case OperatorSuperset:
+		return containsAll(a, b)
+	case OperatorSubset:
+		return containsAll(b, a)
+	case OperatorSetEq:
+		return containsAll(a, b) && containsAll(b, a)
+	default:
+		return false
+	}
+}
+
+// toElements returns the elements of a slice or array. Byte slices hold text
+// and are not collections.
+func toElements(v interface{}) ([]interface{}, bool) {
+	switch v.(type) {
+	case []byte, json.RawMessage:
+		return nil, false
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+	elements := make([]interface{}, rv.Len())
+	for i := range elements {
+		elements[i] = rv.Index(i).Interface()
+	}
+	return elements, true
+}
+
+// containsAll checks if every element of sub equals some element of set
+func containsAll(set, sub []interface{}) bool {
+	for _, want := range sub {
+		if !isIn(want, set) {
+			return false
+		}
+	}
+	return true
+}
+
+// setContains checks if the set of elements of v contains the value, or
+// every element of the value when it is a list, with spec given as
+// [delimiter, value]. A string v is split on the delimiter into trimmed,
+// non-empty elements, so "a; b;c" split on ";" contains "b" and ["c", "a"],
+// and a slice or array v is used as it is. Elements are matched with isEqual.
+// Other fields, an empty delimiter and malformed specs never match.
+func setContains(v, spec interface{}) bool {
+	sv := reflect.ValueOf(spec)
+	if (sv.Kind() != reflect.Slice && sv.Kind() != reflect.Array) || sv.Len() != 2 {
+		return false
+	}
+	delimiter, ok := sv.Index(0).Interface().(string)
+	if !ok || delimiter == "" {
+		return false
+	}
+
+	var set []interface{}
+	if str, ok := v.(string); ok {
+		for _, element := range splitList(str, delimiter) {
+			set = append(set, element)
+		}
+	} else if set, ok = toElements(v); !ok {
+		return false
+	}
+
+	want := sv.Index(1).Interface()
+	if wants, ok := toElements(want); ok {
+		return containsAll(set, wants)
+	}
+	return want != nil && isIn(want, set)
+}
+
+// compareRank compares the positions of v and a threshold in an ordered list.
+// spec is [order, threshold], e.g. [["bronze", "silver", "gold"], "silver"].
+// Elements are matched with isEqual; if v or the threshold isn't in the list
+// the comparison is false.
+func compareRank(v interface{}, op Operator, spec interface{}) bool {
+	sv := reflect.ValueOf(spec)
+	if (sv.Kind() != reflect.Slice && sv.Kind() != reflect.Array) || sv.Len() != 2 {
+		return false
+	}
+	order := sv.Index(0).Interface()
+	threshold := sv.Index(1).Interface()
+
+	pos, threshPos := rankOf(v, order), rankOf(threshold, order)
+	if pos < 0 || threshPos < 0 {
+		return false
+	}
+
+	switch op {
+	case OperatorRankGt:
+		return pos > threshPos
+	case OperatorRankGte:
+		return pos >= threshPos
+	case OperatorRankLt:
+		return pos < threshPos
+	case OperatorRankLte:
+		return pos <= threshPos
+	default:
+		return false
+	}
+}
+
+// rankOf returns the index of v in the ordered list, or -1 if it isn't found
+func rankOf(v, order interface{}) int {
+	if v == nil {
+		return -1
+	}
+	ov := reflect.ValueOf(order)
+	if ov.Kind() != reflect.Slice && ov.Kind() != reflect.Array {
+		return -1
+	}
+	for i := 0; i < ov.Len(); i++ {
+		if isEqual(v, ov.Index(i).Interface()) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Schema is a minimal description of an object's structure, used as the value
+// of the "matches_schema" operator. It is not JSON Schema: it only lists the
+// keys that must be present and the allowed types of keys.
+//
+// In JSON it is written as:
+//
+//	{"required": ["street", "zip"], "properties": {"street": "string", "zip": ["string", "number"]}}
+type Schema struct {
+	// Required keys must be present in the object (their value may be null
+	// unless a type in Properties excludes it).
+	Required []string `json:"required,omitempty"`
+	// Properties maps keys to a type name, or a list of type names, as
+	// accepted by the "typeis" operator. Keys not in Required are optional,
+	// but must have a matching type when present. Keys not listed are allowed.
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+// matchesSchema checks if v is an object with string keys that satisfies the schema
+func matchesSchema(v, schema interface{}) bool {
+	s, ok := toSchema(schema)
+	if !ok {
+		return false
+	}
+
+	ov := reflect.ValueOf(v)
+	if ov.Kind() != reflect.Map || ov.Type().Key().Kind() != reflect.String {
+		return false
+	}
+	field := func(key string) (interface{}, bool) {
+		fv := ov.MapIndex(reflect.ValueOf(key).Convert(ov.Type().Key()))
+		if !fv.IsValid() {
+			return nil, false
+		}
+		return fv.Interface(), true
+	}
+
+	for _, key := range s.Required {
+		if _, exists := field(key); !exists {
+			return false
+		}
+	}
+	for key, types := range s.Properties {
+		if fv, exists := field(key); exists && !typeIs(fv, types) {
+			return false
+		}
+	}
+	return true
+}
+
+// jsonEqual checks if a and b are equal as JSON values: objects are equal
+// when they have the same keys with equal values, regardless of order, arrays
+// when their elements are equal in order, and numbers when they have the same
+// value, whatever their Go types. Unlike ==, no coercion is applied between
+// strings, numbers and booleans. json.RawMessage values are decoded first,
+// and structs are compared through their JSON encoding.
+func jsonEqual(a, b interface{}) bool {
+	a, okA := toJSONValue(a)
+	b, okB := toJSONValue(b)
+	if !okA || !okB {
+		return false
+	}
+
+	switch av := a.(type) {
+	case nil:
+		return b == nil
+	case bool:
+		bv, ok := b.(bool)
+		return ok && av == bv
+	case string:
+		bv, ok := b.(string)
+		return ok && av == bv
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for key, value := range av {
+			other, exists := bv[key]
+			if !exists || !jsonEqual(value, other) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !jsonEqual(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		// Numbers of any Go type
+		if _, ok := b.(string); ok {
+			return false
+		}
+		c, ok := compareNumbers(a, b)
+		return ok && c == 0
+	}
+}
+
+// toJSONValue converts v to nil, a bool, a string, a number, a
+// map[string]interface{} or a []interface{}, one level deep
+func toJSONValue(v interface{}) (interface{}, bool) {
+	switch val := v.(type) {
+	case json.RawMessage:
+		var decoded interface{}
+		if err := json.Unmarshal(val, &decoded); err != nil {
+			return nil, false
+		}
+		return decoded, true
+	case []byte:
+		return string(val), true
+	}
+
+	rv := reflect.ValueOf(deref(v))
+	switch rv.Kind() {
+	case reflect.Invalid:
+		return nil, true
+	case reflect.Bool:
+		return rv.Bool(), true
+	case reflect.String:
+		return rv.String(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return rv.Interface(), true
+	case reflect.Map:
+		if rv.IsNil() {
+			return nil, true
+		}
+		m := make(map[string]interface{}, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			m[toString(iter.Key().Interface())] = iter.Value().Interface()
+		}
+		return m, true
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return nil, true
+		}
+		s := make([]interface{}, rv.Len())
+		for i := range s {
+			s[i] = rv.Index(i).Interface()
+		}
+		return s, true
+	default:
+		// Structs and other values are compared through their JSON encoding
+		data, err := json.Marshal(rv.Interface())
+		if err != nil {
+			return nil, false
+		}
+		var decoded interface{}
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			return nil, false
+		}
+		return decoded, true
+	}
+}
+
+// toSchema converts a Schema, *Schema or decoded JSON object to a Schema
+func toSchema(v interface{}) (Schema, bool) {
+	switch val := v.(type) {
+	case Schema:
+		return val, true
+	case *Schema:
+		if val != nil {
+			return *val, true
+		}
+	case map[string]interface{}:
+		var s Schema
+		if required, ok := val["required"]; ok {
+			rv := reflect.ValueOf(required)
+			if rv.Kind() != reflect.Slice {
+				return Schema{}, false
+			}
+			for i := 0; i < rv.Len(); i++ {
+				s.Required = append(s.Required, toString(rv.Index(i).Interface()))
+			}
+		}
+		if properties, ok := val["properties"]; ok {
+			props, ok := properties.(map[string]interface{})
+			if !ok {
+				return Schema{}, false
+			}
+			s.Properties = props
+		}
+		return s, true
+	}
+	return Schema{}, false
+}
+
+// ipInCIDR checks if v is an IPv4 or IPv6 address string within the CIDR
+// block cidrs, or within any of them when cidrs is a slice. IPv4-mapped IPv6
+// addresses such as "::ffff:10.0.0.1" match IPv4 blocks. Unparseable
+// addresses never match, and unparseable blocks are skipped.
+func ipInCIDR(v, cidrs interface{}) bool {
+	addr, err := netip.ParseAddr(strings.TrimSpace(toString(v)))
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+
+	contains := func(cidr interface{}) bool {
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(toString(cidr)))
+		return err == nil && prefix.Contains(addr)
+	}
+
+	cv := reflect.ValueOf(cidrs)
+	if cv.Kind() != reflect.Slice && cv.Kind() != reflect.Array {
+		return cidrs != nil && contains(cidrs)
+	}
+	for i := 0; i < cv.Len(); i++ {
+		if contains(cv.Index(i).Interface()) {
+			return true
+		}
+	}
+	return false
+}
+
+// submatch evaluates the condition tree stored in the field v against the
+// same data, letting records carry part of the rule. The tree may be a
+// Conditions, a decoded JSON object or raw JSON text. Each nested rule counts
+// as a group towards MaxDepth, and a rule that reaches its own key again fails
+// with ErrSubmatchCycle. Missing fields and invalid rules never match.
+func (ev *evaluation) submatch(key string, v interface{}, exists bool) (bool, error) {
+	if !exists || v == nil {
+		return false, nil
+	}
+	cond, err := toConditions(v)
+	if err != nil {
+		return false, fmt.Errorf("operator %s: %w", OperatorSubmatch, err)
+	}
+
+	if ev.submatchKeys[key] {
+		return false, fmt.Errorf("operator %s: %w: %q", OperatorSubmatch, ErrSubmatchCycle, key)
+	}
+	if err := ev.enterGroup(); err != nil {
+		return false, err
+	}
+	defer ev.leaveGroup()
+	if ev.submatchKeys == nil {
+		ev.submatchKeys = make(map[string]bool)
+	}
+	ev.submatchKeys[key] = true
+	defer delete(ev.submatchKeys, key)
+
+	return ev.evalCondition(cond)
+}
+
+// toConditions converts a Conditions, decoded JSON object or raw JSON text to
+// a Conditions
+func toConditions(v interface{}) (Conditions, error) {
+	var data []byte
+	switch val := v.(type) {
+	case Conditions:
+		return val, nil
+	case string:
+		data = []byte(val)
+	case []byte:
+		data = val
+	case json.RawMessage:
+		data = val
+	case map[string]interface{}:
+		var err error
+		if data, err = json.Marshal(val); err != nil {
+			return Conditions{}, err
+		}
+	default:
+		return Conditions{}, fmt.Errorf("%w: %T", ErrUnsupportedConditionType, v)
+	}
+
+	var cond Conditions
+	if err := json.Unmarshal(data, &cond); err != nil {
+		return Conditions{}, err
+	}
+	return cond, nil
+}
+
+// OperatorValue is one alternative of the "any_op" operator: an operator and
+// the expected value it is applied with. In JSON it is written as
+// {"operator": "startswith", "value": "pending_"}.
+type OperatorValue struct {
+	Operator Operator    `json:"operator"`
+	Value    interface{} `json:"value,omitempty"`
+}
+
+// anyOp checks if the field matches any of the alternatives, given as a slice
+// of OperatorValue or of decoded JSON objects. Alternatives are tried in order
+// and an error from one of them stops the evaluation. A malformed list never
+// matches.
+func (ev *evaluation) anyOp(v interface{}, exists bool, alternatives interface{}) (bool, error) {
+	av := reflect.ValueOf(alternatives)
+	if av.Kind() != reflect.Slice && av.Kind() != reflect.Array {
+		return false, nil
+	}
+	for i := 0; i < av.Len(); i++ {
+		alt, ok := toOperatorValue(av.Index(i).Interface())
+		if !ok {
+			return false, nil
+		}
+		result, err := ev.evalOperator(alt.Operator, v, exists, alt.Value)
+		if err != nil {
+			return false, err
+		}
+		if result {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// quantify checks if some (for "any") or every (for "all") element of the
+// collection v matches the {operator, value} pair in spec, given as an
+// OperatorValue or a decoded JSON object. An empty collection never matches
+// "any" and always matches "all". Values that aren't slices or arrays, and
+// malformed specs, never match; use a "*" key segment to quantify over the
+// values of a map.
+func (ev *evaluation) quantify(v interface{}, op Operator, spec interface{}) (bool, error) {
+	pair, ok := toOperatorValue(spec)
+	if !ok {
+		return false, nil
+	}
+	switch v.(type) {
+	case []byte, json.RawMessage:
+		// Byte slices hold text
+		return false, nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return false, nil
+	}
+
+	want := op == OperatorAny
+	for i := 0; i < rv.Len(); i++ {
+		result, err := ev.evalOperator(pair.Operator, deref(rv.Index(i).Interface()), true, pair.Value)
+		if err != nil {
+			return false, err
+		}
+		if result == want {
+			return want, nil
+		}
+	}
+	return !want, nil
+}
+
+// regexExtract checks if a capture group of a regular expression matching the
+// string v satisfies an operator, given as [pattern, group, operator, value],
+// so ["^[A-Z]+-([0-9]+)$", 1, ">=", 100] reads the number of a SKU such as
+// "ABC-120". group is the index of the group, 0 being the whole match, or the
+// name of a named group. The capture is passed to the operator as a string,
+// which operators such as ">=" read as a number when it is numeric. A string
+// that doesn't match, a group that didn't take part in the match, an invalid
+// pattern and a malformed spec never match.
+func (ev *evaluation) regexExtract(v, spec interface{}) (bool, error) {
+	sv := reflect.ValueOf(spec)
+	if (sv.Kind() != reflect.Slice && sv.Kind() != reflect.Array) || sv.Len() != 4 || v == nil {
+		return false, nil
+	}
+	pattern, ok := sv.Index(0).Interface().(string)
+	if !ok {
+		return false, nil
+	}
+	re, err := cachedRegexp(pattern)
+	if err != nil {
+		return false, nil
+	}
+
+	group := -1
+	switch g := sv.Index(1).Interface().(type) {
+	case string:
+		group = re.SubexpIndex(g)
+	default:
+		if n, ok := toNumber(g); ok && n == math.Trunc(n) && n >= 0 && n <= float64(re.NumSubexp()) {
+			group = int(n)
+		}
+	}
+	if group < 0 {
+		return false, nil
+	}
+	var op Operator
+	switch name := sv.Index(2).Interface().(type) {
+	case Operator:
+		op = name
+	case string:
+		op = Operator(name)
+	default:
+		return false, nil
+	}
+
+	str := toString(v)
+	match := re.FindStringSubmatchIndex(str)
+	if match == nil || match[2*group] < 0 {
+		return false, nil
+	}
+	return ev.evalOperator(op, str[match[2*group]:match[2*group+1]], true, sv.Index(3).Interface())
+}
+
+// Category is one entry of the ordered list of the "category_is" operator: a
+// regular expression and the label of the values it matches. In JSON it is
+// written as {"pattern": "^[0-9]{3}-", "label": "internal"}.
+type Category struct {
+	Pattern string `json:"pattern"`
+	Label   string `json:"label"`
+}
+
+// categoryIs classifies the string v by the first of an ordered list of
+// categories whose pattern matches it and checks the category's label, given
+// as [categories, label]. The categories are a slice of Category or of
+// decoded JSON objects. A value that no pattern matches has no category, so
+// it never matches; neither does a malformed spec, nor an invalid pattern
+// reached before the first match.
+func (ev *evaluation) categoryIs(v, spec interface{}) bool {
+	sv := reflect.ValueOf(spec)
+	if (sv.Kind() != reflect.Slice && sv.Kind() != reflect.Array) || sv.Len() != 2 || v == nil {
+		return false
+	}
+	categories := reflect.ValueOf(sv.Index(0).Interface())
+	if categories.Kind() != reflect.Slice && categories.Kind() != reflect.Array {
+		return false
+	}
+
+	str := toString(v)
+	for i := 0; i < categories.Len(); i++ {
+		category, ok := toCategory(categories.Index(i).Interface())
+		if !ok {
+			return false
+		}
+		re, err := cachedRegexp(category.Pattern)
+		if err != nil {
+			return false
+		}
+		if re.MatchString(str) {
+			return ev.equals(category.Label, sv.Index(1).Interface())
+		}
+	}
+	return false
+}
+
+// toCategory converts a Category or decoded JSON object to a Category
+func toCategory(v interface{}) (Category, bool) {
+	switch val := v.(type) {
+	case Category:
+		return val, true
+	case map[string]interface{}:
+		pattern, ok := val["pattern"].(string)
+		if !ok {
+			return Category{}, false
+		}
+		label, ok := val["label"].(string)
+		return Category{Pattern: pattern, Label: label}, ok
+	}
+	return Category{}, false
+}
+
+// maxCachedRegexps bounds the number of compiled patterns kept by
+// cachedRegexp, so rules built from arbitrary input can't grow it forever
+const maxCachedRegexps = 1000
+
+// Compiled regular expressions, by pattern
+var (
+	regexpCache      = make(map[string]*regexp.Regexp)
+	regexpCacheMutex sync.RWMutex
+)
+
+// cachedRegexp compiles pattern, reusing the result of earlier calls
+func cachedRegexp(pattern string) (*regexp.Regexp, error) {
+	regexpCacheMutex.RLock()
+	re, ok := regexpCache[pattern]
+	regexpCacheMutex.RUnlock()
+	if ok {
+		return re, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	regexpCacheMutex.Lock()
+	defer regexpCacheMutex.Unlock()
+	if len(regexpCache) >= maxCachedRegexps {
+		regexpCache = make(map[string]*regexp.Regexp)
+	}
+	regexpCache[pattern] = re
+	return re, nil
+}
+
+// toOperatorValue converts an OperatorValue or decoded JSON object to an
+// OperatorValue with an operator set
+func toOperatorValue(v interface{}) (OperatorValue, bool) {
+	switch val := v.(type) {
+	case OperatorValue:
+		return val, val.Operator != ""
+	case map[string]interface{}:
+		op, ok := val["operator"].(string)
+		return OperatorValue{Operator: Operator(op), Value: val["value"]}, ok && op != ""
+	}
+	return OperatorValue{}, false
+}
@@ -0,0 +1,1137 @@
+package jsonvaluate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTypeIsOperator(t *testing.T) {
+	data := map[string]interface{}{
+		"int":       25,
+		"float":     88.5,
+		"uint8":     uint8(1),
+		"numStr":    "25",
+		"str":       "hello",
+		"bool":      true,
+		"slice":     []string{"a"},
+		"array":     [2]int{1, 2},
+		"map":       map[string]interface{}{"a": 1},
+		"struct":    struct{ A int }{1},
+		"time":      time.Now(),
+		"dateStr":   "2024-07-01T12:00:00Z",
+		"nil":       nil,
+		"fn":        func() {},
+		"emptyList": []interface{}{},
+	}
+
+	tests := []struct {
+		key    string
+		value  interface{}
+		expect bool
+	}{
+		{"int", "number", true},
+		{"float", "number", true},
+		{"uint8", "number", true},
+		{"numStr", "number", false},
+		{"numStr", "string", true},
+		{"str", "string", true},
+		{"str", "number", false},
+		{"bool", "bool", true},
+		{"bool", "string", false},
+		{"slice", "array", true},
+		{"array", "array", true},
+		{"emptyList", "array", true},
+		{"map", "object", true},
+		{"struct", "object", true},
+		{"map", "array", false},
+		{"time", "time", true},
+		{"time", "object", false},
+		{"dateStr", "time", false},
+		{"nil", "null", true},
+		{"nil", "string", false},
+		{"fn", "object", false},
+		{"int", []interface{}{"string", "number"}, true},
+		{"bool", []string{"string", "number"}, false},
+		{"int", "integer", false},
+		{"int", 5, false},
+		{"missing", "null", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			result := evalSingleCondition(tt.key, OperatorTypeIs, tt.value, data)
+			if result != tt.expect {
+				t.Errorf("typeis(%s, %v) = %v, want %v", tt.key, tt.value, result, tt.expect)
+			}
+		})
+	}
+}
+
+func TestRankOperators(t *testing.T) {
+	tiers := []interface{}{"bronze", "silver", "gold"}
+	data := map[string]interface{}{
+		"bronze":   "bronze",
+		"silver":   "silver",
+		"gold":     "gold",
+		"platinum": "platinum",
+		"level":    2,
+	}
+
+	tests := []struct {
+		key    string
+		op     Operator
+		value  interface{}
+		expect bool
+	}{
+		{"gold", OperatorRankGte, []interface{}{tiers, "silver"}, true},
+		{"silver", OperatorRankGte, []interface{}{tiers, "silver"}, true},
+		{"bronze", OperatorRankGte, []interface{}{tiers, "silver"}, false},
+		{"gold", OperatorRankGt, []interface{}{tiers, "silver"}, true},
+		{"silver", OperatorRankGt, []interface{}{tiers, "silver"}, false},
+		{"bronze", OperatorRankLt, []interface{}{tiers, "silver"}, true},
+		{"silver", OperatorRankLt, []interface{}{tiers, "silver"}, false},
+		{"silver", OperatorRankLte, []interface{}{tiers, "silver"}, true},
+		{"gold", OperatorRankLte, []interface{}{tiers, "silver"}, false},
+		// "gold" > "silver" is false as strings, but true by rank
+		{"gold", OperatorRankGt, []interface{}{[]string{"bronze", "silver", "gold"}, "silver"}, true},
+		{"level", OperatorRankGt, []interface{}{[]int{1, 2, 3}, 1}, true},
+		{"gold", OperatorRankGt, [2]interface{}{[3]string{"bronze", "silver", "gold"}, "silver"}, true},
+		{"bronze", OperatorRankGte, [2]interface{}{[3]string{"bronze", "silver", "gold"}, "silver"}, false},
+		// Unknown field or threshold values are false
+		{"platinum", OperatorRankGte, []interface{}{tiers, "silver"}, false},
+		{"platinum", OperatorRankLt, []interface{}{tiers, "silver"}, false},
+		{"gold", OperatorRankGte, []interface{}{tiers, "diamond"}, false},
+		{"missing", OperatorRankLt, []interface{}{tiers, "silver"}, false},
+		// Malformed specs are false
+		{"gold", OperatorRankGte, tiers, false},
+		{"gold", OperatorRankGte, []interface{}{"bronze,silver,gold", "silver"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.op)+" "+tt.key, func(t *testing.T) {
+			if result := evalSingleCondition(tt.key, tt.op, tt.value, data); result != tt.expect {
+				t.Errorf("evalSingleCondition(%s, %s, %v) = %v, want %v", tt.key, tt.op, tt.value, result, tt.expect)
+			}
+		})
+	}
+}
+
+func TestMatchesSchemaOperator(t *testing.T) {
+	schema := Schema{
+		Required: []string{"street", "zip"},
+		Properties: map[string]interface{}{
+			"street": "string",
+			"zip":    []interface{}{"string", "number"},
+			"unit":   "number",
+		},
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal([]byte(`{"required": ["street"], "properties": {"street": "string"}}`), &decoded); err != nil {
+		t.Fatalf("unmarshal schema: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		data   interface{}
+		schema interface{}
+		expect bool
+	}{
+		{"all keys valid", map[string]interface{}{"street": "Main St", "zip": "12345"}, schema, true},
+		{"alternative type", map[string]interface{}{"street": "Main St", "zip": 12345}, schema, true},
+		{"optional key valid", map[string]interface{}{"street": "Main St", "zip": "12345", "unit": 4}, schema, true},
+		{"extra keys allowed", map[string]interface{}{"street": "Main St", "zip": "12345", "city": "X"}, schema, true},
+		{"required key missing", map[string]interface{}{"street": "Main St"}, schema, false},
+		{"wrong type", map[string]interface{}{"street": 42, "zip": "12345"}, schema, false},
+		{"optional key wrong type", map[string]interface{}{"street": "Main St", "zip": "12345", "unit": "4B"}, schema, false},
+		{"pointer schema", map[string]interface{}{"street": "Main St", "zip": "12345"}, &schema, true},
+		{"decoded schema", map[string]interface{}{"street": "Main St"}, decoded, true},
+		{"decoded schema wrong type", map[string]interface{}{"street": true}, decoded, false},
+		{"typed map", map[string]string{"street": "Main St", "zip": "12345"}, schema, true},
+		{"not an object", "Main St", schema, false},
+		{"invalid schema", map[string]interface{}{"street": "Main St"}, "street", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := map[string]interface{}{"address": tt.data}
+			if result := evalSingleCondition("address", OperatorMatchesSchema, tt.schema, data); result != tt.expect {
+				t.Errorf("matches_schema(%v, %v) = %v, want %v", tt.data, tt.schema, result, tt.expect)
+			}
+		})
+	}
+}
+
+func TestJSONEqOperator(t *testing.T) {
+	type address struct {
+		Street string `json:"street"`
+		Zip    int    `json:"zip"`
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(`{"zip": 12345, "street": "Main St", "tags": [1, 2.5]}`), &decoded); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		data     interface{}
+		expected interface{}
+		expect   bool
+	}{
+		{"reordered keys", decoded, json.RawMessage(`{"tags": [1, 2.5], "street": "Main St", "zip": 12345}`), true},
+		{"int and float leaves", map[string]interface{}{"zip": 12345, "tags": []int{1, 2}}, map[string]interface{}{"tags": []float64{1.0, 2.0}, "zip": 12345.0}, true},
+		{"typed map", map[string]int{"a": 1}, map[string]interface{}{"a": int64(1)}, true},
+		{"struct", address{Street: "Main St", Zip: 12345}, map[string]interface{}{"zip": 12345, "street": "Main St"}, true},
+		{"nested", map[string]interface{}{"a": map[string]interface{}{"b": []interface{}{nil, true}}}, map[string]interface{}{"a": map[string]interface{}{"b": []interface{}{nil, true}}}, true},
+		{"scalars", 1, 1.0, true},
+		{"nulls", nil, nil, true},
+		{"array order matters", []int{1, 2}, []int{2, 1}, false},
+		{"different values", map[string]interface{}{"a": 1}, map[string]interface{}{"a": 2}, false},
+		{"extra key", map[string]interface{}{"a": 1}, map[string]interface{}{"a": 1, "b": 2}, false},
+		{"missing key vs null", map[string]interface{}{"a": 1, "b": nil}, map[string]interface{}{"a": 1, "c": nil}, false},
+		{"no string coercion", map[string]interface{}{"zip": "12345"}, map[string]interface{}{"zip": 12345}, false},
+		{"no bool coercion", []interface{}{true}, []interface{}{"true"}, false},
+		{"null vs empty object", nil, map[string]interface{}{}, false},
+		{"invalid raw JSON", map[string]interface{}{}, json.RawMessage(`{`), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := map[string]interface{}{"doc": tt.data}
+			if result := evalSingleCondition("doc", OperatorJSONEq, tt.expected, data); result != tt.expect {
+				t.Errorf("json_eq(%v, %v) = %v, want %v", tt.data, tt.expected, result, tt.expect)
+			}
+		})
+	}
+}
+
+func TestWithinPctOperator(t *testing.T) {
+	tests := []struct {
+		value  interface{}
+		spec   interface{}
+		expect bool
+	}{
+		{100, []interface{}{100, 5}, true},
+		{103.2, []interface{}{100, 5}, true},
+		// The boundary is inclusive on both sides
+		{105, []interface{}{100, 5}, true},
+		{95, []interface{}{100, 5}, true},
+		{105.01, []interface{}{100, 5}, false},
+		{94.99, []interface{}{100, 5}, false},
+		{"98", []interface{}{"100", "5"}, true},
+		{int64(1020), []float64{1000, 2.5}, true},
+		// Negative targets use the target's magnitude
+		{-95, []interface{}{-100, 5}, true},
+		{-106, []interface{}{-100, 5}, false},
+		// A zero target only matches exactly
+		{0, []interface{}{0, 5}, true},
+		{0.0001, []interface{}{0, 5}, false},
+		{-0.0001, []interface{}{0, 50}, false},
+		{100, []interface{}{100, 0}, true},
+		{100.5, []interface{}{100, 0}, false},
+		// Non-numeric values and malformed specs never match
+		{"abc", []interface{}{100, 5}, false},
+		{nil, []interface{}{100, 5}, false},
+		{100, []interface{}{"abc", 5}, false},
+		{100, []interface{}{100, -5}, false},
+		{100, []interface{}{100}, false},
+		{100, 100, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%v within_pct %v", tt.value, tt.spec), func(t *testing.T) {
+			data := map[string]interface{}{"amount": tt.value}
+			if result := evalSingleCondition("amount", OperatorWithinPct, tt.spec, data); result != tt.expect {
+				t.Errorf("within_pct(%v, %v) = %v, want %v", tt.value, tt.spec, result, tt.expect)
+			}
+		})
+	}
+}
+
+func TestPctBetweenOperator(t *testing.T) {
+	claim := []interface{}{"sum_insured", 10, 90}
+	tests := []struct {
+		name   string
+		amount interface{}
+		data   map[string]interface{}
+		spec   interface{}
+		expect bool
+	}{
+		{"inside", 100000, map[string]interface{}{"sum_insured": 250000}, claim, true},
+		{"low bound inclusive", 25000, map[string]interface{}{"sum_insured": 250000}, claim, true},
+		{"high bound inclusive", 225000, map[string]interface{}{"sum_insured": 250000}, claim, true},
+		{"below", 24999, map[string]interface{}{"sum_insured": 250000}, claim, false},
+		{"above", 225001, map[string]interface{}{"sum_insured": 250000}, claim, false},
+		{"numeric strings", "100000", map[string]interface{}{"sum_insured": "250000"}, []interface{}{"sum_insured", "10", "90"}, true},
+		{"nested basis", 50000, map[string]interface{}{"policy": map[string]interface{}{"sum_insured": 100000}}, []interface{}{"policy.sum_insured", 10, 90}, true},
+		{"field reference key", 50000, map[string]interface{}{"sum_insured": 100000}, []interface{}{Ref("sum_insured"), 10, 90}, true},
+		{"bounds put in order", 50000, map[string]interface{}{"sum_insured": 100000}, []interface{}{"sum_insured", 90, 10}, true},
+		{"negative basis", -50000, map[string]interface{}{"sum_insured": -100000}, claim, true},
+		{"open high bound", 300000, map[string]interface{}{"sum_insured": 250000}, []interface{}{"sum_insured", 10, nil}, true},
+		{"open low bound", 0, map[string]interface{}{"sum_insured": 250000}, []interface{}{"sum_insured", nil, 90}, true},
+		{"missing basis", 100000, map[string]interface{}{}, claim, false},
+		{"non-numeric basis", 100000, map[string]interface{}{"sum_insured": "n/a"}, claim, false},
+		{"non-numeric amount", "n/a", map[string]interface{}{"sum_insured": 250000}, claim, false},
+		{"non-numeric percentage", 100000, map[string]interface{}{"sum_insured": 250000}, []interface{}{"sum_insured", "ten", 90}, false},
+		{"non-string key", 100000, map[string]interface{}{"sum_insured": 250000}, []interface{}{1, 10, 90}, false},
+		{"wrong length", 100000, map[string]interface{}{"sum_insured": 250000}, []interface{}{"sum_insured", 10}, false},
+		{"not a slice", 100000, map[string]interface{}{"sum_insured": 250000}, "sum_insured", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := map[string]interface{}{"amount": tt.amount}
+			for k, v := range tt.data {
+				data[k] = v
+			}
+			if result := evalSingleCondition("amount", OperatorPctBetween, tt.spec, data); result != tt.expect {
+				t.Errorf("pct_between(%v, %v) = %v, want %v", tt.amount, tt.spec, result, tt.expect)
+			}
+		})
+	}
+}
+
+func TestBitFlagOperators(t *testing.T) {
+	const (
+		read   = 0x01
+		write  = 0x02
+		remove = 0x04
+		admin  = 0x80
+	)
+	tests := []struct {
+		name   string
+		value  interface{}
+		op     Operator
+		mask   interface{}
+		expect bool
+	}{
+		{"single flag set", read | remove, OperatorBitSet, remove, true},
+		{"single flag not set", read | remove, OperatorBitSet, write, false},
+		{"combined flags set", read | write | remove, OperatorBitSet, read | write, true},
+		{"combined flags partly set", read | remove, OperatorBitSet, read | write, false},
+		{"clear flag", read | remove, OperatorBitClear, write, true},
+		{"combined flags clear", read, OperatorBitClear, write | admin, true},
+		{"combined flags partly clear", read | admin, OperatorBitClear, write | admin, false},
+		{"empty mask set", 0, OperatorBitSet, 0, true},
+		{"empty mask clear", 0xff, OperatorBitClear, 0, true},
+		{"float from JSON", float64(read | remove), OperatorBitSet, float64(remove), true},
+		{"numeric strings", "5", OperatorBitSet, "4", true},
+		{"json.Number", json.Number("133"), OperatorBitSet, admin | remove, true},
+		{"uint64 high bit", uint64(1 << 63), OperatorBitSet, uint64(1 << 63), true},
+		{"int64 beyond float precision", int64(1<<62 | 1), OperatorBitSet, 1, true},
+		{"fractional value", 5.5, OperatorBitSet, 4, false},
+		{"fractional mask", 5, OperatorBitSet, 4.5, false},
+		{"negative value", -1, OperatorBitSet, 1, false},
+		{"negative mask", 5, OperatorBitClear, -2, false},
+		{"not a number", "rw", OperatorBitSet, 1, false},
+		{"nil mask", 5, OperatorBitClear, nil, false},
+		{"NaN", math.NaN(), OperatorBitClear, 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := map[string]interface{}{"perms": tt.value}
+			if result := evalSingleCondition("perms", tt.op, tt.mask, data); result != tt.expect {
+				t.Errorf("%s(%v, %v) = %v, want %v", tt.op, tt.value, tt.mask, result, tt.expect)
+			}
+		})
+	}
+}
+
+func TestIsUniqueOperator(t *testing.T) {
+	one, uno := 1, 1
+	tests := []struct {
+		name   string
+		value  interface{}
+		expect bool
+	}{
+		{"unique strings", []string{"b-1", "b-2", "b-3"}, true},
+		{"duplicate strings", []string{"b-1", "b-2", "b-1"}, false},
+		{"unique numbers", []interface{}{1, 2, 3.5}, true},
+		{"int and float duplicates", []interface{}{1, 2, 1.0}, false},
+		{"int and numeric string duplicates", []interface{}{"7", 8, 7}, false},
+		{"numeric strings duplicates", []interface{}{"1e3", "1000"}, false},
+		{"duration strings duplicates", []interface{}{"1h", "60m"}, false},
+		{"mixed types unique", []interface{}{1, "one", nil, true, []int{1}}, true},
+		{"nil duplicates", []interface{}{nil, 1, nil}, false},
+		{"bool and truthy string duplicates", []interface{}{true, "yes"}, false},
+		{"bool and number unique", []interface{}{true, 1}, true},
+		{"pointer duplicates", []*int{&one, &uno}, false},
+		{"array", [3]int{1, 2, 3}, true},
+		{"typed ints", []int64{9007199254740993, 9007199254740992}, true},
+		{"negative zero", []float64{0, math.Copysign(0, -1)}, false},
+		{"empty", []interface{}{}, true},
+		{"not a collection", "abc", false},
+		{"bytes", []byte("aa"), false},
+		{"nil", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := map[string]interface{}{"ids": tt.value}
+			if result := evalSingleCondition("ids", OperatorIsUnique, nil, data); result != tt.expect {
+				t.Errorf("isunique(%v) = %v, want %v", tt.value, result, tt.expect)
+			}
+		})
+	}
+
+	if evalSingleCondition("missing", OperatorIsUnique, nil, map[string]interface{}{}) {
+		t.Error("expected missing field not to be unique")
+	}
+
+	// FloatTolerance applies, comparing pairwise
+	e := NewEvaluator()
+	e.FloatTolerance = 0.01
+	data := map[string]interface{}{"amounts": []float64{85.5, 85.499999, 90}}
+	if e.EvaluateCondition(NewSimpleCondition("amounts", OperatorIsUnique, nil), data) {
+		t.Error("expected amounts within tolerance to be duplicates")
+	}
+}
+
+func BenchmarkIsUnique(b *testing.B) {
+	ids := make([]interface{}, 10000)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("id-%d", i)
+	}
+	data := map[string]interface{}{"ids": ids}
+	cond := NewSimpleCondition("ids", OperatorIsUnique, nil)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !EvaluateCondition(cond, data) {
+			b.Fatal("expected ids to be unique")
+		}
+	}
+}
+
+func TestIsIntegerOperator(t *testing.T) {
+	tests := []struct {
+		value  interface{}
+		expect bool
+	}{
+		{3, true},
+		{int64(-42), true},
+		{uint8(0), true},
+		{3.0, true},
+		{float32(7), true},
+		{3.5, false},
+		{-0.25, false},
+		{"10", true},
+		{"-7", true},
+		{"1e3", true},
+		{"10.0", true},
+		{"10.5", false},
+		{namedScore(12), true},
+		{json.Number("12"), true},
+		{json.Number("12.5"), false},
+		// Non-numeric and non-finite values are not integers
+		{"abc", false},
+		{"", false},
+		{true, false},
+		{nil, false},
+		{[]interface{}{1}, false},
+		{math.Inf(1), false},
+		{math.NaN(), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%T %v", tt.value, tt.value), func(t *testing.T) {
+			data := map[string]interface{}{"quantity": tt.value}
+			if result := evalSingleCondition("quantity", OperatorIsInteger, nil, data); result != tt.expect {
+				t.Errorf("isinteger(%v) = %v, want %v", tt.value, result, tt.expect)
+			}
+		})
+	}
+
+	if evalSingleCondition("missing", OperatorIsInteger, nil, map[string]interface{}{}) {
+		t.Error("expected a missing field not to be an integer")
+	}
+}
+
+func TestStepOperator(t *testing.T) {
+	tests := []struct {
+		value  interface{}
+		spec   interface{}
+		expect bool
+	}{
+		{0, []interface{}{0, 1000, 50}, true},
+		{150, []interface{}{0, 1000, 50}, true},
+		{1000, []interface{}{0, 1000, 50}, true},
+		{"250", []interface{}{0, 1000, 50}, true},
+		{175, []interface{}{0, 1000, 50}, false},
+		{1050, []interface{}{0, 1000, 50}, false},
+		{-50, []interface{}{0, 1000, 50}, false},
+		// Steps are counted from min
+		{15, []interface{}{5, 100, 10}, true},
+		{20, []interface{}{5, 100, 10}, false},
+		{-5, []int{-25, 25, 10}, true},
+		// Fractional steps absorb floating-point noise
+		{0.3, []interface{}{0, 1, 0.1}, true},
+		{0.35, []interface{}{0, 1, 0.1}, false},
+		{19.99, []float64{0.99, 100, 0.25}, true},
+		// Non-numeric values, malformed specs and non-positive steps never match
+		{"abc", []interface{}{0, 1000, 50}, false},
+		{nil, []interface{}{0, 1000, 50}, false},
+		{100, []interface{}{0, 1000, 0}, false},
+		{100, []interface{}{0, 1000, -50}, false},
+		{100, []interface{}{0, 1000}, false},
+		{100, []interface{}{0, "x", 50}, false},
+		{100, 50, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%v step %v", tt.value, tt.spec), func(t *testing.T) {
+			data := map[string]interface{}{"amount": tt.value}
+			if result := evalSingleCondition("amount", OperatorStep, tt.spec, data); result != tt.expect {
+				t.Errorf("step(%v, %v) = %v, want %v", tt.value, tt.spec, result, tt.expect)
+			}
+		})
+	}
+}
+
+func TestQuantifierOperators(t *testing.T) {
+	three := 3
+	data := map[string]interface{}{
+		"scores":  []interface{}{90, 85, 70},
+		"typed":   []int{5, 10},
+		"names":   [2]string{"alice", "bob"},
+		"ptrs":    []*int{&three, nil},
+		"empty":   []interface{}{},
+		"scalar":  90,
+		"bytes":   []byte("abc"),
+		"nothing": nil,
+	}
+
+	tests := []struct {
+		key    string
+		op     Operator
+		spec   interface{}
+		expect bool
+	}{
+		{"scores", OperatorAny, OperatorValue{Operator: OperatorGte, Value: 90}, true},
+		{"scores", OperatorAny, OperatorValue{Operator: OperatorGt, Value: 90}, false},
+		{"scores", OperatorAll, OperatorValue{Operator: OperatorGte, Value: 70}, true},
+		{"scores", OperatorAll, OperatorValue{Operator: OperatorGt, Value: 70}, false},
+		{"typed", OperatorAll, map[string]interface{}{"operator": "between", "value": []interface{}{1, 10}}, true},
+		{"names", OperatorAny, map[string]interface{}{"operator": "startswith", "value": "b"}, true},
+		{"ptrs", OperatorAny, OperatorValue{Operator: OperatorEq, Value: 3}, true},
+		{"ptrs", OperatorAny, OperatorValue{Operator: OperatorIsnull}, true},
+		// An empty collection has no matching element, and no failing one
+		{"empty", OperatorAny, OperatorValue{Operator: OperatorIsnotnull}, false},
+		{"empty", OperatorAll, OperatorValue{Operator: OperatorIsnull}, true},
+		// Non-collections, missing fields and malformed specs never match
+		{"scalar", OperatorAny, OperatorValue{Operator: OperatorEq, Value: 90}, false},
+		{"bytes", OperatorAll, OperatorValue{Operator: OperatorIsnotnull}, false},
+		{"nothing", OperatorAll, OperatorValue{Operator: OperatorIsnull}, false},
+		{"missing", OperatorAll, OperatorValue{Operator: OperatorIsnull}, false},
+		{"scores", OperatorAny, 90, false},
+		{"scores", OperatorAny, map[string]interface{}{"value": 90}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %s %v", tt.key, tt.op, tt.spec), func(t *testing.T) {
+			if result := evalSingleCondition(tt.key, tt.op, tt.spec, data); result != tt.expect {
+				t.Errorf("%s(%s, %v) = %v, want %v", tt.op, tt.key, tt.spec, result, tt.expect)
+			}
+		})
+	}
+}
+
+func TestLengthBetweenOperator(t *testing.T) {
+	tests := []struct {
+		value  interface{}
+		bounds interface{}
+		expect bool
+	}{
+		{"alice", []interface{}{3, 20}, true},
+		// Bounds are inclusive
+		{"bob", []interface{}{3, 20}, true},
+		{"al", []interface{}{3, 20}, false},
+		{"abcdefghij", []interface{}{3, 10}, true},
+		{"abcdefghijk", []interface{}{3, 10}, false},
+		{"", []interface{}{0, 5}, true},
+		{"", []interface{}{1, 5}, false},
+		// Characters, not bytes, are counted
+		{"José", []interface{}{4, 4}, true},
+		{"สวัสดี", []interface{}{6, 6}, true},
+		{[]byte("hello"), []int{5, 5}, true},
+		{[]interface{}{1, 2, 3}, []interface{}{1, 3}, true},
+		{[]string{}, []interface{}{1, 3}, false},
+		{[]int{1, 2, 3, 4}, []interface{}{1, 3}, false},
+		{map[string]interface{}{"a": 1}, []interface{}{1, 1}, true},
+		// A nil bound is open
+		{"a long description", []interface{}{10, nil}, true},
+		{"short", []interface{}{nil, 3}, false},
+		{"ok", []interface{}{nil, nil}, true},
+		{"12", []interface{}{"1", "2"}, true},
+		// Values without a length and malformed bounds never match
+		{12345, []interface{}{1, 10}, false},
+		{nil, []interface{}{0, 10}, false},
+		{true, []interface{}{0, 10}, false},
+		{"abc", []interface{}{1}, false},
+		{"abc", []interface{}{"a", 5}, false},
+		{"abc", 3, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%v length_between %v", tt.value, tt.bounds), func(t *testing.T) {
+			data := map[string]interface{}{"field": tt.value}
+			if result := evalSingleCondition("field", OperatorLengthBetween, tt.bounds, data); result != tt.expect {
+				t.Errorf("length_between(%v, %v) = %v, want %v", tt.value, tt.bounds, result, tt.expect)
+			}
+		})
+	}
+}
+
+func TestCountOperator(t *testing.T) {
+	data := map[string]interface{}{
+		"beneficiaries": []interface{}{"alice", "bob"},
+		"dependents":    []string{},
+		"scores":        [3]int{1, 2, 3},
+		"tags":          map[string]interface{}{"a": 1},
+		"name":          "alice",
+		"bytes":         []byte("ab"),
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(`[">=", 1]`), &decoded); err != nil {
+		t.Fatalf("unmarshal value: %v", err)
+	}
+
+	tests := []struct {
+		key    string
+		value  interface{}
+		expect bool
+	}{
+		{"beneficiaries", 2, true},
+		{"beneficiaries", 3, false},
+		{"beneficiaries", 2.0, true},
+		{"beneficiaries", []interface{}{"==", 2}, true},
+		{"beneficiaries", []interface{}{"!=", 2}, false},
+		{"beneficiaries", []interface{}{">", 1}, true},
+		{"beneficiaries", []interface{}{"<=", 1}, false},
+		{"beneficiaries", decoded, true},
+		{"dependents", decoded, false},
+		{"dependents", 0, true},
+		{"dependents", []interface{}{"<", 1}, true},
+		{"scores", []interface{}{">=", 3}, true},
+		{"tags", 1, true},
+		// Non-collection fields never match
+		{"name", 5, false},
+		{"bytes", 2, false},
+		{"missing", 0, false},
+		// Malformed expected values never match
+		{"beneficiaries", "two", false},
+		{"beneficiaries", []interface{}{"~", 2}, false},
+		{"beneficiaries", []interface{}{">="}, false},
+		{"beneficiaries", []interface{}{2, ">="}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %v", tt.key, tt.value), func(t *testing.T) {
+			if result := evalSingleCondition(tt.key, OperatorCount, tt.value, data); result != tt.expect {
+				t.Errorf("count(%s, %v) = %v, want %v", tt.key, tt.value, result, tt.expect)
+			}
+		})
+	}
+}
+
+func TestIPInCIDROperator(t *testing.T) {
+	data := map[string]interface{}{
+		"ipv4":     "10.1.2.3",
+		"ipv6":     "2001:db8::1",
+		"mapped":   "::ffff:192.168.1.10",
+		"public":   "8.8.8.8",
+		"invalid":  "not-an-ip",
+		"withPort": "10.1.2.3:8080",
+		"number":   42,
+	}
+
+	tests := []struct {
+		key    string
+		value  interface{}
+		expect bool
+	}{
+		{"ipv4", "10.0.0.0/8", true},
+		{"ipv4", "10.1.2.0/24", true},
+		{"ipv4", "10.1.3.0/24", false},
+		{"ipv4", "10.1.2.3/32", true},
+		{"ipv6", "2001:db8::/32", true},
+		{"ipv6", "2001:db9::/32", false},
+		{"ipv6", "10.0.0.0/8", false},
+		{"ipv4", "::/0", false},
+		{"mapped", "192.168.0.0/16", true},
+		{"public", []interface{}{"10.0.0.0/8", "192.168.0.0/16"}, false},
+		{"ipv4", []interface{}{"192.168.0.0/16", "10.0.0.0/8"}, true},
+		{"ipv6", []string{"10.0.0.0/8", "2001:db8::/48"}, true},
+		{"ipv4", []interface{}{}, false},
+		// Unparseable addresses and blocks never match
+		{"invalid", "0.0.0.0/0", false},
+		{"withPort", "10.0.0.0/8", false},
+		{"number", "0.0.0.0/0", false},
+		{"missing", "0.0.0.0/0", false},
+		{"ipv4", "10.0.0.0", false},
+		{"ipv4", "garbage", false},
+		{"ipv4", []interface{}{"garbage", "10.0.0.0/8"}, true},
+		{"ipv4", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %v", tt.key, tt.value), func(t *testing.T) {
+			if result := evalSingleCondition(tt.key, OperatorIPInCIDR, tt.value, data); result != tt.expect {
+				t.Errorf("ip_in_cidr(%s, %v) = %v, want %v", tt.key, tt.value, result, tt.expect)
+			}
+		})
+	}
+}
+
+func TestSubmatchOperator(t *testing.T) {
+	// Two-level composition: the rule under "policy" refers to the rule under
+	// "eligibility", both stored in the data
+	data := map[string]interface{}{
+		"age":         25,
+		"country":     "TH",
+		"eligibility": `{"key": "age", "operator": ">=", "value": 18}`,
+		"policy": map[string]interface{}{
+			"logic": "AND",
+			"children": []interface{}{
+				map[string]interface{}{"key": "country", "operator": "in", "value": []interface{}{"TH", "SG"}},
+				map[string]interface{}{"key": "eligibility", "operator": "submatch"},
+			},
+		},
+		"typed":    NewSimpleCondition("country", OperatorEq, "SG"),
+		"raw":      json.RawMessage(`{"key": "country", "operator": "==", "value": "TH"}`),
+		"self":     `{"key": "self", "operator": "submatch"}`,
+		"invalid":  `{"key": `,
+		"number":   42,
+		"loopA":    `{"key": "loopB", "operator": "submatch"}`,
+		"loopB":    `{"key": "loopA", "operator": "submatch"}`,
+		"emptyobj": `{}`,
+	}
+
+	tests := []struct {
+		key    string
+		expect bool
+		err    error
+	}{
+		{"policy", true, nil},
+		{"eligibility", true, nil},
+		{"typed", false, nil},
+		{"raw", true, nil},
+		{"emptyobj", true, nil},
+		{"missing", false, nil},
+		{"self", false, ErrSubmatchCycle},
+		{"loopA", false, ErrSubmatchCycle},
+		{"invalid", false, nil},
+		{"number", false, ErrUnsupportedConditionType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			cond := NewSimpleCondition(tt.key, OperatorSubmatch, nil)
+			if result := EvaluateCondition(cond, data); result != tt.expect {
+				t.Errorf("EvaluateCondition() = %v, want %v", result, tt.expect)
+			}
+			_, err := EvaluateConditionE(cond, data)
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Errorf("EvaluateConditionE() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+
+	// The inner rule sees the same data
+	data["age"] = 16
+	if EvaluateCondition(NewSimpleCondition("policy", OperatorSubmatch, nil), data) {
+		t.Error("Expected nested rule to fail for age 16")
+	}
+
+	// Nested rules count towards MaxDepth
+	e := NewEvaluator()
+	e.MaxDepth = 1
+	if _, err := e.EvaluateConditionE(NewSimpleCondition("policy", OperatorSubmatch, nil), data); !errors.Is(err, ErrMaxDepthExceeded) {
+		t.Errorf("EvaluateConditionE() with MaxDepth 1 error = %v, want ErrMaxDepthExceeded", err)
+	}
+}
+
+func TestAnyOpOperator(t *testing.T) {
+	data := map[string]interface{}{
+		"active":  "A",
+		"pending": "pending_review",
+		"closed":  "closed",
+		"empty":   "",
+	}
+
+	statusCheck := []OperatorValue{
+		{Operator: OperatorIn, Value: []interface{}{"A", "B"}},
+		{Operator: OperatorStartsWith, Value: "pending_"},
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(`[
+		{"operator": "in", "value": ["A", "B"]},
+		{"operator": "startswith", "value": "pending_"}
+	]`), &decoded); err != nil {
+		t.Fatalf("unmarshal alternatives: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		key    string
+		value  interface{}
+		expect bool
+	}{
+		{"first alternative", "active", statusCheck, true},
+		{"second alternative", "pending", statusCheck, true},
+		{"no alternative", "closed", statusCheck, false},
+		{"decoded first alternative", "active", decoded, true},
+		{"decoded second alternative", "pending", decoded, true},
+		{"decoded no alternative", "closed", decoded, false},
+		{"unary alternative on missing field", "missing", []OperatorValue{{Operator: OperatorEq, Value: ""}, {Operator: OperatorIsnull}}, true},
+		{"unary alternative on empty field", "empty", []OperatorValue{{Operator: OperatorIsnull}, {Operator: OperatorIsEmpty}}, true},
+		{"missing field", "missing", statusCheck, false},
+		{"no alternatives", "active", []OperatorValue{}, false},
+		{"not a list", "active", OperatorValue{Operator: OperatorEq, Value: "A"}, false},
+		{"alternative without operator", "active", []interface{}{map[string]interface{}{"value": "A"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := evalSingleCondition(tt.key, OperatorAnyOp, tt.value, data); result != tt.expect {
+				t.Errorf("any_op(%s, %v) = %v, want %v", tt.key, tt.value, result, tt.expect)
+			}
+		})
+	}
+
+	t.Run("custom operator errors", func(t *testing.T) {
+		defer RestoreOperators(SnapshotOperators())
+		failure := errors.New("failed")
+		RegisterCustomOperatorE("fails", func(fieldValue, expectedValue interface{}) (bool, error) {
+			return false, failure
+		})
+		cond := NewSimpleCondition("closed", OperatorAnyOp, []OperatorValue{{Operator: "fails"}, {Operator: OperatorEq, Value: "closed"}})
+		if _, err := EvaluateConditionE(cond, data); !errors.Is(err, failure) {
+			t.Errorf("EvaluateConditionE() error = %v, want %v", err, failure)
+		}
+	})
+}
+
+func TestRegexExtractOperator(t *testing.T) {
+	data := map[string]interface{}{
+		"sku":   "ABC-120",
+		"small": "ABC-7",
+		"phone": "+66-812345678",
+		"code":  "order 1042 shipped",
+		"num":   4321,
+		"null":  nil,
+	}
+	skuNumber := `^[A-Z]+-([0-9]+)$`
+
+	tests := []struct {
+		name   string
+		key    string
+		value  interface{}
+		expect bool
+	}{
+		{"numeric comparison on capture", "sku", []interface{}{skuNumber, 1, ">=", 100}, true},
+		{"numeric comparison fails", "small", []interface{}{skuNumber, 1, ">=", 100}, false},
+		{"numeric not text comparison", "small", []interface{}{skuNumber, 1, "<", 10}, true},
+		{"capture length", "sku", []interface{}{skuNumber, 1, OperatorLengthBetween, []interface{}{3, nil}}, true},
+		{"capture length too short", "small", []interface{}{skuNumber, 1, "length_between", []interface{}{3, nil}}, false},
+		{"whole match", "code", []interface{}{`[0-9]+`, 0, "==", 1042}, true},
+		{"named group", "phone", []interface{}{`^\+(?P<country>[0-9]+)-`, "country", "in", []interface{}{"66", "65"}}, true},
+		{"float group index", "sku", []interface{}{skuNumber, 1.0, "==", "120"}, true},
+		{"number field", "num", []interface{}{`^([0-9]{2})`, 1, "==", 43}, true},
+		{"no match", "code", []interface{}{skuNumber, 1, ">=", 0}, false},
+		{"optional group not matched", "sku", []interface{}{`^([A-Z]+)-(x)?`, 2, "isnull", nil}, false},
+		{"group out of range", "sku", []interface{}{skuNumber, 2, ">=", 0}, false},
+		{"unknown group name", "sku", []interface{}{skuNumber, "number", ">=", 0}, false},
+		{"invalid pattern", "sku", []interface{}{`([0-9]+`, 1, ">=", 0}, false},
+		{"operator not a string", "sku", []interface{}{skuNumber, 1, 5, 0}, false},
+		{"wrong length", "sku", []interface{}{skuNumber, 1, ">="}, false},
+		{"null field", "null", []interface{}{`.*`, 0, "isnull", nil}, false},
+		{"missing field", "missing", []interface{}{`.*`, 0, "isnull", nil}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := evalSingleCondition(tt.key, OperatorRegexExtract, tt.value, data); result != tt.expect {
+				t.Errorf("regex_extract(%s, %v) = %v, want %v", tt.key, tt.value, result, tt.expect)
+			}
+		})
+	}
+
+	// Patterns are compiled once
+	evalSingleCondition("sku", OperatorRegexExtract, []interface{}{skuNumber, 1, ">=", 100}, data)
+	first, _ := cachedRegexp(skuNumber)
+	second, _ := cachedRegexp(skuNumber)
+	if first == nil || first != second {
+		t.Error("expected the compiled pattern to be cached")
+	}
+}
+
+func TestCategoryIsOperator(t *testing.T) {
+	// Overlapping patterns: the first match decides
+	phones := []Category{
+		{Pattern: `^\+66-2`, Label: "bangkok"},
+		{Pattern: `^\+66-`, Label: "thailand"},
+		{Pattern: `^\+`, Label: "international"},
+	}
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(`[
+		{"pattern": "^[0-9]$", "label": "child"},
+		{"pattern": "^1[0-9]$", "label": "teen"},
+		{"pattern": "^[2-9][0-9]$", "label": "adult"}
+	]`), &decoded); err != nil {
+		t.Fatalf("unmarshal categories: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		value  interface{}
+		spec   interface{}
+		expect bool
+	}{
+		{"most specific first", "+66-2123-4567", []interface{}{phones, "bangkok"}, true},
+		{"later categories are not consulted", "+66-2123-4567", []interface{}{phones, "thailand"}, false},
+		{"second category", "+66-81-234-5678", []interface{}{phones, "thailand"}, true},
+		{"fallback category", "+1-555-0100", []interface{}{phones, "international"}, true},
+		{"no category", "02-123-4567", []interface{}{phones, "international"}, false},
+		{"no category, empty label", "02-123-4567", []interface{}{phones, ""}, false},
+		{"decoded categories", 15, []interface{}{decoded, "teen"}, true},
+		{"decoded categories mismatch", 42, []interface{}{decoded, "teen"}, false},
+		{"decoded categories adult", 42, []interface{}{decoded, "adult"}, true},
+		{"order reversed", "+66-2123-4567", []interface{}{[]Category{phones[2], phones[0]}, "international"}, true},
+		{"invalid pattern before match", "+66-2", []interface{}{[]Category{{Pattern: `(`, Label: "x"}, phones[0]}, "bangkok"}, false},
+		{"invalid pattern after match", "+66-2", []interface{}{[]Category{phones[0], {Pattern: `(`, Label: "x"}}, "bangkok"}, true},
+		{"entry without pattern", "+66-2", []interface{}{[]interface{}{map[string]interface{}{"label": "x"}}, "x"}, false},
+		{"no categories", "+66-2", []interface{}{[]Category{}, "bangkok"}, false},
+		{"categories not a list", "+66-2", []interface{}{phones[0], "bangkok"}, false},
+		{"missing label", "+66-2", []interface{}{phones}, false},
+		{"null field", nil, []interface{}{phones, "bangkok"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := map[string]interface{}{"field": tt.value}
+			if result := evalSingleCondition("field", OperatorCategoryIs, tt.spec, data); result != tt.expect {
+				t.Errorf("category_is(%v, %v) = %v, want %v", tt.value, tt.spec, result, tt.expect)
+			}
+		})
+	}
+}
+
+func TestBucketIsOperator(t *testing.T) {
+	ages := []Bucket{
+		{Max: 12, Label: "child"},
+		{Max: 19, Label: "teen"},
+		{Max: 64, Label: "adult"},
+		{Label: "senior"},
+	}
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(`[
+		{"max": 0, "label": "refund"},
+		{"max": 99.99, "label": "small"},
+		{"max": 999.99, "label": "medium"}
+	]`), &decoded); err != nil {
+		t.Fatalf("unmarshal buckets: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		value  interface{}
+		spec   interface{}
+		expect bool
+	}{
+		{"first bucket", 8, []interface{}{ages, "child"}, true},
+		{"boundary belongs to lower bucket", 12, []interface{}{ages, "child"}, true},
+		{"boundary is not in next bucket", 12, []interface{}{ages, "teen"}, false},
+		{"just above boundary", 12.5, []interface{}{ages, "teen"}, true},
+		{"upper boundary of middle bucket", 19, []interface{}{ages, "teen"}, true},
+		{"open last bucket", 90, []interface{}{ages, "senior"}, true},
+		{"numeric string", "30", []interface{}{ages, "adult"}, true},
+		{"negative number", -3, []interface{}{ages, "child"}, true},
+		{"decoded buckets", 0, []interface{}{decoded, "refund"}, true},
+		{"decoded buckets boundary", 99.99, []interface{}{decoded, "small"}, true},
+		{"decoded buckets above boundary", 100, []interface{}{decoded, "medium"}, true},
+		{"above every max", 1000, []interface{}{decoded, "medium"}, false},
+		{"above every max, empty label", 1000, []interface{}{decoded, ""}, false},
+		{"first match decides", 5, []interface{}{[]Bucket{{Max: 19, Label: "teen"}, {Max: 12, Label: "child"}}, "child"}, false},
+		{"non-numeric max before match", 30, []interface{}{[]Bucket{{Max: "ten", Label: "x"}, {Label: "y"}}, "y"}, false},
+		{"non-numeric max after match", 5, []interface{}{[]Bucket{{Max: 10, Label: "x"}, {Max: "ten", Label: "y"}}, "x"}, true},
+		{"entry without label", 5, []interface{}{[]interface{}{map[string]interface{}{"max": 10}}, ""}, false},
+		{"no buckets", 5, []interface{}{[]Bucket{}, "child"}, false},
+		{"buckets not a list", 5, []interface{}{ages[0], "child"}, false},
+		{"missing label", 5, []interface{}{ages}, false},
+		{"non-numeric field", "old", []interface{}{ages, "senior"}, false},
+		{"NaN field", math.NaN(), []interface{}{ages, "senior"}, false},
+		{"null field", nil, []interface{}{ages, "senior"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := map[string]interface{}{"field": tt.value}
+			if result := evalSingleCondition("field", OperatorBucketIs, tt.spec, data); result != tt.expect {
+				t.Errorf("bucket_is(%v, %v) = %v, want %v", tt.value, tt.spec, result, tt.expect)
+			}
+		})
+	}
+}
+
+func TestFuzzyEqOperator(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  interface{}
+		spec   interface{}
+		expect bool
+	}{
+		{"exact match", "Jonathan", []interface{}{"Jonathan", 0}, true},
+		{"exact match needed", "Jonathon", []interface{}{"Jonathan", 0}, false},
+		{"one substitution", "Jonathon", []interface{}{"Jonathan", 2}, true},
+		{"one insertion", "Johnathan", []interface{}{"Jonathan", 1}, true},
+		{"one deletion", "Jonthan", []interface{}{"Jonathan", 1}, true},
+		{"at threshold", "Johnathon", []interface{}{"Jonathan", 2}, true},
+		{"beyond threshold", "Johnathon", []interface{}{"Jonathan", 1}, false},
+		{"length difference beyond threshold", "John", []interface{}{"Jonathan", 2}, false},
+		{"transposition costs two", "Jnoathan", []interface{}{"Jonathan", 1}, false},
+		{"case matters", "jonathan", []interface{}{"Jonathan", 0}, false},
+		{"counts characters", "José", []interface{}{"Jose", 1}, true},
+		{"empty field", "", []interface{}{"abc", 3}, true},
+		{"number as text", 12345, []interface{}{"12346", 1}, true},
+		{"json.Number distance", "kitten", []interface{}{"sitting", json.Number("3")}, true},
+		{"kitten to sitting", "kitten", []interface{}{"sitting", 2}, false},
+		{"negative distance", "Jonathan", []interface{}{"Jonathan", -1}, false},
+		{"fractional distance", "Jonathon", []interface{}{"Jonathan", 1.5}, false},
+		{"non-numeric distance", "Jonathan", []interface{}{"Jonathan", "two"}, false},
+		{"null target", "Jonathan", []interface{}{nil, 10}, false},
+		{"missing distance", "Jonathan", []interface{}{"Jonathan"}, false},
+		{"not a list", "Jonathan", "Jonathan", false},
+		{"null field", nil, []interface{}{"", 0}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := map[string]interface{}{"field": tt.value}
+			if result := evalSingleCondition("field", OperatorFuzzyEq, tt.spec, data); result != tt.expect {
+				t.Errorf("fuzzy_eq(%v, %v) = %v, want %v", tt.value, tt.spec, result, tt.expect)
+			}
+		})
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	for _, tt := range []struct {
+		a, b string
+		max  int
+		want int
+	}{
+		{"", "", 0, 0},
+		{"abc", "", 5, 3},
+		{"kitten", "sitting", 5, 3},
+		{"sitting", "kitten", 5, 3},
+		{"flaw", "lawn", 5, 2},
+		{"kitten", "sitting", 2, -1},
+	} {
+		if got := levenshtein([]rune(tt.a), []rune(tt.b), tt.max); got != tt.want {
+			t.Errorf("levenshtein(%q, %q, %d) = %d, want %d", tt.a, tt.b, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestSetOperators(t *testing.T) {
+	data := map[string]interface{}{
+		"roles":  []string{"editor", "admin", "viewer", "admin"},
+		"tags":   []interface{}{"b", "a", "a"},
+		"ids":    []int{3, 1, 2},
+		"empty":  []string{},
+		"single": "admin",
+	}
+
+	tests := []struct {
+		key    string
+		op     Operator
+		value  interface{}
+		expect bool
+	}{
+		{"roles", OperatorSuperset, []interface{}{"admin", "viewer"}, true},
+		{"roles", OperatorSuperset, []string{"viewer", "admin", "admin"}, true},
+		{"roles", OperatorSuperset, []interface{}{"admin", "owner"}, false},
+		{"roles", OperatorSuperset, []interface{}{}, true},
+		{"roles", OperatorSubset, []interface{}{"admin", "editor", "viewer", "owner"}, true},
+		{"roles", OperatorSubset, []interface{}{"admin", "editor"}, false},
+		{"empty", OperatorSubset, []interface{}{"admin"}, true},
+		{"tags", OperatorSetEq, []string{"a", "b"}, true},
+		{"tags", OperatorSetEq, []interface{}{"a", "b", "b", "a"}, true},
+		{"tags", OperatorSetEq, []interface{}{"a"}, false},
+		{"tags", OperatorSetEq, []interface{}{"a", "b", "c"}, false},
+		{"ids", OperatorSetEq, []interface{}{1.0, 2.0, 3.0}, true},
+		{"ids", OperatorSuperset, [2]int{1, 3}, true},
+		{"empty", OperatorSetEq, []interface{}{}, true},
+		// Non-collection operands never match
+		{"single", OperatorSubset, []interface{}{"admin"}, false},
+		{"roles", OperatorSuperset, "admin", false},
+		{"roles", OperatorSetEq, nil, false},
+		{"missing", OperatorSubset, []interface{}{"admin"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %s %v", tt.key, tt.op, tt.value), func(t *testing.T) {
+			if result := evalSingleCondition(tt.key, tt.op, tt.value, data); result != tt.expect {
+				t.Errorf("%s %s %v = %v, want %v", tt.key, tt.op, tt.value, result, tt.expect)
+			}
+		})
+	}
+}
+
+func TestSetContainsOperator(t *testing.T) {
+	data := map[string]interface{}{
+		"tags":    "vip; beta;early ;",
+		"csv":     "TH, SG,,MY",
+		"ids":     "1,2,3",
+		"pipes":   "a || b||c",
+		"list":    []string{"vip", "beta"},
+		"partial": "vip;beta-tester",
+		"empty":   "",
+		"number":  12,
+	}
+
+	tests := []struct {
+		name   string
+		key    string
+		value  interface{}
+		expect bool
+	}{
+		{"semicolon member", "tags", []interface{}{";", "beta"}, true},
+		{"semicolon trimmed", "tags", []interface{}{";", "early"}, true},
+		{"semicolon not member", "tags", []interface{}{";", "alpha"}, false},
+		{"whole elements only", "partial", []interface{}{";", "beta"}, false},
+		{"empty element dropped", "tags", []interface{}{";", ""}, false},
+		{"comma member", "csv", []interface{}{",", "SG"}, true},
+		{"comma superset", "csv", []interface{}{",", []string{"MY", "TH"}}, true},
+		{"comma not superset", "csv", []interface{}{",", []string{"TH", "US"}}, false},
+		{"wrong delimiter", "csv", []interface{}{";", "SG"}, false},
+		{"multi-character delimiter", "pipes", []interface{}{"||", "b"}, true},
+		{"empty list", "csv", []interface{}{",", []string{}}, true},
+		{"numbers match numerically", "ids", []interface{}{",", 2}, true},
+		{"slice field", "list", []interface{}{";", "beta"}, true},
+		{"slice field superset", "list", []interface{}{";", []interface{}{"beta", "vip"}}, true},
+		{"empty field", "empty", []interface{}{",", "a"}, false},
+		{"number field", "number", []interface{}{",", 12}, false},
+		{"missing field", "missing", []interface{}{",", "a"}, false},
+		{"null value", "tags", []interface{}{";", nil}, false},
+		{"empty delimiter", "tags", []interface{}{"", "vip"}, false},
+		{"delimiter not a string", "tags", []interface{}{59, "vip"}, false},
+		{"missing delimiter", "tags", "vip", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := evalSingleCondition(tt.key, OperatorSetContains, tt.value, data); result != tt.expect {
+				t.Errorf("set_contains(%s, %v) = %v, want %v", tt.key, tt.value, result, tt.expect)
+			}
+		})
+	}
+}
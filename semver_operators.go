@@ -0,0 +1,136 @@
+package jsonvaluate
+
+import (
+	"strconv"
+	"strings"
+)
+
+// semver is a parsed semantic version. Build metadata is dropped since it
+// doesn't affect precedence.
+type semver struct {
+	core       [3]uint64
+	prerelease []string
+}
+
+// compareSemver compares v and expected as semantic versions for the semver
+// operators. Inputs that aren't versions never match.
+func compareSemver(v interface{}, op Operator, expected interface{}) bool {
+	if v == nil || expected == nil {
+		return false
+	}
+	a, ok := parseSemver(toString(v))
+	if !ok {
+		return false
+	}
+	b, ok := parseSemver(toString(expected))
+	if !ok {
+		return false
+	}
+
+	c := a.compare(b)
+	switch op {
+	case OperatorSemverEq:
+		return c == 0
+	case OperatorSemverGt:
+		return c > 0
+	case OperatorSemverGte:
+		return c >= 0
+	case OperatorSemverLt:
+		return c < 0
+	case OperatorSemverLte:
+		return c <= 0
+	default:
+		return false
+	}
+}
+
+// parseSemver parses a version such as "1.10.0", "v2.0.0-rc.1" or
+// "1.0.0+build.5". Missing minor and patch numbers are treated as 0, so "1.9"
+// is "1.9.0".
+func parseSemver(s string) (semver, bool) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i]
+	}
+
+	var version semver
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		version.prerelease = strings.Split(s[i+1:], ".")
+		for _, id := range version.prerelease {
+			if id == "" {
+				return semver{}, false
+			}
+		}
+		s = s[:i]
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return semver{}, false
+	}
+	for i, part := range parts {
+		n, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return semver{}, false
+		}
+		version.core[i] = n
+	}
+	return version, true
+}
+
+// compare returns -1, 0, or 1 following semver precedence: core numbers are
+// compared numerically, and a prerelease version is lower than the release.
+func (v semver) compare(other semver) int {
+	for i := range v.core {
+		if v.core[i] != other.core[i] {
+			if v.core[i] < other.core[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	switch {
+	case len(v.prerelease) == 0 && len(other.prerelease) == 0:
+		return 0
+	case len(v.prerelease) == 0:
+		return 1
+	case len(other.prerelease) == 0:
+		return -1
+	}
+
+	for i := 0; i < len(v.prerelease) && i < len(other.prerelease); i++ {
+		if c := comparePrereleaseID(v.prerelease[i], other.prerelease[i]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(v.prerelease) < len(other.prerelease):
+		return -1
+	case len(v.prerelease) > len(other.prerelease):
+		return 1
+	}
+	return 0
+}
+
+// comparePrereleaseID compares two prerelease identifiers. Numeric
+// identifiers are compared numerically and are lower than alphanumeric ones,
+// which are compared lexically.
+func comparePrereleaseID(a, b string) int {
+	na, errA := strconv.ParseUint(a, 10, 64)
+	nb, errB := strconv.ParseUint(b, 10, 64)
+	switch {
+	case errA == nil && errB == nil:
+		if na < nb {
+			return -1
+		} else if na > nb {
+			return 1
+		}
+		return 0
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
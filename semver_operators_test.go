@@ -0,0 +1,59 @@
+package jsonvaluate
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSemverOperators(t *testing.T) {
+	tests := []struct {
+		version  interface{}
+		op       Operator
+		expected interface{}
+		expect   bool
+	}{
+		// "1.10.0" < "1.9.0" as strings, but not as versions
+		{"1.10.0", OperatorSemverGte, "1.9.0", true},
+		{"1.10.0", OperatorSemverGt, "1.9.0", true},
+		{"1.10.0", OperatorSemverLt, "1.9.0", false},
+		{"1.9.0", OperatorSemverLt, "1.10.0", true},
+		{"1.10", OperatorSemverGt, "1.9", true},
+		{"2.0.0", OperatorSemverEq, "2.0.0", true},
+		{"2.0.0", OperatorSemverEq, "2.0", true},
+		{"v2.0.0", OperatorSemverEq, "2.0.0", true},
+		{"2.0.1", OperatorSemverEq, "2.0.0", false},
+		{"2.0.0", OperatorSemverLte, "2.0.0", true},
+		{"2.0.0", OperatorSemverGte, "2.0.0", true},
+		{"2.0.0", OperatorSemverGt, "2.0.0", false},
+		// Prerelease versions are lower than the release
+		{"1.0.0-rc1", OperatorSemverLt, "1.0.0", true},
+		{"1.0.0", OperatorSemverGt, "1.0.0-rc1", true},
+		{"1.0.0-alpha", OperatorSemverLt, "1.0.0-alpha.1", true},
+		{"1.0.0-alpha.1", OperatorSemverLt, "1.0.0-alpha.beta", true},
+		{"1.0.0-alpha.beta", OperatorSemverLt, "1.0.0-beta", true},
+		{"1.0.0-beta.2", OperatorSemverLt, "1.0.0-beta.11", true},
+		{"1.0.0-beta.11", OperatorSemverLt, "1.0.0-rc.1", true},
+		{"1.0.0-rc.1", OperatorSemverEq, "1.0.0-rc.1", true},
+		// Build metadata is ignored
+		{"1.0.0+build.5", OperatorSemverEq, "1.0.0+build.9", true},
+		{"1.0.0-rc.1+build.5", OperatorSemverLt, "1.0.0", true},
+		// Unparseable inputs never match
+		{"latest", OperatorSemverGte, "1.0.0", false},
+		{"1.0.0", OperatorSemverLt, "latest", false},
+		{"1.0.0.0", OperatorSemverEq, "1.0.0.0", false},
+		{"1.x", OperatorSemverGte, "1.0", false},
+		{"1.0.0-", OperatorSemverLt, "1.0.0", false},
+		{"", OperatorSemverLte, "1.0.0", false},
+		{nil, OperatorSemverLte, "1.0.0", false},
+		{"1.0.0", OperatorSemverEq, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%v %s %v", tt.version, tt.op, tt.expected), func(t *testing.T) {
+			data := map[string]interface{}{"version": tt.version}
+			if result := evalSingleCondition("version", tt.op, tt.expected, data); result != tt.expect {
+				t.Errorf("%v %s %v = %v, want %v", tt.version, tt.op, tt.expected, result, tt.expect)
+			}
+		})
+	}
+}
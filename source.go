@@ -0,0 +1,52 @@
+package jsonvaluate
+
+import "sync"
+
+// DataSource provides the data a condition tree is evaluated against, for
+// callers whose data isn't held in a map[string]interface{}, such as a
+// sync.Map updated concurrently. Get returns the value for a top-level key
+// and whether the key exists; nested keys such as "user.address.city" are
+// resolved by calling Get for the first segment and descending into the
+// returned maps.
+type DataSource interface {
+	Get(key string) (interface{}, bool)
+}
+
+// MapSource adapts a map[string]interface{} to a DataSource.
+type MapSource map[string]interface{}
+
+// Get returns the value for key in the map.
+func (m MapSource) Get(key string) (interface{}, bool) {
+	v, exists := m[key]
+	return v, exists
+}
+
+// SyncMapSource adapts a sync.Map with string keys to a DataSource.
+type SyncMapSource struct {
+	Map *sync.Map
+}
+
+// Get returns the value stored for key in the sync.Map.
+func (s SyncMapSource) Get(key string) (interface{}, bool) {
+	return s.Map.Load(key)
+}
+
+// EvaluateConditionSource evaluates a condition tree against the data
+// provided by src, like EvaluateCondition. Keys are looked up in src exactly:
+// Evaluator.CaseInsensitiveKeys and a top-level "*" wildcard need the keys of
+// the data, so they only apply when src is a MapSource.
+func EvaluateConditionSource(cond Conditions, src DataSource) bool {
+	return defaultEvaluator.EvaluateConditionSource(cond, src)
+}
+
+// EvaluateConditionSource evaluates a condition tree against the data
+// provided by src. See the package-level EvaluateConditionSource for details.
+func (e *Evaluator) EvaluateConditionSource(cond Conditions, src DataSource) bool {
+	if m, ok := src.(MapSource); ok {
+		return e.EvaluateCondition(cond, m)
+	}
+	ev := e.newEvaluation(nil, false)
+	ev.source = src
+	result, _ := ev.evalCondition(cond)
+	return result
+}
@@ -0,0 +1,64 @@
+package jsonvaluate
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestEvaluateConditionSource(t *testing.T) {
+	var m sync.Map
+	m.Store("age", 30)
+	m.Store("country", "TH")
+	m.Store("user", map[interface{}]interface{}{"address": map[string]interface{}{"city": "Bangkok"}})
+	m.Store("nothing", nil)
+	src := SyncMapSource{Map: &m}
+
+	tests := []struct {
+		name   string
+		cond   Conditions
+		expect bool
+	}{
+		{"top-level key", NewSimpleCondition("age", OperatorGte, 18), true},
+		{"group", NewAndGroup(NewSimpleCondition("age", OperatorGte, 18), NewSimpleCondition("country", OperatorEq, "TH")), true},
+		{"nested key", NewSimpleCondition("user.address.city", OperatorEq, "Bangkok"), true},
+		{"missing key", NewSimpleCondition("score", OperatorIsnull, nil), true},
+		{"null value", NewSimpleCondition("nothing", OperatorIsnull, nil), true},
+		{"missing key with default", Conditions{Key: "score", Operator: OperatorGt, Value: 5, Default: 10}, true},
+		{"false", NewSimpleCondition("country", OperatorEq, "SG"), false},
+		{"exact keys only", NewSimpleCondition("COUNTRY", OperatorIsnotnull, nil), false},
+		{"no top-level wildcard", NewSimpleCondition("*", OperatorIsnotnull, nil), false},
+	}
+
+	e := NewEvaluator()
+	e.CaseInsensitiveKeys = true
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := e.EvaluateConditionSource(tt.cond, src); result != tt.expect {
+				t.Errorf("EvaluateConditionSource() = %v, want %v", result, tt.expect)
+			}
+		})
+	}
+
+	// Updates to the sync.Map are seen by later evaluations
+	m.Store("country", "SG")
+	if !EvaluateConditionSource(NewSimpleCondition("country", OperatorEq, "SG"), src) {
+		t.Error("expected updated value to be used")
+	}
+}
+
+func TestEvaluateConditionSource_MapSource(t *testing.T) {
+	data := map[string]interface{}{"Country": "TH", "scores": map[string]interface{}{"a": 1, "b": 2}}
+	e := NewEvaluator()
+	e.CaseInsensitiveKeys = true
+
+	// A MapSource behaves exactly like the map itself
+	for _, cond := range []Conditions{
+		NewSimpleCondition("country", OperatorEq, "TH"),
+		NewSimpleCondition("scores.*", OperatorAll, OperatorValue{Operator: OperatorGt, Value: 0}),
+		NewSimpleCondition("*", OperatorIsnotnull, nil),
+	} {
+		if result, want := e.EvaluateConditionSource(cond, MapSource(data)), e.EvaluateCondition(cond, data); result != want || !result {
+			t.Errorf("%s %s: EvaluateConditionSource() = %v, EvaluateCondition() = %v", cond.Key, cond.Operator, result, want)
+		}
+	}
+}
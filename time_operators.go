@@ -0,0 +1,385 @@
+package jsonvaluate
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// datePartEq checks if a date component of v equals expected, or one of the
+// values in expected when it is a slice. v is parsed with toTime; values that
+// aren't times never match. Components are taken in the time's own location,
+// so strings without a zone are interpreted as UTC.
+//
+// Months may be given as numbers (1-12) or English names ("March", "mar").
+// Weekdays may be given as numbers (0 = Sunday ... 6 = Saturday) or English
+// names ("Saturday", "sat").
+func datePartEq(v interface{}, op Operator, expected interface{}) bool {
+	t, ok := toTime(v)
+	if !ok || expected == nil {
+		return false
+	}
+
+	var part int
+	var parse func(interface{}) (int, bool)
+	switch op {
+	case OperatorYearEq:
+		part, parse = t.Year(), toInt
+	case OperatorMonthEq:
+		part, parse = int(t.Month()), parseMonth
+	case OperatorDayEq:
+		part, parse = t.Day(), toInt
+	case OperatorWeekdayEq:
+		part, parse = int(t.Weekday()), parseWeekday
+	default:
+		return false
+	}
+
+	ev := reflect.ValueOf(expected)
+	if ev.Kind() != reflect.Slice && ev.Kind() != reflect.Array {
+		want, ok := parse(expected)
+		return ok && want == part
+	}
+	for i := 0; i < ev.Len(); i++ {
+		if want, ok := parse(ev.Index(i).Interface()); ok && want == part {
+			return true
+		}
+	}
+	return false
+}
+
+// toInt converts a value to an int if it is a whole number
+func toInt(v interface{}) (int, bool) {
+	n, ok := toNumber(v)
+	if !ok || n != float64(int(n)) {
+		return 0, false
+	}
+	return int(n), true
+}
+
+// parseMonth converts a month number or English month name to 1-12
+func parseMonth(v interface{}) (int, bool) {
+	if n, ok := toInt(v); ok {
+		return n, n >= 1 && n <= 12
+	}
+	name := strings.ToLower(toString(v))
+	for m := time.January; m <= time.December; m++ {
+		full := strings.ToLower(m.String())
+		if name == full || name == full[:3] {
+			return int(m), true
+		}
+	}
+	return 0, false
+}
+
+// parseWeekday converts a weekday number or English weekday name to 0-6 (Sunday = 0)
+func parseWeekday(v interface{}) (int, bool) {
+	if n, ok := toInt(v); ok {
+		return n, n >= 0 && n <= 6
+	}
+	name := strings.ToLower(toString(v))
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		full := strings.ToLower(d.String())
+		if name == full || name == full[:3] {
+			return int(d), true
+		}
+	}
+	return 0, false
+}
+
+// Layouts accepted by isdate and isdatetime when no layout is given. They are
+// the date layouts understood by toTime; a time of day alone is not a date.
+var (
+	dateTimeLayouts = []string{time.RFC3339, time.RFC3339Nano, "2006-01-02 15:04:05"}
+	dateLayouts     = append([]string{"2006-01-02"}, dateTimeLayouts...)
+)
+
+// isDate checks if v is a time.Time or a string that parses as a date (or,
+// for isdatetime, a date with a time of day). layouts, when not nil, is a Go
+// time layout or a list of layouts to parse with instead of the defaults.
+// Other values, including numbers, are not dates.
+func isDate(v interface{}, op Operator, layouts interface{}) bool {
+	if _, ok := v.(time.Time); ok {
+		return true
+	}
+	s, ok := v.(string)
+	if !ok {
+		return false
+	}
+
+	var candidates []string
+	switch lv := reflect.ValueOf(layouts); {
+	case layouts == nil && op == OperatorIsDateTime:
+		candidates = dateTimeLayouts
+	case layouts == nil:
+		candidates = dateLayouts
+	case lv.Kind() == reflect.Slice || lv.Kind() == reflect.Array:
+		for i := 0; i < lv.Len(); i++ {
+			candidates = append(candidates, toString(lv.Index(i).Interface()))
+		}
+	default:
+		candidates = []string{toString(layouts)}
+	}
+
+	for _, layout := range candidates {
+		if _, err := time.Parse(layout, s); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// within checks if the time v lies within the duration window before now,
+// i.e. now-window <= v <= now. Timestamps in the future never match.
+func (ev *evaluation) within(v, window interface{}) bool {
+	t, ok := toTime(v)
+	if !ok {
+		return false
+	}
+	d, ok := toDuration(window)
+	if !ok {
+		return false
+	}
+
+	age := ev.currentTime().Sub(t)
+	return age >= 0 && age <= d
+}
+
+// olderThan checks if the time v lies more than the duration before now.
+// Timestamps in the future never match.
+func (ev *evaluation) olderThan(v, threshold interface{}) bool {
+	t, ok := toTime(v)
+	if !ok {
+		return false
+	}
+	d, ok := toDuration(threshold)
+	if !ok {
+		return false
+	}
+
+	return ev.currentTime().Sub(t) > d
+}
+
+// timeWithin checks if the time v is within a duration of a target time,
+// with spec given as [target, duration]: |v - target| <= duration. The
+// duration may be a time.Duration or a duration string such as "5s". Values
+// that aren't times, negative durations and malformed specs never match.
+func timeWithin(v, spec interface{}) bool {
+	sv := reflect.ValueOf(spec)
+	if (sv.Kind() != reflect.Slice && sv.Kind() != reflect.Array) || sv.Len() != 2 {
+		return false
+	}
+	t, ok := toTime(v)
+	if !ok {
+		return false
+	}
+	target, ok := toTime(sv.Index(0).Interface())
+	if !ok {
+		return false
+	}
+	window, ok := toDuration(sv.Index(1).Interface())
+	if !ok || window < 0 {
+		return false
+	}
+
+	diff := t.Sub(target)
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff <= window
+}
+
+// toDuration converts a time.Duration or a duration string such as "168h" to
+// a time.Duration. Bare numbers are rejected since their unit is ambiguous.
+func toDuration(v interface{}) (time.Duration, bool) {
+	switch val := v.(type) {
+	case time.Duration:
+		return val, true
+	case string:
+		if d, err := time.ParseDuration(val); err == nil {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// Schedule is the value of the "in_schedule" operator: a daily window of
+// time, from Start up to but not including End, on the given days of the
+// week, in a time zone. In JSON it is written as {"days": ["mon", "tue",
+// "wed", "thu", "fri"], "start": "09:00", "end": "17:00", "timezone":
+// "Asia/Bangkok"}, with days given as names or numbers (0 = Sunday).
+type Schedule struct {
+	// Days are the days on which the window starts; none means every day
+	Days []time.Weekday `json:"days,omitempty"`
+	// Start and End are times of day as "15:04" or "15:04:05", defaulting
+	// to "00:00" and "24:00". An End before Start is on the next day, so
+	// "22:00" to "06:00" is overnight.
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+	// Timezone is the IANA name of the zone the window is in, such as
+	// "Europe/Paris"; empty means the time's own location
+	Timezone string `json:"timezone,omitempty"`
+}
+
+// UnmarshalJSON decodes a Schedule from JSON, accepting days as names, such
+// as "mon" or "Monday", or as numbers.
+func (s *Schedule) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Days     []interface{} `json:"days"`
+		Start    string        `json:"start"`
+		End      string        `json:"end"`
+		Timezone string        `json:"timezone"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	schedule := Schedule{Start: raw.Start, End: raw.End, Timezone: raw.Timezone}
+	for _, v := range raw.Days {
+		day, ok := parseWeekday(v)
+		if !ok {
+			return fmt.Errorf("schedule: invalid day %v", v)
+		}
+		schedule.Days = append(schedule.Days, time.Weekday(day))
+	}
+	*s = schedule
+	return nil
+}
+
+// inSchedule checks if the time v falls in the schedule spec. A window that
+// runs past midnight belongs to the day it starts on, so a Friday "22:00" to
+// "06:00" window includes Saturday 05:00. Values that aren't times,
+// malformed schedules and unknown time zones never match, and neither does
+// an empty window, with Start equal to End.
+func inSchedule(v, spec interface{}) bool {
+	t, ok := toTime(v)
+	if !ok {
+		return false
+	}
+	schedule, ok := toSchedule(spec)
+	if !ok {
+		return false
+	}
+	start, ok := parseClock(schedule.Start, 0)
+	if !ok {
+		return false
+	}
+	end, ok := parseClock(schedule.End, 24*time.Hour)
+	if !ok {
+		return false
+	}
+	if schedule.Timezone != "" {
+		loc, err := cachedLocation(schedule.Timezone)
+		if err != nil {
+			return false
+		}
+		t = t.In(loc)
+	}
+
+	clock := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second + time.Duration(t.Nanosecond())
+	switch {
+	case start < end:
+		return clock >= start && clock < end && onDay(schedule.Days, t.Weekday())
+	case start > end && clock >= start:
+		return onDay(schedule.Days, t.Weekday())
+	case start > end && clock < end:
+		// The part of the window after midnight started the day before
+		return onDay(schedule.Days, (t.Weekday()+6)%7)
+	}
+	return false
+}
+
+// onDay checks if day is one of days, or days is empty
+func onDay(days []time.Weekday, day time.Weekday) bool {
+	if len(days) == 0 {
+		return true
+	}
+	for _, d := range days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// toSchedule converts a Schedule or decoded JSON object to a Schedule
+func toSchedule(v interface{}) (Schedule, bool) {
+	switch val := v.(type) {
+	case Schedule:
+		return val, true
+	case map[string]interface{}:
+		var schedule Schedule
+		if days, ok := val["days"]; ok {
+			dv := reflect.ValueOf(days)
+			if dv.Kind() != reflect.Slice && dv.Kind() != reflect.Array {
+				return Schedule{}, false
+			}
+			for i := 0; i < dv.Len(); i++ {
+				day, ok := parseWeekday(dv.Index(i).Interface())
+				if !ok {
+					return Schedule{}, false
+				}
+				schedule.Days = append(schedule.Days, time.Weekday(day))
+			}
+		}
+		for key, field := range map[string]*string{"start": &schedule.Start, "end": &schedule.End, "timezone": &schedule.Timezone} {
+			if s, ok := val[key]; ok {
+				if *field, ok = s.(string); !ok {
+					return Schedule{}, false
+				}
+			}
+		}
+		return schedule, true
+	}
+	return Schedule{}, false
+}
+
+// parseClock parses a time of day given as "15:04" or "15:04:05", up to
+// "24:00", into the time since midnight, returning def for an empty string
+func parseClock(s string, def time.Duration) (time.Duration, bool) {
+	if s == "" {
+		return def, true
+	}
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 && len(parts) != 3 {
+		return 0, false
+	}
+	var fields [3]int
+	for i, part := range parts {
+		// Exactly two digits, so signs such as "-1" are rejected
+		if len(part) != 2 || part[0] < '0' || part[0] > '9' || part[1] < '0' || part[1] > '9' {
+			return 0, false
+		}
+		fields[i] = int(part[0]-'0')*10 + int(part[1]-'0')
+	}
+	hour, minute, second := fields[0], fields[1], fields[2]
+	if hour > 24 || minute > 59 || second > 59 || (hour == 24 && minute+second > 0) {
+		return 0, false
+	}
+	return time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute + time.Duration(second)*time.Second, true
+}
+
+// Loaded time zones by name, since time.LoadLocation reads the zone database
+// on every call
+var (
+	locationCache      = make(map[string]*time.Location)
+	locationCacheMutex sync.Mutex
+)
+
+// cachedLocation returns the time zone with the given IANA name
+func cachedLocation(name string) (*time.Location, error) {
+	locationCacheMutex.Lock()
+	defer locationCacheMutex.Unlock()
+	if loc, ok := locationCache[name]; ok {
+		return loc, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locationCache[name] = loc
+	return loc, nil
+}
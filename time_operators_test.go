@@ -0,0 +1,379 @@
+package jsonvaluate
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDatePartOperators(t *testing.T) {
+	// 2024-07-06 is a Saturday
+	data := map[string]interface{}{
+		"created":    time.Date(2024, 7, 6, 12, 0, 0, 0, time.UTC),
+		"createdStr": "2024-07-06T12:00:00Z",
+		"weekday":    "2024-07-03",
+		"notTime":    "yesterday",
+		"number":     2024,
+	}
+
+	tests := []struct {
+		name   string
+		key    string
+		op     Operator
+		value  interface{}
+		expect bool
+	}{
+		{"year time", "created", OperatorYearEq, 2024, true},
+		{"year string", "createdStr", OperatorYearEq, 2024, true},
+		{"year mismatch", "created", OperatorYearEq, 2023, false},
+		{"year list", "created", OperatorYearEq, []interface{}{2023, 2024}, true},
+		{"year numeric string", "created", OperatorYearEq, "2024", true},
+		{"month number", "created", OperatorMonthEq, 7, true},
+		{"month name", "createdStr", OperatorMonthEq, "July", true},
+		{"month short name", "created", OperatorMonthEq, "jul", true},
+		{"month mismatch", "created", OperatorMonthEq, 8, false},
+		{"month out of range", "created", OperatorMonthEq, 19, false},
+		{"day", "created", OperatorDayEq, 6, true},
+		{"day mismatch", "createdStr", OperatorDayEq, 7, false},
+		{"weekday number", "created", OperatorWeekdayEq, 6, true},
+		{"weekday name", "createdStr", OperatorWeekdayEq, "Saturday", true},
+		{"weekend", "created", OperatorWeekdayEq, []string{"sat", "sun"}, true},
+		{"not weekend", "weekday", OperatorWeekdayEq, []string{"sat", "sun"}, false},
+		{"weekday midweek", "weekday", OperatorWeekdayEq, "wednesday", true},
+		{"not a time", "notTime", OperatorYearEq, 2024, false},
+		{"number is not a time", "number", OperatorYearEq, 2024, false},
+		{"missing key", "missing", OperatorYearEq, 2024, false},
+		{"nil value", "created", OperatorYearEq, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := evalSingleCondition(tt.key, tt.op, tt.value, data)
+			if result != tt.expect {
+				t.Errorf("evalSingleCondition(%s, %s, %v) = %v, want %v", tt.key, tt.op, tt.value, result, tt.expect)
+			}
+		})
+	}
+}
+
+func TestRelativeTimeOperators(t *testing.T) {
+	now := time.Date(2024, 7, 10, 12, 0, 0, 0, time.UTC)
+	e := NewEvaluator()
+	e.Now = func() time.Time { return now }
+
+	data := map[string]interface{}{
+		"updated":    now.Add(-3 * 24 * time.Hour),
+		"updatedStr": now.Add(-10 * 24 * time.Hour).Format(time.RFC3339),
+		"token":      now.Add(-2 * time.Hour),
+		"exact":      now.Add(-time.Hour),
+		"future":     now.Add(time.Hour),
+		"notTime":    "soon",
+	}
+
+	tests := []struct {
+		name   string
+		key    string
+		op     Operator
+		value  interface{}
+		expect bool
+	}{
+		{"within last 7 days", "updated", OperatorWithin, "168h", true},
+		{"within as duration", "updated", OperatorWithin, 7 * 24 * time.Hour, true},
+		{"not within last 7 days", "updatedStr", OperatorWithin, "168h", false},
+		{"within boundary", "exact", OperatorWithin, "1h", true},
+		{"within future", "future", OperatorWithin, "168h", false},
+		{"older than 1h", "token", OperatorOlderThan, "1h", true},
+		{"not older than 3h", "token", OperatorOlderThan, "3h", false},
+		{"older than boundary", "exact", OperatorOlderThan, time.Hour, false},
+		{"older than future", "future", OperatorOlderThan, "0s", false},
+		{"older than string time", "updatedStr", OperatorOlderThan, "168h", true},
+		{"bare number duration", "updated", OperatorWithin, 1000, false},
+		{"invalid duration", "updated", OperatorWithin, "a week", false},
+		{"not a time", "notTime", OperatorWithin, "1h", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := e.EvaluateCondition(NewSimpleCondition(tt.key, tt.op, tt.value), data)
+			if result != tt.expect {
+				t.Errorf("EvaluateCondition(%s, %s, %v) = %v, want %v", tt.key, tt.op, tt.value, result, tt.expect)
+			}
+		})
+	}
+}
+
+func TestRelativeTimeOperators_ClockReadOnce(t *testing.T) {
+	calls := 0
+	e := NewEvaluator()
+	e.Now = func() time.Time {
+		calls++
+		return time.Date(2024, 7, 10, 12, 0, 0, 0, time.UTC)
+	}
+
+	data := map[string]interface{}{"a": "2024-07-10", "b": "2024-07-09"}
+	cond := NewAndGroup(
+		NewSimpleCondition("a", OperatorWithin, "24h"),
+		NewSimpleCondition("b", OperatorWithin, "48h"),
+	)
+	if !e.EvaluateCondition(cond, data) {
+		t.Error("Both timestamps should be within the window")
+	}
+	if calls != 1 {
+		t.Errorf("Expected the clock to be read once, got %d", calls)
+	}
+
+	// A clock returning the zero time is still read only once
+	calls = 0
+	e.Now = func() time.Time {
+		calls++
+		return time.Time{}
+	}
+	e.EvaluateCondition(NewAndGroup(
+		NewSimpleCondition("a", OperatorGt, Now()),
+		NewSimpleCondition("b", OperatorGt, Now()),
+	), data)
+	if calls != 1 {
+		t.Errorf("Expected a zero clock to be read once, got %d", calls)
+	}
+}
+
+func TestNowValue(t *testing.T) {
+	now := time.Date(2024, 7, 10, 12, 0, 0, 0, time.UTC)
+	calls := 0
+	e := NewEvaluator()
+	e.Now = func() time.Time {
+		calls++
+		return now
+	}
+
+	data := map[string]interface{}{
+		"expires_at":    now.Add(time.Hour),
+		"expired_at":    now.Add(-time.Hour),
+		"expires_str":   now.Add(24 * time.Hour).Format(time.RFC3339),
+		"expires_date":  "2024-07-09",
+		"checked_at":    now,
+		"not_a_time":    "soon",
+		"starts_at":     now.Add(-time.Minute),
+		"subscriptions": []interface{}{now.Add(-time.Hour), now.Add(time.Hour)},
+	}
+
+	tests := []struct {
+		name   string
+		cond   Conditions
+		expect bool
+	}{
+		{"future time", NewSimpleCondition("expires_at", OperatorGt, Now()), true},
+		{"past time", NewSimpleCondition("expired_at", OperatorGt, Now()), false},
+		{"past time before now", NewSimpleCondition("expired_at", OperatorLt, Now()), true},
+		{"time string", NewSimpleCondition("expires_str", OperatorGt, Now()), true},
+		{"date string", NewSimpleCondition("expires_date", OperatorGte, Now()), false},
+		{"same instant", NewSimpleCondition("checked_at", OperatorEq, Now()), true},
+		{"not a time", NewSimpleCondition("not_a_time", OperatorGt, Now()), false},
+		{"missing field", NewSimpleCondition("missing", OperatorGt, Now()), false},
+		{"quantifier", NewSimpleCondition("subscriptions", OperatorAny, OperatorValue{Operator: OperatorGt, Value: Now()}), true},
+		{"active window", NewAndGroup(
+			NewSimpleCondition("starts_at", OperatorLte, Now()),
+			NewSimpleCondition("expires_at", OperatorGt, Now()),
+		), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = 0
+			if result := e.EvaluateCondition(tt.cond, data); result != tt.expect {
+				t.Errorf("EvaluateCondition() = %v, want %v", result, tt.expect)
+			}
+			if calls > 1 {
+				t.Errorf("clock read %d times, want at most once", calls)
+			}
+		})
+	}
+
+	// Validation leaves Now() to evaluation time
+	if err := ValidateConditions(NewSimpleCondition("expires_at", OperatorGt, Now())); err != nil {
+		t.Errorf("ValidateConditions() = %v", err)
+	}
+}
+
+func TestTimeWithinOperator(t *testing.T) {
+	target := time.Date(2024, 7, 10, 12, 0, 0, 0, time.UTC)
+	targetStr := target.Format(time.RFC3339)
+
+	tests := []struct {
+		name   string
+		value  interface{}
+		spec   interface{}
+		expect bool
+	}{
+		{"same instant", target, []interface{}{target, "5s"}, true},
+		{"inside after", target.Add(3 * time.Second), []interface{}{target, "5s"}, true},
+		{"inside before", target.Add(-3 * time.Second), []interface{}{target, "5s"}, true},
+		{"boundary after", target.Add(5 * time.Second), []interface{}{target, "5s"}, true},
+		{"boundary before", target.Add(-5 * time.Second), []interface{}{target, 5 * time.Second}, true},
+		{"outside after", target.Add(5*time.Second + time.Nanosecond), []interface{}{target, "5s"}, false},
+		{"outside before", target.Add(-6 * time.Second), []interface{}{target, "5s"}, false},
+		{"string times", target.Add(2 * time.Second).Format(time.RFC3339), []interface{}{targetStr, "5s"}, true},
+		{"other zone", target.In(time.FixedZone("ICT", 7*3600)), []interface{}{targetStr, "0s"}, true},
+		{"zero window", target.Add(time.Millisecond), []interface{}{target, "0s"}, false},
+		{"negative window", target, []interface{}{target, "-5s"}, false},
+		{"bare number window", target, []interface{}{target, 5}, false},
+		{"not a time", "soon", []interface{}{target, "5s"}, false},
+		{"target not a time", target, []interface{}{"soon", "5s"}, false},
+		{"missing window", target, []interface{}{target}, false},
+		{"not a slice", target, "5s", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := map[string]interface{}{"created": tt.value}
+			if result := evalSingleCondition("created", OperatorTimeWithin, tt.spec, data); result != tt.expect {
+				t.Errorf("time_within(%v, %v) = %v, want %v", tt.value, tt.spec, result, tt.expect)
+			}
+		})
+	}
+}
+
+func TestIsDateOperators(t *testing.T) {
+	data := map[string]interface{}{
+		"date":       "1990-05-17",
+		"datetime":   "1990-05-17T08:30:00Z",
+		"spaced":     "1990-05-17 08:30:00",
+		"timeOnly":   "08:30:00",
+		"impossible": "1990-02-30",
+		"text":       "yesterday",
+		"us":         "05/17/1990",
+		"timeValue":  time.Date(1990, 5, 17, 0, 0, 0, 0, time.UTC),
+		"unix":       int64(643000000),
+		"empty":      "",
+	}
+
+	tests := []struct {
+		key    string
+		op     Operator
+		value  interface{}
+		expect bool
+	}{
+		{"date", OperatorIsDate, nil, true},
+		{"datetime", OperatorIsDate, nil, true},
+		{"spaced", OperatorIsDate, nil, true},
+		{"timeValue", OperatorIsDate, nil, true},
+		{"timeOnly", OperatorIsDate, nil, false},
+		{"impossible", OperatorIsDate, nil, false},
+		{"text", OperatorIsDate, nil, false},
+		{"us", OperatorIsDate, nil, false},
+		{"unix", OperatorIsDate, nil, false},
+		{"empty", OperatorIsDate, nil, false},
+		{"missing", OperatorIsDate, nil, false},
+		{"datetime", OperatorIsDateTime, nil, true},
+		{"spaced", OperatorIsDateTime, nil, true},
+		{"timeValue", OperatorIsDateTime, nil, true},
+		{"date", OperatorIsDateTime, nil, false},
+		{"timeOnly", OperatorIsDateTime, nil, false},
+		// An explicit layout replaces the defaults
+		{"us", OperatorIsDate, "01/02/2006", true},
+		{"date", OperatorIsDate, "01/02/2006", false},
+		{"us", OperatorIsDate, []interface{}{"2006-01-02", "01/02/2006"}, true},
+		{"timeOnly", OperatorIsDateTime, "15:04:05", true},
+		{"text", OperatorIsDate, []string{"2006-01-02"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.op)+" "+tt.key, func(t *testing.T) {
+			if result := evalSingleCondition(tt.key, tt.op, tt.value, data); result != tt.expect {
+				t.Errorf("%s(%s, %v) = %v, want %v", tt.op, tt.key, tt.value, result, tt.expect)
+			}
+		})
+	}
+}
+
+func TestInScheduleOperator(t *testing.T) {
+	// 2024-07-10 is a Wednesday and 2024-07-13 a Saturday
+	wednesday := func(hour, min int) time.Time { return time.Date(2024, 7, 10, hour, min, 0, 0, time.UTC) }
+	businessHours := Schedule{
+		Days:     []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+		Start:    "09:00",
+		End:      "17:00",
+		Timezone: "Asia/Bangkok",
+	}
+	overnight := Schedule{Days: []time.Weekday{time.Friday, time.Saturday}, Start: "22:00", End: "06:00"}
+
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(`{"days": ["mon", "Tuesday", 3, "thu", "fri"], "start": "09:00", "end": "17:00", "timezone": "Asia/Bangkok"}`), &decoded); err != nil {
+		t.Fatalf("unmarshal schedule: %v", err)
+	}
+	var typed Schedule
+	if err := json.Unmarshal([]byte(`{"days": [1, 2, 3, 4, 5], "start": "09:00", "end": "17:00", "timezone": "Asia/Bangkok"}`), &typed); err != nil {
+		t.Fatalf("unmarshal schedule: %v", err)
+	}
+
+	var named Schedule
+	if err := json.Unmarshal([]byte(`{"days": ["mon", "Tuesday", 3, "thu", "fri"], "start": "09:00", "end": "17:00", "timezone": "Asia/Bangkok"}`), &named); err != nil {
+		t.Fatalf("unmarshal schedule with day names: %v", err)
+	}
+	if !reflect.DeepEqual(named, businessHours) {
+		t.Errorf("unmarshaled schedule = %+v, want %+v", named, businessHours)
+	}
+	for _, invalid := range []string{`{"days": ["funday"]}`, `{"days": [7]}`, `{"days": "mon"}`, `{"start": 9}`} {
+		var schedule Schedule
+		if err := json.Unmarshal([]byte(invalid), &schedule); err == nil {
+			t.Errorf("unmarshal %s = %+v, want an error", invalid, schedule)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		value  interface{}
+		spec   interface{}
+		expect bool
+	}{
+		{"weekday in window", wednesday(3, 0), businessHours, true},
+		{"weekend", time.Date(2024, 7, 13, 3, 0, 0, 0, time.UTC), businessHours, false},
+		{"start is included", wednesday(2, 0), businessHours, true},
+		{"end is excluded", wednesday(10, 0), businessHours, false},
+		{"last second", wednesday(9, 59).Add(59 * time.Second), businessHours, true},
+		{"before start", wednesday(1, 59), businessHours, false},
+		{"zone moves the day", time.Date(2024, 7, 12, 20, 0, 0, 0, time.UTC), Schedule{Days: []time.Weekday{time.Saturday}, Timezone: "Asia/Bangkok"}, true},
+		{"zone moves the day away", time.Date(2024, 7, 12, 20, 0, 0, 0, time.UTC), Schedule{Days: []time.Weekday{time.Friday}, Timezone: "Asia/Bangkok"}, false},
+		{"own location without zone", wednesday(10, 0), Schedule{Start: "09:00", End: "17:00"}, true},
+		{"string with offset", "2024-07-10T10:00:00+07:00", businessHours, true},
+		{"decoded schedule", wednesday(3, 0), decoded, true},
+		{"decoded schedule weekend", time.Date(2024, 7, 13, 3, 0, 0, 0, time.UTC), decoded, false},
+		{"unmarshaled schedule", wednesday(3, 0), typed, true},
+		{"every day", time.Date(2024, 7, 14, 12, 0, 0, 0, time.UTC), Schedule{Start: "09:00", End: "17:00"}, true},
+		{"whole day", time.Date(2024, 7, 14, 23, 59, 59, 0, time.UTC), Schedule{Days: []time.Weekday{time.Sunday}}, true},
+		{"until midnight", wednesday(23, 30), Schedule{Start: "18:00", End: "24:00"}, true},
+		{"with seconds", wednesday(9, 0), Schedule{Start: "08:59:30", End: "09:00:30"}, true},
+
+		{"overnight before midnight", time.Date(2024, 7, 12, 23, 0, 0, 0, time.UTC), overnight, true},
+		{"overnight after midnight", time.Date(2024, 7, 13, 5, 0, 0, 0, time.UTC), overnight, true},
+		{"overnight end is excluded", time.Date(2024, 7, 13, 6, 0, 0, 0, time.UTC), overnight, false},
+		{"overnight from the day before", time.Date(2024, 7, 12, 5, 0, 0, 0, time.UTC), overnight, false},
+		{"overnight into sunday", time.Date(2024, 7, 14, 1, 0, 0, 0, time.UTC), overnight, true},
+		{"overnight into monday", time.Date(2024, 7, 15, 1, 0, 0, 0, time.UTC), overnight, false},
+
+		{"empty window", wednesday(9, 0), Schedule{Start: "09:00", End: "09:00"}, false},
+		{"unknown zone", wednesday(3, 0), Schedule{Timezone: "Mars/Olympus"}, false},
+		{"single digit hour", wednesday(10, 0), Schedule{Start: "9:00"}, false},
+		{"single digit minute", wednesday(10, 0), Schedule{Start: "09:5"}, false},
+		{"negative hour", wednesday(10, 0), Schedule{Start: "-1:00"}, false},
+		{"negative minute", wednesday(8, 0), Schedule{End: "09:-5"}, false},
+		{"signed hour", wednesday(10, 0), Schedule{Start: "+9:00"}, false},
+		{"past midnight", wednesday(10, 0), Schedule{End: "24:30"}, false},
+		{"invalid minute", wednesday(10, 0), Schedule{End: "12:60"}, false},
+		{"unknown day", wednesday(10, 0), map[string]interface{}{"days": []interface{}{"funday"}}, false},
+		{"days not a list", wednesday(10, 0), map[string]interface{}{"days": "wed"}, false},
+		{"start not a string", wednesday(10, 0), map[string]interface{}{"start": 9}, false},
+		{"not a schedule", wednesday(10, 0), "09:00-17:00", false},
+		{"not a time", "soon", Schedule{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := map[string]interface{}{"at": tt.value}
+			if result := evalSingleCondition("at", OperatorInSchedule, tt.spec, data); result != tt.expect {
+				t.Errorf("in_schedule(%v, %v) = %v, want %v", tt.value, tt.spec, result, tt.expect)
+			}
+		})
+	}
+}
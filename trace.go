@@ -0,0 +1,57 @@
+package jsonvaluate
+
+// GroupTrace records how EvaluateConditionGroupTrace combined the conditions
+// of a ConditionGroup.
+type GroupTrace struct {
+	Result bool        // Result of the group
+	Steps  []GroupStep // One step per condition, in order
+}
+
+// GroupStep is one step of the left fold over a ConditionGroup's conditions.
+type GroupStep struct {
+	Index  int  // Position of the condition in the group
+	Result bool // Result of the condition on its own, after Not
+
+	// Name and ID are those of the condition, so a failing step can be
+	// reported as "Applicant must be an adult" rather than by its key
+	Name string
+	ID   string
+
+	// Logic combines the condition with the preceding ones: the previous
+	// condition's NextLogic, with a missing or unknown NextLogic applied as
+	// AND. It is empty for the first condition.
+	Logic Logic
+
+	// Accumulator is the result of the conditions up to and including this
+	// one, i.e. the running value of the fold.
+	Accumulator bool
+
+	// Group is the trace of the nested group, when the condition is one
+	Group *GroupTrace
+}
+
+// EvaluateConditionGroupTrace evaluates a ConditionGroup like
+// EvaluateConditionGroup and records each step of the evaluation: every
+// condition's own result, the logic applied to combine it with the
+// conditions before it, and the running accumulator. This shows how the left
+// fold, where "A OR B AND C" is "(A OR B) AND C", arrived at its result.
+//
+// Example:
+//
+//	trace := EvaluateConditionGroupTrace(group, data)
+//	for _, step := range trace.Steps {
+//	    fmt.Printf("%d %s %v => %v\n", step.Index, step.Logic, step.Result, step.Accumulator)
+//	}
+func EvaluateConditionGroupTrace(group ConditionGroup, data map[string]interface{}) GroupTrace {
+	return defaultEvaluator.EvaluateConditionGroupTrace(group, data)
+}
+
+// EvaluateConditionGroupTrace evaluates a ConditionGroup and records each
+// step. See the package-level EvaluateConditionGroupTrace for details. With
+// HonorPrecedence set, the accumulator is the result of the conditions so far
+// with AND binding tighter than OR.
+func (e *Evaluator) EvaluateConditionGroupTrace(group ConditionGroup, data map[string]interface{}) GroupTrace {
+	var trace GroupTrace
+	trace.Result, _ = e.newEvaluation(data, false).evalGroup(group, &trace)
+	return trace
+}
@@ -0,0 +1,163 @@
+package jsonvaluate
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestEvaluateConditionGroupTrace(t *testing.T) {
+	data := map[string]interface{}{"age": 30, "status": "inactive", "role": "guest", "banned": true}
+
+	// age > 25 OR status == "active" AND role == "admin" AND (NOT banned OR role == "guest")
+	group := NewConditionGroup(
+		NewConditionWithLogic("age", OperatorGt, 25, LogicOr),
+		NewConditionWithLogic("status", OperatorEq, "active", LogicAnd),
+		NewConditionWithLogic("role", OperatorEq, "admin", ""),
+		ConditionWithLogic{Group: &ConditionGroup{Conditions: []ConditionWithLogic{
+			{Key: "banned", Operator: OperatorIsTrue, Not: true, NextLogic: LogicOr},
+			{Key: "role", Operator: OperatorEq, Value: "guest"},
+		}}},
+	)
+
+	trace := EvaluateConditionGroupTrace(group, data)
+	want := GroupTrace{
+		Result: false,
+		Steps: []GroupStep{
+			{Index: 0, Result: true, Logic: "", Accumulator: true},
+			{Index: 1, Result: false, Logic: LogicOr, Accumulator: true},
+			{Index: 2, Result: false, Logic: LogicAnd, Accumulator: false},
+			{Index: 3, Result: true, Logic: LogicAnd, Accumulator: false, Group: &GroupTrace{
+				Result: true,
+				Steps: []GroupStep{
+					{Index: 0, Result: false, Logic: "", Accumulator: false},
+					{Index: 1, Result: true, Logic: LogicOr, Accumulator: true},
+				},
+			}},
+		},
+	}
+	if !reflect.DeepEqual(trace, want) {
+		t.Errorf("EvaluateConditionGroupTrace() = %+v, want %+v", trace, want)
+	}
+	if trace.Result != EvaluateConditionGroup(group, data) {
+		t.Error("trace result differs from EvaluateConditionGroup")
+	}
+
+	// With precedence the accumulator is A OR (B AND C AND D)
+	e := NewEvaluator()
+	e.HonorPrecedence = true
+	var accumulators []bool
+	for _, step := range e.EvaluateConditionGroupTrace(group, data).Steps {
+		accumulators = append(accumulators, step.Accumulator)
+	}
+	if want := []bool{true, true, true, true}; !reflect.DeepEqual(accumulators, want) {
+		t.Errorf("accumulators with precedence = %v, want %v", accumulators, want)
+	}
+}
+
+func TestEvaluateConditionGroupTrace_EdgeCases(t *testing.T) {
+	if trace := EvaluateConditionGroupTrace(ConditionGroup{}, nil); !trace.Result || len(trace.Steps) != 0 {
+		t.Errorf("empty group trace = %+v, want true with no steps", trace)
+	}
+
+	// Unknown logic is applied as AND
+	group := NewConditionGroup(
+		NewConditionWithLogic("a", OperatorIsTrue, nil, "XOR"),
+		NewConditionWithLogic("b", OperatorIsTrue, nil, ""),
+	)
+	trace := EvaluateConditionGroupTrace(group, map[string]interface{}{"a": true, "b": false})
+	if trace.Result || trace.Steps[1].Logic != LogicAnd || trace.Steps[1].Accumulator {
+		t.Errorf("unknown logic trace = %+v, want AND giving false", trace)
+	}
+}
+
+func TestEvaluateConditionGroupTrace_Names(t *testing.T) {
+	var cond Conditions
+	if err := json.Unmarshal([]byte(`{
+		"logic": "AND",
+		"name": "Eligibility",
+		"children": [
+			{"key": "age", "operator": ">=", "value": 18, "name": "Applicant must be an adult", "id": "adult"},
+			{"logic": "OR", "name": "Supported region", "id": "region", "children": [
+				{"key": "country", "operator": "==", "value": "TH", "name": "Lives in Thailand"},
+				{"key": "country", "operator": "==", "value": "SG", "id": "sg"}
+			]}
+		]
+	}`), &cond); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	data := map[string]interface{}{"age": 16, "country": "SG"}
+	if EvaluateCondition(cond, data) != EvaluateCondition(Conditions{Logic: LogicAnd, Children: []Conditions{
+		NewSimpleCondition("age", OperatorGte, 18),
+		NewOrGroup(NewSimpleCondition("country", OperatorEq, "TH"), NewSimpleCondition("country", OperatorEq, "SG")),
+	}}, data) {
+		t.Error("names changed the result of the evaluation")
+	}
+
+	trace := EvaluateConditionGroupTrace(ConvertToConditionGroup(cond), data)
+	if trace.Result {
+		t.Fatal("expected a minor to fail eligibility")
+	}
+	var failing []string
+	for _, step := range trace.Steps {
+		if !step.Result {
+			failing = append(failing, step.Name)
+		}
+	}
+	if want := []string{"Applicant must be an adult"}; !reflect.DeepEqual(failing, want) {
+		t.Errorf("failing steps = %v, want %v", failing, want)
+	}
+
+	labels := func(steps []GroupStep) [][2]string {
+		var out [][2]string
+		for _, step := range steps {
+			out = append(out, [2]string{step.Name, step.ID})
+		}
+		return out
+	}
+	if got, want := labels(trace.Steps), [][2]string{{"Applicant must be an adult", "adult"}, {"Supported region", "region"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("step labels = %v, want %v", got, want)
+	}
+	if got, want := labels(trace.Steps[1].Group.Steps), [][2]string{{"Lives in Thailand", ""}, {"", "sg"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("nested step labels = %v, want %v", got, want)
+	}
+
+	// NOT and IMPLIES groups keep their labels, at the top level and nested
+	adult := NewSimpleCondition("age", OperatorGte, 18)
+	negated := Negate(adult)
+	negated.Name, negated.ID = "Minor", "minor"
+	implies := NewImpliesGroup(NewSimpleCondition("country", OperatorEq, "SG"), adult)
+	implies.Name, implies.ID = "Adults in Singapore", "sg_adult"
+	for _, tt := range []struct {
+		cond Conditions
+		want [][2]string
+	}{
+		{negated, [][2]string{{"Minor", "minor"}}},
+		{implies, [][2]string{{"Adults in Singapore", "sg_adult"}}},
+		{NewOrGroup(negated, implies), [][2]string{{"Minor", "minor"}, {"Adults in Singapore", "sg_adult"}}},
+	} {
+		trace := EvaluateConditionGroupTrace(ConvertToConditionGroup(tt.cond), data)
+		if got := labels(trace.Steps); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("step labels of converted %s group = %v, want %v", tt.cond.Logic, got, tt.want)
+		}
+		if trace.Result != EvaluateCondition(tt.cond, data) {
+			t.Errorf("converted %s group = %v, want %v", tt.cond.Logic, trace.Result, !trace.Result)
+		}
+	}
+
+	// Names written directly on a ConditionGroup are traced too
+	group := NewConditionGroup(ConditionWithLogic{Key: "age", Operator: OperatorLt, Value: 65, Name: "Under retirement age", ID: "age"})
+	if step := EvaluateConditionGroupTrace(group, data).Steps[0]; step.Name != "Under retirement age" || step.ID != "age" || !step.Result {
+		t.Errorf("step = %+v, want the condition's name and ID", step)
+	}
+
+	if err := ValidateConditions(cond); err != nil {
+		t.Errorf("ValidateConditions() = %v, want nil", err)
+	}
+	unnamed := cond.Clone()
+	unnamed.Name, unnamed.Children[0].ID = "", ""
+	if !cond.Equal(unnamed) {
+		t.Error("expected Equal to ignore names and IDs")
+	}
+}
@@ -0,0 +1,410 @@
+package jsonvaluate
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// ReferencedKeys returns the sorted, de-duplicated data keys used by a
+// condition tree, including keys of conditions nested inside groups, the
+// basis keys of pct_between conditions and the keys of FieldRefs in Value or
+// Default, at any depth. It can be used to fetch
+// only the fields a rule needs, or to check they all exist before evaluating.
+func ReferencedKeys(cond Conditions) []string {
+	keys := make(map[string]bool)
+	collectKeys(cond, keys)
+	return sortedKeys(keys)
+}
+
+// ReferencedGroupKeys is like ReferencedKeys for a ConditionGroup.
+func ReferencedGroupKeys(group ConditionGroup) []string {
+	keys := make(map[string]bool)
+	collectGroupKeys(group, keys)
+	return sortedKeys(keys)
+}
+
+// collectKeys adds the keys referenced by cond to keys
+func collectKeys(cond Conditions, keys map[string]bool) {
+	if cond.Key != "" {
+		keys[cond.Key] = true
+	}
+	collectOperatorKeys(cond.Operator, cond.Value, keys)
+	collectValueKeys(cond.Default, keys)
+	for _, child := range cond.Children {
+		collectKeys(child, keys)
+	}
+}
+
+// collectGroupKeys adds the keys referenced by group to keys
+func collectGroupKeys(group ConditionGroup, keys map[string]bool) {
+	for _, condition := range group.Conditions {
+		if condition.Key != "" {
+			keys[condition.Key] = true
+		}
+		collectOperatorKeys(condition.Operator, condition.Value, keys)
+		collectValueKeys(condition.Default, keys)
+		if condition.Group != nil {
+			collectGroupKeys(*condition.Group, keys)
+		}
+	}
+}
+
+// collectOperatorKeys adds the keys read by operator op with the given value
+// to keys: the basis key of pct_between and the keys of FieldRefs in value
+func collectOperatorKeys(op Operator, value interface{}, keys map[string]bool) {
+	if op == OperatorPctBetween {
+		sv := reflect.ValueOf(value)
+		if (sv.Kind() == reflect.Slice || sv.Kind() == reflect.Array) && sv.Len() == 3 {
+			if key, ok := sv.Index(0).Interface().(string); ok {
+				keys[key] = true
+			}
+		}
+	}
+	collectValueKeys(value, keys)
+}
+
+// collectValueKeys adds the keys of the FieldRefs in a condition value to
+// keys, looking inside pointers, slices, maps and any_op alternatives
+func collectValueKeys(v interface{}, keys map[string]bool) {
+	switch val := v.(type) {
+	case nil:
+		return
+	case FieldRef:
+		keys[string(val)] = true
+		return
+	case OperatorValue:
+		collectOperatorKeys(val.Operator, val.Value, keys)
+		return
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if !rv.IsNil() {
+			collectValueKeys(rv.Elem().Interface(), keys)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			collectValueKeys(rv.Index(i).Interface(), keys)
+		}
+	case reflect.Map:
+		iter := rv.MapRange()
+		for iter.Next() {
+			collectValueKeys(iter.Value().Interface(), keys)
+		}
+	}
+}
+
+// sortedKeys returns the keys of a set in sorted order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Clone returns a deep copy of the condition tree. Children are copied
+// recursively, and slices and maps held in Value or Default are copied so the
+// clone can be modified without affecting the original, including those in
+// exported fields of structs such as Schema, Schedule and OperatorValue. Other
+// reference types (pointers, channels, functions) are shared, as are
+// unexported struct fields. Custom operators live in the
+// registry rather than in the tree, so they are unaffected.
+func (c Conditions) Clone() Conditions {
+	clone := c
+	clone.Value = cloneValue(c.Value)
+	clone.Default = cloneValue(c.Default)
+	if c.Children != nil {
+		clone.Children = make([]Conditions, len(c.Children))
+		for i, child := range c.Children {
+			clone.Children[i] = child.Clone()
+		}
+	}
+	return clone
+}
+
+// Equal reports whether c and other describe the same rule, ignoring Name and
+// ID. Logic, Key, Operator and Threshold must match exactly, and Value and
+// Default are compared as JSON values, as by the json_eq operator: map keys
+// may be in any order and numbers of different types are equal when their
+// values are, so a tree equals itself after a JSON round trip that turned
+// int(5) into float64(5). A time.Duration or time.Time, at any depth, only
+// equals another of the same type and value, not the number or string it
+// encodes to, since operators such as within treat them differently. Likewise
+// a FieldRef, ParamRef or NowRef only equals a reference of the same type to
+// the same name, never the literal string it holds.
+// Children are compared in order, since order decides short-circuiting and
+// ConditionGroup chaining; trees whose children are merely permuted are not
+// equal.
+func (c Conditions) Equal(other Conditions) bool {
+	if c.Logic != other.Logic || c.Threshold != other.Threshold ||
+		c.Key != other.Key || c.Operator != other.Operator ||
+		len(c.Children) != len(other.Children) {
+		return false
+	}
+	if !valueEqual(c.Value, other.Value) || !valueEqual(c.Default, other.Default) {
+		return false
+	}
+	for i := range c.Children {
+		if !c.Children[i].Equal(other.Children[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// valueEqual compares condition values as JSON values, as jsonEqual does,
+// except that durations, times and references only equal values of the same
+// type
+func valueEqual(a, b interface{}) bool {
+	a, b = operatorValueMap(deref(a)), operatorValueMap(deref(b))
+	switch av := a.(type) {
+	case FieldRef:
+		bv, ok := b.(FieldRef)
+		return ok && av == bv
+	case ParamRef:
+		bv, ok := b.(ParamRef)
+		return ok && av == bv
+	case NowRef:
+		_, ok := b.(NowRef)
+		return ok
+	case time.Duration:
+		bv, ok := b.(time.Duration)
+		return ok && av == bv
+	case time.Time:
+		bv, ok := b.(time.Time)
+		return ok && av.Equal(bv)
+	}
+	switch b.(type) {
+	case FieldRef, ParamRef, NowRef, time.Duration, time.Time:
+		return false
+	}
+
+	// Compare lists and objects element by element, so that durations and
+	// times nested in them are compared by type too
+	ja, okA := toJSONValue(a)
+	jb, okB := toJSONValue(b)
+	if !okA || !okB {
+		return false
+	}
+	switch av := ja.(type) {
+	case []interface{}:
+		bv, ok := jb.([]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !valueEqual(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		bv, ok := jb.(map[string]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for key, value := range av {
+			other, exists := bv[key]
+			if !exists || !valueEqual(value, other) {
+				return false
+			}
+		}
+		return true
+	}
+	return jsonEqual(ja, jb)
+}
+
+// operatorValueMap converts an OperatorValue to the object it encodes to, so
+// the references in its Value are compared by valueEqual rather than through
+// their JSON encoding. Other values are returned unchanged.
+func operatorValueMap(v interface{}) interface{} {
+	alt, ok := v.(OperatorValue)
+	if !ok {
+		return v
+	}
+	m := map[string]interface{}{"operator": string(alt.Operator)}
+	if alt.Value != nil {
+		m["value"] = alt.Value
+	}
+	return m
+}
+
+// Normalize returns a canonical form of a condition tree, so that rules
+// written differently but meaning the same thing can be deduplicated or used
+// as cache keys. It
+//   - flattens groups nested in a group with the same logic, so
+//     AND(AND(a, b), c) becomes AND(a, b, c); empty AND groups inside AND
+//     groups and empty OR groups inside OR groups disappear this way
+//   - replaces AND and OR groups with a single child by that child
+//   - sorts the children of AND, OR and ATLEAST groups by their JSON encoding
+//   - converts numbers in Value and Default, other than time.Duration, to
+//     int64 when they are whole numbers that fit and to float64 otherwise,
+//     and slices and maps with string keys to []interface{} and
+//     map[string]interface{}
+//
+// Empty groups elsewhere are kept, since an empty AND group is true and an
+// empty OR group false. The Name and ID of a group that is flattened or
+// replaced by its child are dropped. Sorting children changes the order in
+// which they are evaluated, so it only preserves results for operators without
+// side effects; which error EvaluateConditionE reports first may differ. The
+// input is not modified.
+func Normalize(cond Conditions) Conditions {
+	normalized := cond
+	normalized.Value = normalizeValue(cond.Value)
+	normalized.Default = normalizeValue(cond.Default)
+	if cond.Children == nil {
+		return normalized
+	}
+
+	normalized.Children = make([]Conditions, 0, len(cond.Children))
+	for _, child := range cond.Children {
+		child = Normalize(child)
+		if (cond.Logic == LogicAnd || cond.Logic == LogicOr) && child.Logic == cond.Logic {
+			normalized.Children = append(normalized.Children, child.Children...)
+		} else {
+			normalized.Children = append(normalized.Children, child)
+		}
+	}
+
+	switch cond.Logic {
+	case LogicAnd, LogicOr:
+		if len(normalized.Children) == 1 {
+			return normalized.Children[0]
+		}
+		sortConditions(normalized.Children)
+	case LogicAtLeast:
+		sortConditions(normalized.Children)
+	}
+	return normalized
+}
+
+// sortConditions sorts conditions by their JSON encoding, falling back to
+// their printed form for values that can't be encoded
+func sortConditions(conditions []Conditions) {
+	type keyed struct {
+		key  string
+		cond Conditions
+	}
+	sorted := make([]keyed, len(conditions))
+	for i, c := range conditions {
+		key := fmt.Sprintf("%v", c)
+		if data, err := json.Marshal(c); err == nil {
+			key = string(data)
+		}
+		sorted[i] = keyed{key, c}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].key < sorted[j].key
+	})
+	for i := range sorted {
+		conditions[i] = sorted[i].cond
+	}
+}
+
+// normalizeValue converts numbers to int64 or float64, and slices and maps
+// with string keys to []interface{} and map[string]interface{}, recursively.
+// Other values are returned unchanged.
+func normalizeValue(v interface{}) interface{} {
+	switch v.(type) {
+	case nil, string, bool, []byte, json.RawMessage, time.Duration:
+		return v
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n := rv.Uint(); n <= math.MaxInt64 {
+			return int64(n)
+		}
+		return rv.Uint()
+	case reflect.Float32, reflect.Float64:
+		f, _ := toNumber(v)
+		if f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 {
+			return int64(f)
+		}
+		return f
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return v
+		}
+		s := make([]interface{}, rv.Len())
+		for i := range s {
+			s[i] = normalizeValue(rv.Index(i).Interface())
+		}
+		return s
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String || rv.IsNil() {
+			return v
+		}
+		m := make(map[string]interface{}, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			m[iter.Key().String()] = normalizeValue(iter.Value().Interface())
+		}
+		return m
+	default:
+		return v
+	}
+}
+
+// cloneValue deep-copies slices, maps and the exported fields of structs,
+// returning other values unchanged
+func cloneValue(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	return cloneReflectValue(reflect.ValueOf(v)).Interface()
+}
+
+// cloneReflectValue deep-copies slices, maps, exported struct fields and the
+// values held in interfaces
+func cloneReflectValue(rv reflect.Value) reflect.Value {
+	switch rv.Kind() {
+	case reflect.Slice:
+		if rv.IsNil() {
+			return rv
+		}
+		clone := reflect.MakeSlice(rv.Type(), rv.Len(), rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			clone.Index(i).Set(cloneReflectValue(rv.Index(i)))
+		}
+		return clone
+	case reflect.Map:
+		if rv.IsNil() {
+			return rv
+		}
+		clone := reflect.MakeMapWithSize(rv.Type(), rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			clone.SetMapIndex(iter.Key(), cloneReflectValue(iter.Value()))
+		}
+		return clone
+	case reflect.Interface:
+		if rv.IsNil() {
+			return rv
+		}
+		clone := reflect.New(rv.Type()).Elem()
+		clone.Set(cloneReflectValue(rv.Elem()))
+		return clone
+	case reflect.Struct:
+		clone := reflect.New(rv.Type()).Elem()
+		clone.Set(rv)
+		for i := 0; i < rv.NumField(); i++ {
+			if rv.Type().Field(i).IsExported() {
+				clone.Field(i).Set(cloneReflectValue(rv.Field(i)))
+			}
+		}
+		return clone
+	default:
+		return rv
+	}
+}
@@ -0,0 +1,307 @@
+package jsonvaluate
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReferencedKeys(t *testing.T) {
+	cond := NewAndGroup(
+		NewSimpleCondition("sum_insured", OperatorGte, 200000),
+		NewOrGroup(
+			NewSimpleCondition("amount", OperatorGte, 100000),
+			NewSimpleCondition("amount", OperatorLte, 1000000),
+			Negate(NewSimpleCondition("country", OperatorIn, []interface{}{"TH", "SG"})),
+		),
+		Conditions{},
+		NewSimpleCondition("age", OperatorIsnotnull, nil),
+	)
+
+	expected := []string{"age", "amount", "country", "sum_insured"}
+	if keys := ReferencedKeys(cond); !reflect.DeepEqual(keys, expected) {
+		t.Errorf("ReferencedKeys() = %v, want %v", keys, expected)
+	}
+	if keys := ReferencedGroupKeys(ConvertToConditionGroup(cond)); !reflect.DeepEqual(keys, expected) {
+		t.Errorf("ReferencedGroupKeys() = %v, want %v", keys, expected)
+	}
+
+	if keys := ReferencedKeys(Conditions{}); len(keys) != 0 {
+		t.Errorf("Empty condition should reference no keys, got %v", keys)
+	}
+}
+
+func TestReferencedKeys_FieldRefs(t *testing.T) {
+	cond := NewAndGroup(
+		NewSimpleCondition("a", OperatorEq, Ref("b")),
+		NewSimpleCondition("id", OperatorIn, Ref("ids")),
+		NewSimpleCondition("tier", OperatorIn, []interface{}{"gold", Ref("default_tier")}),
+		Conditions{Key: "score", Operator: OperatorGte, Value: 10, Default: Ref("base_score")},
+		NewSimpleCondition("status", OperatorAnyOp, []OperatorValue{{Operator: OperatorEq, Value: Ref("previous_status")}}),
+		NewSimpleCondition("region", OperatorEq, ParamRef("region")),
+		NewSimpleCondition("claim", OperatorPctBetween, []interface{}{"sum_insured", 10, 90}),
+		NewSimpleCondition("deductible", OperatorPctBetween, []interface{}{Ref("premium"), nil, 5}),
+		NewSimpleCondition("fee", OperatorAnyOp, []OperatorValue{{Operator: OperatorPctBetween, Value: []interface{}{"price", 0, 3}}}),
+	)
+
+	expected := []string{"a", "b", "base_score", "claim", "deductible", "default_tier", "fee", "id", "ids", "premium", "previous_status", "price", "region", "score", "status", "sum_insured", "tier"}
+	if keys := ReferencedKeys(cond); !reflect.DeepEqual(keys, expected) {
+		t.Errorf("ReferencedKeys() = %v, want %v", keys, expected)
+	}
+	if keys := ReferencedGroupKeys(ConvertToConditionGroup(cond)); !reflect.DeepEqual(keys, expected) {
+		t.Errorf("ReferencedGroupKeys() = %v, want %v", keys, expected)
+	}
+}
+
+func TestConditionsClone(t *testing.T) {
+	original := NewAndGroup(
+		NewSimpleCondition("country", OperatorIn, []interface{}{"TH", "SG"}),
+		NewOrGroup(
+			NewSimpleCondition("tier", OperatorIn, map[string]interface{}{"gold": []string{"a"}}),
+			Conditions{Key: "score", Operator: OperatorGte, Value: 10, Default: []int{1}},
+		),
+	)
+	snapshot := NewAndGroup(
+		NewSimpleCondition("country", OperatorIn, []interface{}{"TH", "SG"}),
+		NewOrGroup(
+			NewSimpleCondition("tier", OperatorIn, map[string]interface{}{"gold": []string{"a"}}),
+			Conditions{Key: "score", Operator: OperatorGte, Value: 10, Default: []int{1}},
+		),
+	)
+
+	clone := original.Clone()
+	if !reflect.DeepEqual(clone, original) {
+		t.Fatal("Clone should equal the original")
+	}
+
+	// Mutate every level of the clone
+	clone.Logic = LogicOr
+	clone.Children[0].Value.([]interface{})[0] = "MY"
+	clone.Children[1].Children[0].Value.(map[string]interface{})["gold"].([]string)[0] = "b"
+	clone.Children[1].Children[0].Value.(map[string]interface{})["silver"] = nil
+	clone.Children[1].Children[1].Default.([]int)[0] = 2
+	clone.Children[1].Children = append(clone.Children[1].Children, Conditions{})
+	clone.Children[1].Children[1].Key = "other"
+
+	if !reflect.DeepEqual(original, snapshot) {
+		t.Errorf("Mutating the clone changed the original: %+v", original)
+	}
+}
+
+func TestConditionsClone_StructValues(t *testing.T) {
+	original := NewAndGroup(
+		NewSimpleCondition("address", OperatorMatchesSchema, Schema{Required: []string{"zip"}, Properties: map[string]interface{}{"zip": "string"}}),
+		NewSimpleCondition("at", OperatorInSchedule, Schedule{Days: []time.Weekday{time.Monday}, Start: "09:00"}),
+		NewSimpleCondition("status", OperatorAnyOp, []OperatorValue{{Operator: OperatorIn, Value: []string{"new"}}}),
+	)
+	snapshot := original.Clone()
+
+	clone := original.Clone()
+	clone.Children[0].Value.(Schema).Required[0] = "street"
+	clone.Children[0].Value.(Schema).Properties["zip"] = "number"
+	clone.Children[1].Value.(Schedule).Days[0] = time.Sunday
+	clone.Children[2].Value.([]OperatorValue)[0].Value.([]string)[0] = "old"
+
+	if !reflect.DeepEqual(original, snapshot) {
+		t.Errorf("Mutating struct values in the clone changed the original: %+v", original)
+	}
+}
+
+func TestConditionsEqual(t *testing.T) {
+	literal := NewAndGroup(
+		NewSimpleCondition("age", OperatorGte, 18),
+		NewSimpleCondition("country", OperatorIn, []string{"TH", "SG"}),
+		NewAtLeastGroup(1,
+			NewSimpleCondition("score", OperatorBetween, map[string]interface{}{"min": 1, "max": 10}),
+			Conditions{Key: "tier", Operator: OperatorEq, Value: "gold", Default: 0},
+		),
+	)
+
+	data, err := json.Marshal(literal)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var roundTripped Conditions
+	if err := json.Unmarshal(data, &roundTripped); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if reflect.DeepEqual(literal, roundTripped) {
+		t.Fatal("expected the round trip to change value types")
+	}
+	if !literal.Equal(roundTripped) || !roundTripped.Equal(literal) {
+		t.Error("expected JSON round-tripped tree to equal the literal tree")
+	}
+	if !literal.Equal(literal.Clone()) {
+		t.Error("expected clone to equal the original")
+	}
+
+	tests := []struct {
+		name  string
+		other Conditions
+	}{
+		{"different logic", Conditions{Logic: LogicOr, Children: literal.Children}},
+		{"children reordered", NewAndGroup(literal.Children[1], literal.Children[0], literal.Children[2])},
+		{"missing child", NewAndGroup(literal.Children[:2]...)},
+		{"different value", NewAndGroup(NewSimpleCondition("age", OperatorGte, 21), literal.Children[1], literal.Children[2])},
+		{"string instead of number", NewAndGroup(NewSimpleCondition("age", OperatorGte, "18"), literal.Children[1], literal.Children[2])},
+		{"different operator", NewAndGroup(NewSimpleCondition("age", OperatorGt, 18), literal.Children[1], literal.Children[2])},
+		{"different key", NewAndGroup(NewSimpleCondition("Age", OperatorGte, 18), literal.Children[1], literal.Children[2])},
+		{"different threshold", NewAndGroup(literal.Children[0], literal.Children[1], NewAtLeastGroup(2, literal.Children[2].Children...))},
+		{"different default", NewAndGroup(literal.Children[0], literal.Children[1], NewAtLeastGroup(1,
+			literal.Children[2].Children[0],
+			Conditions{Key: "tier", Operator: OperatorEq, Value: "gold"},
+		))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if literal.Equal(tt.other) {
+				t.Errorf("expected trees to differ")
+			}
+		})
+	}
+}
+
+func TestConditionsEqual_References(t *testing.T) {
+	tests := []struct {
+		name   string
+		a, b   interface{}
+		expect bool
+	}{
+		{"same field ref", Ref("ids"), Ref("ids"), true},
+		{"different field refs", Ref("ids"), Ref("other_ids"), false},
+		{"field ref and literal", Ref("ids"), "ids", false},
+		{"literal and field ref", "ids", Ref("ids"), false},
+		{"param ref and literal", ParamRef("x"), "x", false},
+		{"param ref and field ref", ParamRef("x"), Ref("x"), false},
+		{"now refs", Now(), Now(), true},
+		{"now ref and empty object", Now(), map[string]interface{}{}, false},
+		{"field ref in list and literal", []interface{}{"a", Ref("b")}, []interface{}{"a", "b"}, false},
+		{"field refs in lists", []interface{}{"a", Ref("b")}, []interface{}{"a", Ref("b")}, true},
+		{"field ref in alternative and literal", []OperatorValue{{Operator: OperatorEq, Value: Ref("b")}}, []OperatorValue{{Operator: OperatorEq, Value: "b"}}, false},
+		{"alternative and decoded object", []OperatorValue{{Operator: OperatorEq, Value: "b"}}, []interface{}{map[string]interface{}{"operator": "==", "value": "b"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewSimpleCondition("x", OperatorEq, tt.a)
+			b := NewSimpleCondition("x", OperatorEq, tt.b)
+			if got := a.Equal(b); got != tt.expect {
+				t.Errorf("Equal() = %v, want %v", got, tt.expect)
+			}
+		})
+	}
+}
+
+func TestConditionsEqual_TimeValues(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		a, b   interface{}
+		expect bool
+	}{
+		{"same duration", time.Hour, 60 * time.Minute, true},
+		{"duration and nanoseconds", time.Hour, int64(time.Hour), false},
+		{"nanoseconds and duration", int64(time.Hour), time.Hour, false},
+		{"durations in lists", []interface{}{time.Hour, 2 * time.Hour}, []time.Duration{time.Hour, 2 * time.Hour}, true},
+		{"duration in list and number", []interface{}{time.Hour}, []interface{}{float64(time.Hour)}, false},
+		{"same instant", at, at.In(time.FixedZone("ICT", 7*3600)), true},
+		{"time and string", at, at.Format(time.RFC3339), false},
+		{"string and time", at.Format(time.RFC3339), at, false},
+		{"time in map and string", map[string]interface{}{"from": at}, map[string]interface{}{"from": at.Format(time.RFC3339)}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewSimpleCondition("x", OperatorWithin, tt.a)
+			b := NewSimpleCondition("x", OperatorWithin, tt.b)
+			if got := a.Equal(b); got != tt.expect {
+				t.Errorf("Equal() = %v, want %v", got, tt.expect)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	a := NewSimpleCondition("a", OperatorEq, 1)
+	b := NewSimpleCondition("b", OperatorEq, 2)
+	c := NewSimpleCondition("c", OperatorEq, 3)
+
+	tests := []struct {
+		name   string
+		input  Conditions
+		expect Conditions
+	}{
+		{"flattens nested AND", NewAndGroup(NewAndGroup(a, b), c), NewAndGroup(a, b, c)},
+		{"flattens nested OR", NewOrGroup(c, NewOrGroup(b, NewOrGroup(a))), NewOrGroup(a, b, c)},
+		{"keeps mixed logic", NewAndGroup(NewOrGroup(b, a), c), NewAndGroup(NewOrGroup(a, b), c)},
+		{"sorts children", NewAndGroup(c, a, b), NewAndGroup(a, b, c)},
+		{"sorts ATLEAST children", NewAtLeastGroup(2, c, b, a), NewAtLeastGroup(2, a, b, c)},
+		{"drops neutral empty groups", NewAndGroup(a, NewAndGroup(), b), NewAndGroup(a, b)},
+		{"keeps other empty groups", NewOrGroup(a, NewAndGroup()), NewOrGroup(NewAndGroup(), a)},
+		{"collapses single child", NewAndGroup(NewOrGroup(a)), a},
+		{"keeps NOT", Conditions{Logic: LogicNot, Children: []Conditions{NewAndGroup(a)}}, Conditions{Logic: LogicNot, Children: []Conditions{a}}},
+		{"normalizes numbers", NewSimpleCondition("x", OperatorIn, []float64{1.0, 2.5}), NewSimpleCondition("x", OperatorIn, []interface{}{int64(1), 2.5})},
+		{"normalizes maps", NewSimpleCondition("x", OperatorBetween, map[string]int{"min": 1, "max": 9}), NewSimpleCondition("x", OperatorBetween, map[string]interface{}{"min": int64(1), "max": int64(9)})},
+		{"normalizes defaults", Conditions{Key: "x", Operator: OperatorEq, Value: float32(0.5), Default: uint8(3)}, Conditions{Key: "x", Operator: OperatorEq, Value: 0.5, Default: int64(3)}},
+		{"keeps durations", NewSimpleCondition("x", OperatorGt, 30*time.Minute), NewSimpleCondition("x", OperatorGt, 30*time.Minute)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, want := Normalize(tt.input), Normalize(tt.expect); !reflect.DeepEqual(got, want) {
+				t.Errorf("Normalize() = %+v, want %+v", got, want)
+			}
+		})
+	}
+
+	// Children are ordered by their JSON encoding
+	sorted := Normalize(NewOrGroup(c, a, b))
+	if keys := []string{sorted.Children[0].Key, sorted.Children[1].Key, sorted.Children[2].Key}; !reflect.DeepEqual(keys, []string{"a", "b", "c"}) {
+		t.Errorf("sorted keys = %v, want [a b c]", keys)
+	}
+}
+
+func TestNormalize_SameRuleWrittenDifferently(t *testing.T) {
+	written := NewAndGroup(
+		NewAndGroup(
+			NewSimpleCondition("country", OperatorIn, []string{"TH", "SG"}),
+			NewSimpleCondition("age", OperatorGte, 18),
+		),
+		NewOrGroup(NewSimpleCondition("score", OperatorGt, 700)),
+	)
+	var decoded Conditions
+	if err := json.Unmarshal([]byte(`{"logic": "AND", "children": [
+		{"key": "score", "operator": ">", "value": 700.0},
+		{"key": "age", "operator": ">=", "value": 18},
+		{"key": "country", "operator": "in", "value": ["TH", "SG"]}
+	]}`), &decoded); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	if got, want := Normalize(written), Normalize(decoded); !reflect.DeepEqual(got, want) {
+		t.Errorf("Normalize() differ:\n%+v\n%+v", got, want)
+	}
+
+	// Normalizing is idempotent and doesn't change results
+	normalized := Normalize(written)
+	if !reflect.DeepEqual(Normalize(normalized), normalized) {
+		t.Error("Normalize should be idempotent")
+	}
+	for _, data := range []map[string]interface{}{
+		{"country": "TH", "age": 20, "score": 720},
+		{"country": "TH", "age": 20, "score": 650},
+		{"country": "US", "age": 20, "score": 720},
+	} {
+		if EvaluateCondition(written, data) != EvaluateCondition(normalized, data) {
+			t.Errorf("results differ for %v", data)
+		}
+	}
+
+	// The input is not modified
+	if len(written.Children) != 2 || written.Children[0].Logic != LogicAnd {
+		t.Errorf("input was modified: %+v", written)
+	}
+}
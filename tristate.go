@@ -0,0 +1,167 @@
+package jsonvaluate
+
+// TriState is the result of a three-valued evaluation: true, false, or
+// unknown when the data needed to decide isn't present yet.
+type TriState int
+
+// TriState values. The zero value is TriStateUnknown.
+const (
+	TriStateUnknown TriState = iota
+	TriStateFalse
+	TriStateTrue
+)
+
+// String returns "true", "false" or "unknown".
+func (s TriState) String() string {
+	switch s {
+	case TriStateTrue:
+		return "true"
+	case TriStateFalse:
+		return "false"
+	default:
+		return "unknown"
+	}
+}
+
+// not negates s, leaving unknown unchanged.
+func (s TriState) not() TriState {
+	switch s {
+	case TriStateTrue:
+		return TriStateFalse
+	case TriStateFalse:
+		return TriStateTrue
+	default:
+		return TriStateUnknown
+	}
+}
+
+// triState converts a definite result to a TriState.
+func triState(b bool) TriState {
+	if b {
+		return TriStateTrue
+	}
+	return TriStateFalse
+}
+
+// EvaluateTriState evaluates a condition tree against partial data using
+// three-valued (Kleene) logic, so a decision can be deferred until the data
+// it depends on arrives.
+//
+// A single condition whose key is missing from the data, and that has no
+// Default, is unknown whatever its operator. Groups propagate unknown only
+// when it matters: an AND group with a false child is false and an OR group
+// with a true child is true even if other children are unknown, while a NOT
+// of unknown is unknown. An IMPLIES group is true when its antecedent is
+// false or its consequent true, and false only when both are known. An
+// ATLEAST group is true once Threshold children are true and false once too
+// few can still become true. Conditions that fail with an error are false,
+// as with EvaluateCondition.
+//
+// Example:
+//
+//	cond := NewAndGroup(
+//	    NewSimpleCondition("country", OperatorEq, "TH"),
+//	    NewSimpleCondition("score", OperatorGte, 700),
+//	)
+//	EvaluateTriState(cond, map[string]interface{}{"country": "TH"}) // TriStateUnknown
+//	EvaluateTriState(cond, map[string]interface{}{"country": "US"}) // TriStateFalse
+func EvaluateTriState(cond Conditions, data map[string]interface{}) TriState {
+	return defaultEvaluator.EvaluateTriState(cond, data)
+}
+
+// EvaluateTriState evaluates a condition tree against partial data using
+// three-valued logic. See the package-level EvaluateTriState for details.
+func (e *Evaluator) EvaluateTriState(cond Conditions, data map[string]interface{}) TriState {
+	return e.newEvaluation(data, false).evalTriState(cond)
+}
+
+// evalTriState walks the condition tree like evalCondition, treating
+// conditions on missing keys as unknown.
+func (ev *evaluation) evalTriState(cond Conditions) TriState {
+	if cond.Logic != "" {
+		if err := ev.enterGroup(); err != nil {
+			return TriStateFalse
+		}
+		defer ev.leaveGroup()
+
+		switch cond.Logic {
+		case LogicAnd:
+			return ev.triStateAll(cond.Children)
+		case LogicOr:
+			result := TriStateFalse
+			for _, child := range cond.Children {
+				switch ev.evalTriState(child) {
+				case TriStateTrue:
+					return TriStateTrue
+				case TriStateUnknown:
+					result = TriStateUnknown
+				}
+			}
+			return result
+		case LogicNot:
+			// NOT negates the conjunction of its children, as in evalCondition
+			return ev.triStateAll(cond.Children).not()
+		case LogicImplies:
+			if len(cond.Children) != 2 {
+				return TriStateFalse
+			}
+			// NOT antecedent OR consequent
+			antecedent := ev.evalTriState(cond.Children[0])
+			if antecedent == TriStateFalse {
+				return TriStateTrue
+			}
+			consequent := ev.evalTriState(cond.Children[1])
+			if antecedent == TriStateTrue || consequent == TriStateTrue {
+				return consequent
+			}
+			return TriStateUnknown
+		case LogicAtLeast:
+			passed, unknown := 0, 0
+			for i, child := range cond.Children {
+				if passed >= cond.Threshold || passed+unknown+len(cond.Children)-i < cond.Threshold {
+					break
+				}
+				switch ev.evalTriState(child) {
+				case TriStateTrue:
+					passed++
+				case TriStateUnknown:
+					unknown++
+				}
+			}
+			switch {
+			case passed >= cond.Threshold:
+				return TriStateTrue
+			case passed+unknown >= cond.Threshold:
+				return TriStateUnknown
+			default:
+				return TriStateFalse
+			}
+		default:
+			return TriStateFalse
+		}
+	}
+
+	if cond.Key != "" && cond.Operator != "" {
+		if _, exists := ev.lookup(cond.Key); !exists && cond.Default == nil {
+			return TriStateUnknown
+		}
+		result, err := ev.evalSingle(cond.Key, cond.Operator, cond.Value, cond.Default)
+		return triState(err == nil && result)
+	}
+
+	return triState(ev.EmptyResult)
+}
+
+// triStateAll returns the three-valued conjunction of children.
+func (ev *evaluation) triStateAll(children []Conditions) TriState {
+	result := TriStateTrue
+	for _, child := range children {
+		switch ev.evalTriState(child) {
+		case TriStateFalse:
+			return TriStateFalse
+		case TriStateUnknown:
+			result = TriStateUnknown
+		}
+	}
+	return result
+}
@@ -0,0 +1,80 @@
+package jsonvaluate
+
+import "testing"
+
+func TestEvaluateTriState(t *testing.T) {
+	isTH := NewSimpleCondition("country", OperatorEq, "TH")
+	highScore := NewSimpleCondition("score", OperatorGte, 700)
+	notBanned := Conditions{Logic: LogicNot, Children: []Conditions{NewSimpleCondition("banned", OperatorIsTrue, nil)}}
+
+	tests := []struct {
+		name   string
+		cond   Conditions
+		data   map[string]interface{}
+		expect TriState
+	}{
+		{"leaf true", isTH, map[string]interface{}{"country": "TH"}, TriStateTrue},
+		{"leaf false", isTH, map[string]interface{}{"country": "US"}, TriStateFalse},
+		{"leaf missing", isTH, map[string]interface{}{}, TriStateUnknown},
+		{"isnull on missing key", NewSimpleCondition("country", OperatorIsnull, nil), map[string]interface{}{}, TriStateUnknown},
+		{"leaf with default", Conditions{Key: "country", Operator: OperatorEq, Value: "TH", Default: "TH"}, map[string]interface{}{}, TriStateTrue},
+		{"null value is known", NewSimpleCondition("country", OperatorIsnull, nil), map[string]interface{}{"country": nil}, TriStateTrue},
+
+		{"and all true", NewAndGroup(isTH, highScore), map[string]interface{}{"country": "TH", "score": 720}, TriStateTrue},
+		{"and with unknown", NewAndGroup(isTH, highScore), map[string]interface{}{"country": "TH"}, TriStateUnknown},
+		{"and false beats unknown", NewAndGroup(isTH, highScore), map[string]interface{}{"score": 500}, TriStateFalse},
+		{"and false first", NewAndGroup(isTH, highScore), map[string]interface{}{"country": "US"}, TriStateFalse},
+
+		{"or true beats unknown", NewOrGroup(isTH, highScore), map[string]interface{}{"score": 720}, TriStateTrue},
+		{"or with unknown", NewOrGroup(isTH, highScore), map[string]interface{}{"country": "US"}, TriStateUnknown},
+		{"or all false", NewOrGroup(isTH, highScore), map[string]interface{}{"country": "US", "score": 500}, TriStateFalse},
+
+		{"not unknown", notBanned, map[string]interface{}{}, TriStateUnknown},
+		{"not false", notBanned, map[string]interface{}{"banned": false}, TriStateTrue},
+		{"not true", notBanned, map[string]interface{}{"banned": true}, TriStateFalse},
+
+		{"atleast reached", NewAtLeastGroup(2, isTH, highScore, notBanned), map[string]interface{}{"country": "TH", "score": 720}, TriStateTrue},
+		{"atleast undecided", NewAtLeastGroup(2, isTH, highScore, notBanned), map[string]interface{}{"country": "TH"}, TriStateUnknown},
+		{"atleast unreachable", NewAtLeastGroup(2, isTH, highScore, notBanned), map[string]interface{}{"country": "US", "score": 500}, TriStateFalse},
+
+		{"nested", NewOrGroup(NewAndGroup(isTH, highScore), notBanned), map[string]interface{}{"country": "US", "banned": false}, TriStateTrue},
+		{"empty", Conditions{}, map[string]interface{}{}, TriStateTrue},
+		{"unknown logic", Conditions{Logic: "XOR", Children: []Conditions{isTH}}, map[string]interface{}{"country": "TH"}, TriStateFalse},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := EvaluateTriState(tt.cond, tt.data); result != tt.expect {
+				t.Errorf("EvaluateTriState() = %v, want %v", result, tt.expect)
+			}
+		})
+	}
+}
+
+func TestEvaluateTriState_AgreesWhenComplete(t *testing.T) {
+	// With every key present, three-valued evaluation matches EvaluateCondition
+	cond := NewOrGroup(
+		NewAndGroup(
+			NewSimpleCondition("age", OperatorGte, 18),
+			NewSimpleCondition("country", OperatorIn, []string{"TH", "SG"}),
+		),
+		NewAtLeastGroup(1, NewSimpleCondition("vip", OperatorIsTrue, nil)),
+	)
+	for _, data := range []map[string]interface{}{
+		{"age": 20, "country": "TH", "vip": false},
+		{"age": 16, "country": "TH", "vip": false},
+		{"age": 16, "country": "US", "vip": true},
+	} {
+		if got, want := EvaluateTriState(cond, data), triState(EvaluateCondition(cond, data)); got != want {
+			t.Errorf("EvaluateTriState(%v) = %v, want %v", data, got, want)
+		}
+	}
+}
+
+func TestTriState_String(t *testing.T) {
+	for state, want := range map[TriState]string{TriStateTrue: "true", TriStateFalse: "false", TriStateUnknown: "unknown"} {
+		if got := state.String(); got != want {
+			t.Errorf("String() = %q, want %q", got, want)
+		}
+	}
+}
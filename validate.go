@@ -0,0 +1,268 @@
+package jsonvaluate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Validation errors returned by ValidateConditions. They are wrapped with the
+// path of the offending node, so use errors.Is to test for them.
+var (
+	ErrMixedNode           = errors.New("node has both group (logic/children) and single condition (key/operator/value) fields")
+	ErrMissingLogic        = errors.New("missing logic")
+	ErrUnknownLogic        = errors.New("unknown logic")
+	ErrIncompleteCondition = errors.New("single condition requires both key and operator")
+	ErrNotArity            = errors.New("NOT group requires exactly one child")
+	ErrImpliesArity        = errors.New("IMPLIES group requires exactly two children")
+	ErrUnknownOperator     = errors.New("unknown operator")
+	ErrMaxDepthExceeded    = errors.New("max depth exceeded")
+	ErrSubmatchCycle       = errors.New("submatch rule refers to itself")
+	ErrInvalidThreshold    = errors.New("threshold requires an ATLEAST group and must not be negative")
+	ErrOperatorNotAllowed  = errors.New("operator not allowed")
+)
+
+// ValidateConditions checks that a condition tree is well formed and that
+// groups are nested no deeper than DefaultMaxDepth.
+//
+// Every node must be exactly one of:
+//   - a group: Logic is "AND" or "OR" with Children, "NOT" with exactly one
+//     child, "IMPLIES" with exactly two, or "ATLEAST" with Children and a
+//     non-negative Threshold; Key, Operator, Value and Default unset, and
+//     Threshold unset unless ATLEAST
+//   - a single condition: Key and Operator set; Logic and Children unset
+//   - empty: all fields unset
+//
+// The Value of a condition using a custom operator registered with a value
+// check, such as with RegisterCustomOperatorWithValidation, must pass the
+// check; a failure is reported as ErrInvalidValue.
+//
+// EvaluateCondition does not reject malformed nodes. When a node has Logic and
+// Children set it is evaluated as a group and its Key, Operator and Value are
+// ignored, so ValidateConditions should be used to catch such mistakes in rules
+// loaded from untrusted or hand-written JSON.
+func ValidateConditions(cond Conditions) error {
+	return defaultEvaluator.ValidateConditions(cond)
+}
+
+// ValidateConditions checks cond like the package-level ValidateConditions,
+// applying the Evaluator's own limits: groups may be nested as deep as
+// MaxDepth rather than DefaultMaxDepth, and, when AllowedOperators is set,
+// every condition must use an allowed operator, reporting
+// ErrOperatorNotAllowed with the path and key of the first condition that
+// doesn't. Operators given inside values, such as the alternatives of "any_op"
+// or the rules of "submatch", are only known when the condition is evaluated,
+// so they are checked then. The package-level validation functions delegate
+// here, so this is where every per-Evaluator limit is enforced.
+func (e *Evaluator) ValidateConditions(cond Conditions) error {
+	if err := validateNode(cond, "root", 0, e.maxDepth()); err != nil {
+		return err
+	}
+	return e.validateAllowedOperators(cond, "root")
+}
+
+// validateAllowedOperators checks that every operator in the tree is allowed,
+// prefixing errors with path
+func (e *Evaluator) validateAllowedOperators(cond Conditions, path string) error {
+	if cond.Operator != "" && !e.allowsOperator(cond.Operator) {
+		return fmt.Errorf("%s: %w %q for key %q", path, ErrOperatorNotAllowed, cond.Operator, cond.Key)
+	}
+	for i, child := range cond.Children {
+		if err := e.validateAllowedOperators(child, fmt.Sprintf("%s.children[%d]", path, i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// allowsOperator reports whether op may be used under AllowedOperators
+func (e *Evaluator) allowsOperator(op Operator) bool {
+	return e.AllowedOperators == nil || e.AllowedOperators[op]
+}
+
+// ParseConditions decodes a JSON condition tree and checks it at load time:
+// the tree must pass ValidateConditions, and every operator must be a built-in
+// or a currently registered custom operator. An unknown operator, such as a
+// typo or a custom operator that was never registered, is reported as
+// ErrUnknownOperator with the path and key of the condition using it, rather
+// than silently evaluating to false.
+//
+// Decoding with json.Unmarshal directly performs no such checks.
+func ParseConditions(data []byte) (Conditions, error) {
+	var cond Conditions
+	if err := json.Unmarshal(data, &cond); err != nil {
+		return Conditions{}, err
+	}
+	if err := ValidateConditions(cond); err != nil {
+		return Conditions{}, err
+	}
+	if err := validateOperators(cond, "root"); err != nil {
+		return Conditions{}, err
+	}
+	return cond, nil
+}
+
+// validateOperators checks that every operator in the tree is known,
+// prefixing errors with path
+func validateOperators(cond Conditions, path string) error {
+	if cond.Operator != "" && !isKnownOperator(cond.Operator) {
+		return fmt.Errorf("%s: %w %q for key %q", path, ErrUnknownOperator, cond.Operator, cond.Key)
+	}
+	for i, child := range cond.Children {
+		if err := validateOperators(child, fmt.Sprintf("%s.children[%d]", path, i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isKnownOperator reports whether op is a built-in or registered custom operator
+func isKnownOperator(op Operator) bool {
+	return IsBuiltinOperator(op) || HasCustomOperator(op)
+}
+
+// validateNode validates a single node and its children, prefixing errors with
+// path. depth is the number of groups enclosing the node, and groups may be
+// nested maxDepth deep, or without limit when maxDepth is negative.
+func validateNode(cond Conditions, path string, depth, maxDepth int) error {
+	isGroup := cond.Logic != "" || len(cond.Children) > 0 || cond.Threshold != 0
+	isSingle := cond.Key != "" || cond.Operator != "" || cond.Value != nil || cond.Default != nil
+
+	if isGroup && isSingle {
+		return fmt.Errorf("%s: %w", path, ErrMixedNode)
+	}
+
+	if isGroup {
+		if cond.Threshold < 0 || (cond.Threshold != 0 && cond.Logic != LogicAtLeast) {
+			return fmt.Errorf("%s: %w", path, ErrInvalidThreshold)
+		}
+		switch cond.Logic {
+		case LogicAnd, LogicOr, LogicAtLeast:
+		case LogicNot:
+			if len(cond.Children) != 1 {
+				return fmt.Errorf("%s: %w", path, ErrNotArity)
+			}
+		case LogicImplies:
+			if len(cond.Children) != 2 {
+				return fmt.Errorf("%s: %w", path, ErrImpliesArity)
+			}
+		case "":
+			return fmt.Errorf("%s: %w", path, ErrMissingLogic)
+		default:
+			return fmt.Errorf("%s: %w %q", path, ErrUnknownLogic, cond.Logic)
+		}
+
+		if maxDepth > 0 && depth >= maxDepth {
+			return fmt.Errorf("%s: %w (%d)", path, ErrMaxDepthExceeded, maxDepth)
+		}
+		for i, child := range cond.Children {
+			if err := validateNode(child, fmt.Sprintf("%s.children[%d]", path, i), depth+1, maxDepth); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if isSingle && (cond.Key == "" || cond.Operator == "") {
+		return fmt.Errorf("%s: %w", path, ErrIncompleteCondition)
+	}
+	if isSingle {
+		return checkConditionValue(cond.Operator, cond.Value, path)
+	}
+	return nil
+}
+
+// checkConditionValue runs the value check of a custom operator on the value
+// of a condition at path. Parameter and field references and the current
+// time are only resolved when the condition is evaluated, so they are checked
+// then.
+func checkConditionValue(op Operator, value interface{}, path string) error {
+	switch value.(type) {
+	case ParamRef, FieldRef, NowRef:
+		return nil
+	}
+	if err := checkCustomValue(op, value); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// ValidateConditionGroup checks that a ConditionGroup is well formed. Each
+// condition must be either a nested group or a single condition with Key and
+// Operator, and every condition but the last must set NextLogic to "AND" or
+// "OR". EvaluateConditionGroup silently treats a missing NextLogic as AND,
+// which usually hides a mistake, so ValidateConditionGroup reports it as
+// ErrMissingLogic. NextLogic on the last condition is ignored. Groups may be
+// nested no deeper than DefaultMaxDepth, and values are checked as by
+// ValidateConditions.
+func ValidateConditionGroup(group ConditionGroup) error {
+	return defaultEvaluator.ValidateConditionGroup(group)
+}
+
+// ValidateConditionGroup checks group like the package-level
+// ValidateConditionGroup, applying the Evaluator's MaxDepth and
+// AllowedOperators as ValidateConditions does.
+func (e *Evaluator) ValidateConditionGroup(group ConditionGroup) error {
+	if err := validateGroup(group, "root", 0, e.maxDepth()); err != nil {
+		return err
+	}
+	return e.validateAllowedGroupOperators(group, "root")
+}
+
+// validateAllowedGroupOperators checks that every operator in the group and
+// its nested groups is allowed, prefixing errors with path
+func (e *Evaluator) validateAllowedGroupOperators(group ConditionGroup, path string) error {
+	for i, condition := range group.Conditions {
+		condPath := fmt.Sprintf("%s.conditions[%d]", path, i)
+		if condition.Group != nil {
+			if err := e.validateAllowedGroupOperators(*condition.Group, condPath+".group"); err != nil {
+				return err
+			}
+			continue
+		}
+		if !e.allowsOperator(condition.Operator) {
+			return fmt.Errorf("%s: %w %q for key %q", condPath, ErrOperatorNotAllowed, condition.Operator, condition.Key)
+		}
+	}
+	return nil
+}
+
+// validateGroup validates a group and its nested groups, prefixing errors with
+// path. depth is the number of groups enclosing the group, and groups may be
+// nested maxDepth deep, or without limit when maxDepth is negative.
+func validateGroup(group ConditionGroup, path string, depth, maxDepth int) error {
+	if maxDepth > 0 && depth >= maxDepth {
+		return fmt.Errorf("%s: %w (%d)", path, ErrMaxDepthExceeded, maxDepth)
+	}
+	for i, condition := range group.Conditions {
+		condPath := fmt.Sprintf("%s.conditions[%d]", path, i)
+		isSingle := condition.Key != "" || condition.Operator != "" || condition.Value != nil || condition.Default != nil
+
+		switch {
+		case condition.Group != nil && isSingle:
+			return fmt.Errorf("%s: %w", condPath, ErrMixedNode)
+		case condition.Group != nil:
+			if err := validateGroup(*condition.Group, condPath+".group", depth+1, maxDepth); err != nil {
+				return err
+			}
+		case condition.Key == "" || condition.Operator == "":
+			return fmt.Errorf("%s: %w", condPath, ErrIncompleteCondition)
+		default:
+			if err := checkConditionValue(condition.Operator, condition.Value, condPath); err != nil {
+				return err
+			}
+		}
+
+		if i == len(group.Conditions)-1 {
+			continue
+		}
+		switch condition.NextLogic {
+		case LogicAnd, LogicOr:
+		case "":
+			return fmt.Errorf("%s: %w", condPath, ErrMissingLogic)
+		default:
+			return fmt.Errorf("%s: %w %q", condPath, ErrUnknownLogic, condition.NextLogic)
+		}
+	}
+	return nil
+}
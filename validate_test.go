@@ -0,0 +1,289 @@
+package jsonvaluate
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateConditions(t *testing.T) {
+	tests := []struct {
+		name   string
+		cond   Conditions
+		expect error
+	}{
+		{"empty", Conditions{}, nil},
+		{"single", NewSimpleCondition("age", OperatorGt, 18), nil},
+		{"unary without value", NewSimpleCondition("name", OperatorIsnull, nil), nil},
+		{"group", NewAndGroup(NewSimpleCondition("age", OperatorGt, 18), NewOrGroup()), nil},
+		{
+			"key and children",
+			Conditions{Logic: LogicAnd, Children: []Conditions{NewSimpleCondition("age", OperatorGt, 18)}, Key: "age", Operator: OperatorLt, Value: 10},
+			ErrMixedNode,
+		},
+		{
+			"nested mixed node",
+			NewOrGroup(Conditions{Logic: LogicAnd, Key: "age"}),
+			ErrMixedNode,
+		},
+		{"children without logic", Conditions{Children: []Conditions{NewSimpleCondition("age", OperatorGt, 18)}}, ErrMissingLogic},
+		{"unknown logic", Conditions{Logic: "XOR", Children: []Conditions{NewSimpleCondition("age", OperatorGt, 18)}}, ErrUnknownLogic},
+		{"missing operator", Conditions{Key: "age", Value: 18}, ErrIncompleteCondition},
+		{"missing key", Conditions{Operator: OperatorGt, Value: 18}, ErrIncompleteCondition},
+		{"at least", NewAtLeastGroup(1, NewSimpleCondition("age", OperatorGt, 18)), nil},
+		{"negative threshold", NewAtLeastGroup(-1, NewSimpleCondition("age", OperatorGt, 18)), ErrInvalidThreshold},
+		{"threshold on AND group", Conditions{Logic: LogicAnd, Threshold: 1, Children: []Conditions{NewSimpleCondition("age", OperatorGt, 18)}}, ErrInvalidThreshold},
+		{"threshold on single condition", Conditions{Key: "age", Operator: OperatorGt, Value: 18, Threshold: 1}, ErrMixedNode},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateConditions(tt.cond)
+			if tt.expect == nil && err != nil {
+				t.Errorf("ValidateConditions() = %v, want nil", err)
+			}
+			if tt.expect != nil && !errors.Is(err, tt.expect) {
+				t.Errorf("ValidateConditions() = %v, want %v", err, tt.expect)
+			}
+		})
+	}
+}
+
+func TestValidateConditions_ErrorPath(t *testing.T) {
+	cond := NewAndGroup(
+		NewSimpleCondition("age", OperatorGt, 18),
+		NewOrGroup(NewSimpleCondition("country", OperatorEq, "TH"), Conditions{Key: "status"}),
+	)
+	err := ValidateConditions(cond)
+	if err == nil || err.Error() != "root.children[1].children[1]: "+ErrIncompleteCondition.Error() {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestEvaluateCondition_GroupTakesPrecedence(t *testing.T) {
+	data := map[string]interface{}{"age": 25}
+
+	// The leaf fields (age < 10, false) are ignored because Logic and Children are set
+	cond := Conditions{
+		Logic:    LogicAnd,
+		Children: []Conditions{NewSimpleCondition("age", OperatorGt, 18)},
+		Key:      "age",
+		Operator: OperatorLt,
+		Value:    10,
+	}
+	if !EvaluateCondition(cond, data) {
+		t.Error("Group fields should take precedence over single condition fields")
+	}
+	if !errors.Is(ValidateConditions(cond), ErrMixedNode) {
+		t.Error("ValidateConditions should reject the mixed node")
+	}
+}
+
+func TestValidateConditionGroup(t *testing.T) {
+	tests := []struct {
+		name   string
+		group  ConditionGroup
+		expect error
+	}{
+		{"empty", ConditionGroup{}, nil},
+		{
+			"valid",
+			NewConditionGroup(
+				NewConditionWithLogic("age", OperatorGt, 18, LogicAnd),
+				NewGroupConditionWithLogic(NewConditionGroup(
+					NewConditionWithLogic("status", OperatorEq, "active", LogicOr),
+					NewConditionWithLogic("score", OperatorGte, 80, LogicAnd),
+				), LogicAnd),
+			),
+			nil,
+		},
+		{
+			"missing next logic",
+			NewConditionGroup(
+				NewConditionWithLogic("age", OperatorGt, 18, ""),
+				NewConditionWithLogic("status", OperatorEq, "active", ""),
+			),
+			ErrMissingLogic,
+		},
+		{
+			"unknown next logic",
+			NewConditionGroup(
+				NewConditionWithLogic("age", OperatorGt, 18, "XOR"),
+				NewConditionWithLogic("status", OperatorEq, "active", ""),
+			),
+			ErrUnknownLogic,
+		},
+		{
+			"nested missing next logic",
+			NewConditionGroup(NewGroupConditionWithLogic(NewConditionGroup(
+				NewConditionWithLogic("age", OperatorGt, 18, ""),
+				NewConditionWithLogic("status", OperatorEq, "active", ""),
+			), "")),
+			ErrMissingLogic,
+		},
+		{
+			"group and key",
+			ConditionGroup{Conditions: []ConditionWithLogic{{Key: "age", Group: &ConditionGroup{}}}},
+			ErrMixedNode,
+		},
+		{
+			"incomplete",
+			ConditionGroup{Conditions: []ConditionWithLogic{{Key: "age"}}},
+			ErrIncompleteCondition,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateConditionGroup(tt.group)
+			if tt.expect == nil && err != nil {
+				t.Errorf("ValidateConditionGroup() = %v, want nil", err)
+			}
+			if tt.expect != nil && !errors.Is(err, tt.expect) {
+				t.Errorf("ValidateConditionGroup() = %v, want %v", err, tt.expect)
+			}
+		})
+	}
+}
+
+func TestParseConditions(t *testing.T) {
+	defer RestoreOperators(SnapshotOperators())
+	RegisterCustomOperator("is_even", func(fieldValue, expectedValue interface{}) bool { return true })
+
+	cond, err := ParseConditions([]byte(`{
+		"logic": "AND",
+		"children": [
+			{"key": "age", "operator": ">=", "value": 18},
+			{"key": "score", "operator": "is_even"}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseConditions() = %v, want nil", err)
+	}
+	if !EvaluateCondition(cond, map[string]interface{}{"age": 21, "score": 4}) {
+		t.Error("Parsed condition should evaluate to true")
+	}
+
+	tests := []struct {
+		name   string
+		json   string
+		expect error
+		msg    string
+	}{
+		{
+			"typo in operator",
+			`{"logic": "AND", "children": [{"key": "age", "operator": ">=", "value": 18}, {"key": "country", "operator": "equals", "value": "TH"}]}`,
+			ErrUnknownOperator,
+			`root.children[1]: unknown operator "equals" for key "country"`,
+		},
+		{
+			"unregistered custom operator",
+			`{"key": "score", "operator": "is_odd"}`,
+			ErrUnknownOperator,
+			`root: unknown operator "is_odd" for key "score"`,
+		},
+		{
+			"malformed tree",
+			`{"logic": "XOR", "children": [{"key": "age", "operator": "typo"}]}`,
+			ErrUnknownLogic,
+			"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseConditions([]byte(tt.json))
+			if !errors.Is(err, tt.expect) {
+				t.Fatalf("ParseConditions() = %v, want %v", err, tt.expect)
+			}
+			if tt.msg != "" && err.Error() != tt.msg {
+				t.Errorf("ParseConditions() error = %q, want %q", err.Error(), tt.msg)
+			}
+		})
+	}
+
+	if _, err := ParseConditions([]byte(`{"key": `)); err == nil {
+		t.Error("ParseConditions() should return JSON syntax errors")
+	}
+}
+
+func TestEvaluator_AllowedOperators(t *testing.T) {
+	defer RestoreOperators(SnapshotOperators())
+	RegisterCustomOperator("is_even", func(fieldValue, expectedValue interface{}) bool { return true })
+
+	e := NewEvaluator()
+	e.AllowedOperators = map[Operator]bool{OperatorEq: true, OperatorGte: true, OperatorIn: true, OperatorAnyOp: true}
+	data := map[string]interface{}{"age": 21, "country": "TH", "email": "a@b.co"}
+
+	tests := []struct {
+		name string
+		cond Conditions
+		msg  string
+	}{
+		{
+			"regular expression",
+			NewAndGroup(NewSimpleCondition("age", OperatorGte, 18), NewSimpleCondition("email", OperatorRegexExtract, []interface{}{"^(.+)@", 1, "==", "a"})),
+			`root.children[1]: operator not allowed "regex_extract" for key "email"`,
+		},
+		{
+			"custom operator",
+			NewOrGroup(NewSimpleCondition("country", OperatorEq, "SG"), Conditions{Logic: LogicNot, Children: []Conditions{NewSimpleCondition("age", "is_even", nil)}}),
+			`root.children[1].children[0]: operator not allowed "is_even" for key "age"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateConditions(tt.cond); err != nil {
+				t.Fatalf("ValidateConditions() = %v, want nil", err)
+			}
+			err := e.ValidateConditions(tt.cond)
+			if !errors.Is(err, ErrOperatorNotAllowed) {
+				t.Fatalf("Evaluator.ValidateConditions() = %v, want ErrOperatorNotAllowed", err)
+			}
+			if err.Error() != tt.msg {
+				t.Errorf("Evaluator.ValidateConditions() error = %q, want %q", err.Error(), tt.msg)
+			}
+		})
+	}
+
+	allowed := NewAndGroup(NewSimpleCondition("age", OperatorGte, 18), NewSimpleCondition("country", OperatorIn, []string{"TH", "SG"}))
+	if err := e.ValidateConditions(allowed); err != nil {
+		t.Errorf("Evaluator.ValidateConditions() = %v, want nil", err)
+	}
+	if !e.EvaluateCondition(allowed, data) {
+		t.Error("expected allowed operators to evaluate")
+	}
+	if err := NewEvaluator().ValidateConditions(tests[0].cond); err != nil {
+		t.Errorf("ValidateConditions() without an allowlist = %v, want nil", err)
+	}
+	if err := e.ValidateConditions(Conditions{Logic: "XOR"}); !errors.Is(err, ErrUnknownLogic) {
+		t.Errorf("Evaluator.ValidateConditions() = %v, want ErrUnknownLogic", err)
+	}
+
+	// Evaluation enforces the allowlist too, including nested operators
+	for _, cond := range []Conditions{
+		NewSimpleCondition("email", OperatorRegexExtract, []interface{}{"^(.+)@", 1, "==", "a"}),
+		NewSimpleCondition("age", "is_even", nil),
+		NewSimpleCondition("email", OperatorAnyOp, []OperatorValue{{Operator: OperatorContains, Value: "@"}}),
+	} {
+		if result, err := e.EvaluateConditionE(cond, data); result || !errors.Is(err, ErrOperatorNotAllowed) {
+			t.Errorf("EvaluateConditionE(%s) = %v, %v, want false, ErrOperatorNotAllowed", cond.Operator, result, err)
+		}
+		if e.EvaluateCondition(cond, data) {
+			t.Errorf("EvaluateCondition(%s) = true, want false", cond.Operator)
+		}
+	}
+
+	group := NewConditionGroup(
+		NewConditionWithLogic("age", OperatorGte, 18, LogicAnd),
+		ConditionWithLogic{Group: &ConditionGroup{Conditions: []ConditionWithLogic{
+			NewConditionWithLogic("email", OperatorContains, "@", ""),
+		}}},
+	)
+	err := e.ValidateConditionGroup(group)
+	if !errors.Is(err, ErrOperatorNotAllowed) || err.Error() != `root.conditions[1].group.conditions[0]: operator not allowed "contains" for key "email"` {
+		t.Errorf("Evaluator.ValidateConditionGroup() = %v, want ErrOperatorNotAllowed", err)
+	}
+	if e.EvaluateConditionGroup(group, data) {
+		t.Error("expected a group using a disallowed operator to be false")
+	}
+}